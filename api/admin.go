@@ -0,0 +1,16 @@
+package api
+
+// admin returns the signed in user, as long as they're one of the admins in
+// the config.
+func (api *api) admin(ctx *Context) (string, error) {
+	userID, err := ctx.UserID()
+	if err != nil {
+		return "", err
+	}
+	for _, id := range api.config.Admins {
+		if id == userID {
+			return userID, nil
+		}
+	}
+	return "", ErrForbidden
+}
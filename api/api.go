@@ -19,32 +19,42 @@ import (
 	errwrap "github.com/pkg/errors"
 	autocert "golang.org/x/crypto/acme/autocert"
 
+	blobs "github.com/ashb/slackarchive/blobs"
 	config "github.com/ashb/slackarchive/config"
+	logs "github.com/ashb/slackarchive/logs"
+	metrics "github.com/ashb/slackarchive/metrics"
 	models "github.com/ashb/slackarchive/models"
+	redact "github.com/ashb/slackarchive/redact"
+	storage "github.com/ashb/slackarchive/storage"
 	utils "github.com/ashb/slackarchive/utils"
-	"github.com/go-pg/pg"
-	"github.com/go-pg/pg/orm"
 	"github.com/slack-go/slack"
 
+	apierrors "github.com/ashb/slackarchive/api/errors"
 	handlers "github.com/ashb/slackarchive/api/handlers"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 
 	"net"
-
-	logging "github.com/op/go-logging"
 )
 
-var log = logging.MustGetLogger("slackarchive-api")
+var log = logs.New("api")
 
 type api struct {
-	session orm.DB
-	config  *config.Config
-	store   *sessions.CookieStore
+	db     storage.Store
+	blobs  blobs.Store
+	config *config.Config
+	store  *sessions.CookieStore
+	// redactor redacts messages in responses, nil unless the redaction mode
+	// is output
+	redactor *redact.Redactor
+	// syncer is the bot whose syncs readiness checks look at, nil when
+	// the API runs without it
+	syncer Syncer
+	tokens tokenChecks
 }
 
-func New(config *config.Config, db orm.DB) *api {
+func New(config *config.Config, db storage.Store, blobs blobs.Store, redactor *redact.Redactor, syncer Syncer) *api {
 
 	log.Info("Starting")
 
@@ -53,10 +63,17 @@ func New(config *config.Config, db orm.DB) *api {
 		[]byte(config.Cookies.EncryptionKey),
 	)
 
+	if !config.Redaction.Output() {
+		redactor = nil
+	}
+
 	return &api{
-		session: db,
-		config:  config,
-		store:   store,
+		db:       db,
+		blobs:    blobs,
+		config:   config,
+		store:    store,
+		redactor: redactor,
+		syncer:   syncer,
 	}
 }
 
@@ -68,6 +85,9 @@ func (api *api) teamHandler(ctx *Context) error {
 		IsDisabled bool   `json:"is_disabled"`
 		IsHidden   bool   `json:"is_hidden"`
 
+		EnterpriseID   string `json:"enterprise_id,omitempty"`
+		EnterpriseName string `json:"enterprise_name,omitempty"`
+
 		Plan string                 `json:"plan"`
 		Icon map[string]interface{} `json:"icon"`
 	}
@@ -80,16 +100,11 @@ func (api *api) teamHandler(ctx *Context) error {
 	var teams []models.Team
 	if t, err := api.Team(ctx); err == nil {
 		teams = append(teams, *t)
-		log.Debugf("api.Team added %+v", t)
-	} else {
-		qry := ctx.db.Model(&teams).Where("is_disabled = false")
-		err := qry.Select()
-
-		if err != nil {
-			// TODO: 400/500
-			fmt.Printf("Error: %s\n", err.Error())
-			return err
-		}
+		ctx.log.Debugf("api.Team added %+v", t)
+	} else if teams, err = ctx.db.Teams().List(); err != nil {
+		// TODO: 400/500
+		ctx.log.Errorf("Error listing teams: %s", err)
+		return err
 	}
 	for _, team := range teams {
 		tr := TeamResponse{}
@@ -105,29 +120,32 @@ func (api *api) teamHandler(ctx *Context) error {
 
 type domainFn func(*models.Team, *Context) error
 
+type UserProfileResponse struct {
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	RealName    string `json:"real_name"`
+	DisplayName string `json:"display_name"`
+	// RealNameNormalized string `json:"real_name_normalized"`
+	// Email              string `json:"email"`
+	// Skype         string `json:"skype"`
+	// Phone         string `json:"phone"`
+	Image24       string `json:"image_24"`
+	Image32       string `json:"image_32"`
+	Image48       string `json:"image_48"`
+	Image72       string `json:"image_72"`
+	Image192      string `json:"image_192"`
+	ImageOriginal string `json:"image_original"`
+	Title         string `json:"title"`
+}
+
 // TODO: only for auth users send more info
 type UserResponse struct {
-	ID      string `json:"user_id"`
-	Name    string `json:"name"`
-	Team    string `json:"team"`
-	Deleted bool   `json:"deleted"`
-	Color   string `json:"color"`
-	Profile struct {
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-		RealName  string `json:"real_name"`
-		// RealNameNormalized string `json:"real_name_normalized"`
-		// Email              string `json:"email"`
-		// Skype         string `json:"skype"`
-		// Phone         string `json:"phone"`
-		Image24       string `json:"image_24"`
-		Image32       string `json:"image_32"`
-		Image48       string `json:"image_48"`
-		Image72       string `json:"image_72"`
-		Image192      string `json:"image_192"`
-		ImageOriginal string `json:"image_original"`
-		Title         string `json:"title"`
-	} `json:"profile"`
+	ID      string              `json:"user_id"`
+	Name    string              `json:"name"`
+	Team    string              `json:"team"`
+	Deleted bool                `json:"deleted"`
+	Color   string              `json:"color"`
+	Profile UserProfileResponse `json:"profile"`
 	// IsBot             bool   `json:"is_bot"`
 	// IsAdmin           bool   `json:"is_admin"`
 	// IsOwner           bool   `json:"is_owner"`
@@ -136,72 +154,114 @@ type UserResponse struct {
 	// IsUltraRestricted bool   `json:"is_ultra_restricted"`
 	// HasFiles          bool   `json:"has_files"`
 	// Presence          string `json:"presence"`
+
+	// Slack Connect users from another organisation
+	IsExternal   bool   `json:"is_external"`
+	HomeTeamID   string `json:"home_team_id,omitempty"`
+	HomeTeamName string `json:"home_team_name,omitempty"`
 }
 
 func (api *api) usersHandler(ctx *Context) error {
 	response := struct {
-		Users      []slack.User `json:"users"`
-		TotalCount int64        `json:"total"`
-	}{}
-
-	panic("usersHandler not ported")
-
-	//ctx.w.Header().Set("Content-Type", "application/json")
-	return ctx.Write(response)
-}
+		Users      []UserResponse `json:"users"`
+		TotalCount int            `json:"total"`
+	}{
+		Users: []UserResponse{},
+	}
 
-/*
 	var team *models.Team
-	if t, err := api.Team(ctx); err == nil {
-		team = t
-	} else {
+	var err error
+	if team, err = api.Team(ctx); err != nil {
 		return err
 	}
 
-	team_id := team.ID
+	var users []models.User
 
-	offset := int(0)
-	if val, err := strconv.Atoi(ctx.r.FormValue("offset")); err == nil {
-		offset = val
+	filter := storage.UserFilter{TeamID: team.ID, Pager: storage.NewPager(ctx.r.Form, 1000)}
+	if users, response.TotalCount, err = ctx.db.Users().List(filter); err != nil {
+		return errwrap.Wrap(err, "Error selecting users")
 	}
 
-	size := int(1000)
-	if val, err := strconv.Atoi(ctx.r.FormValue("size")); err == nil {
-		size = val
+	for _, user := range users {
+		usr := UserResponse{}
+		if err := utils.Merge(&usr, user); err != nil {
+			ctx.log.Error(err.Error())
+		}
+
+		response.Users = append(response.Users, usr)
 	}
 
-	qry := ctx.db.Users.Find(bson.M{"team": team_id})
+	return ctx.Write(response)
+}
 
-	if count, err := qry.Count(); err != nil {
-		response.TotalCount = int64(count)
+// userHandler returns a user with who they have been over time: the names
+// and profiles they had, and when they were deactivated.
+func (api *api) userHandler(ctx *Context) error {
+	type HistoryResponse struct {
+		Change    string              `json:"change"`
+		Name      string              `json:"name"`
+		Deleted   bool                `json:"deleted"`
+		Profile   UserProfileResponse `json:"profile"`
+		ChangedAt time.Time           `json:"changed_at"`
 	}
 
-	iter := qry.Skip(offset).Limit(size).Iter()
-	defer iter.Close()
+	team, err := api.Team(ctx)
+	if err != nil {
+		return err
+	}
 
-	user := models.User{}
-	for iter.Next(&user) {
-		usr := UserResponse{}
-		if err := utils.Merge(&usr, user); err != nil {
-			log.Error(err.Error())
-		}
+	user, err := ctx.db.Users().Get(ctx.Vars["id"])
+	if err == storage.ErrNotFound || (err == nil && user.TeamID != team.ID) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
 
-		response.Users = append(response.Users, usr)
+	history, err := ctx.db.Users().History(user.ID)
+	if err != nil {
+		return errwrap.Wrap(err, "Error selecting user history")
+	}
+
+	response := struct {
+		User          UserResponse          `json:"user"`
+		History       []HistoryResponse     `json:"history"`
+		Deactivations []models.Deactivation `json:"deactivations"`
+	}{
+		History:       make([]HistoryResponse, 0, len(history)),
+		Deactivations: models.Deactivations(history),
+	}
+	if err := utils.Merge(&response.User, *user); err != nil {
+		ctx.log.Error(err.Error())
+	}
+	for _, entry := range history {
+		hr := HistoryResponse{}
+		if err := utils.Merge(&hr, entry); err != nil {
+			ctx.log.Error(err.Error())
+		}
+		response.History = append(response.History, hr)
 	}
-*/
+
+	return ctx.Write(response)
+}
 
 func (api *api) channelsHandler(ctx *Context) error {
 	type ChannelResponse struct {
-		ID         string `json:"channel_id"`
-		Name       string `json:"name"`
-		Team       string `json:"team"`
-		IsChannel  bool   `json:"is_channel"`
-		IsArchived bool   `json:"is_archived"`
-		IsGeneral  bool   `json:"is_general"`
-		IsGroup    bool   `json:"is_group"`
-		IsStarred  bool   `json:"is_starred"`
-		IsMember   bool   `json:"is_member"`
-		Purpose    struct {
+		ID          string `json:"channel_id"`
+		Name        string `json:"name"`
+		Team        string `json:"team"`
+		IsChannel   bool   `json:"is_channel"`
+		IsArchived  bool   `json:"is_archived"`
+		IsDeleted   bool   `json:"is_deleted"`
+		IsGeneral   bool   `json:"is_general"`
+		IsGroup     bool   `json:"is_group"`
+		IsStarred   bool   `json:"is_starred"`
+		IsMember    bool   `json:"is_member"`
+		IsShared    bool   `json:"is_shared"`
+		IsExtShared bool   `json:"is_ext_shared"`
+		Topic       struct {
+			Value string `json:"value"`
+		} `json:"topic"`
+		Purpose struct {
 			Value string `json:"value"`
 		} `json:"purpose"`
 		NumMembers int `json:"num_members"`
@@ -218,10 +278,8 @@ func (api *api) channelsHandler(ctx *Context) error {
 		return err
 	}
 
-	var channels []models.Channel
-
-	filter := &models.ChannelFilter{team.ID, models.NewPager(ctx.r.Form)}
-	count, err := ctx.db.Model(&channels).Apply(filter.Filter).SelectAndCount()
+	filter := storage.ChannelFilter{TeamID: team.ID, Pager: storage.NewPager(ctx.r.Form, 1000)}
+	channels, count, err := ctx.db.Channels().List(filter)
 
 	if err != nil {
 		return err
@@ -231,7 +289,7 @@ func (api *api) channelsHandler(ctx *Context) error {
 	for _, channel := range channels {
 		chnl := ChannelResponse{}
 		if err := utils.Merge(&chnl, channel); err != nil {
-			log.Error(err.Error())
+			ctx.log.Error(err.Error())
 		}
 
 		response.Channels = append(response.Channels, chnl)
@@ -277,19 +335,14 @@ func (api *api) Team(ctx *Context) (*models.Team, error) {
 		return nil, err
 	}
 
-	team := &models.Team{
-		Domain:     host,
-	}
-
-	if err := ctx.db.Model(team).WhereStruct(team).Select(); err == nil {
+	if team, err := ctx.db.Teams().GetByDomain(host); err == nil {
 		return team, nil
 	}
 
-	team.Domain = api.config.Team
-	if err := ctx.db.Model(team).WhereStruct(team).Select(); err == nil {
+	if team, err := ctx.db.Teams().GetByDomain(api.config.Team); err == nil {
 		return team, nil
 	} else {
-		log.Errorf("Error: %#v\n", err.Error())
+		ctx.log.Errorf("Error loading team %s: %s", api.config.Team, err)
 		return nil, fmt.Errorf("Team is disabled or does not exist")
 	}
 }
@@ -297,9 +350,19 @@ func (api *api) Team(ctx *Context) (*models.Team, error) {
 func (api *api) messagesHandler(ctx *Context) error {
 
 	response := struct {
-		Messages   []slack.Msg `json:"messages"`
-		TotalCount int         `json:"total"`
-		Aggs       struct {
+		Messages []slack.Msg `json:"messages"`
+		// Scores are the search ranks of Messages, in the same order
+		Scores []float64 `json:"scores,omitempty"`
+		// TotalCount is left out with count=0
+		TotalCount *int `json:"total,omitempty"`
+		// Next and Prev are the cursors to pass as after and before for the
+		// pages either side
+		Next string `json:"next,omitempty"`
+		Prev string `json:"prev,omitempty"`
+		// Rendered are the Messages in the format asked for, html or text,
+		// in the same order
+		Rendered []string `json:"rendered,omitempty"`
+		Aggs     struct {
 			Buckets map[string]int64 `json:"buckets"`
 		} `json:"aggs"`
 		Related struct {
@@ -324,25 +387,52 @@ func (api *api) messagesHandler(ctx *Context) error {
 	if team, err = api.Team(ctx); err != nil {
 		return err
 	}
-	_ = team
 
-	var messages []models.Message
-	qry := api.session.Model(&messages)
+	query := storage.MessageQuery{
+		TeamID:    team.ID,
+		Query:     ctx.r.FormValue("q"),
+		Ascending: ctx.r.FormValue("sort") == "asc",
+		Relevance: ctx.r.FormValue("sort") == "relevance",
+		Aggregate: ctx.r.FormValue("aggs") == "1",
+		NoCount:   ctx.r.FormValue("count") == "0",
+		Pager:     storage.NewPager(ctx.r.Form, 500),
+	}
 
-	var searchQuery string
-	if searchQuery = ctx.r.FormValue("q"); searchQuery != "" {
-		qry.Where(`?TableAlias.tsv @@ websearch_to_tsquery(?)`, searchQuery)
+	if val := ctx.r.FormValue("after"); val != "" {
+		if query.After, err = storage.ParseCursor(val); err != nil {
+			return ErrInvalidCursor
+		}
+	}
+	if val := ctx.r.FormValue("before"); val != "" {
+		if query.Before, err = storage.ParseCursor(val); err != nil {
+			return ErrInvalidCursor
+		}
+	}
+	if query.After != nil && query.Before != nil {
+		return ErrInvalidCursor
+	}
+	if (query.After != nil || query.Before != nil) && query.Relevance && query.Query != "" {
+		return ErrCursorRelevance
 	}
 
-	qry.Column("Channel._").Where("Channel.team_id = ?", team.ID)
+	switch mode := storage.SearchMode(ctx.r.FormValue("mode")); mode {
+	case storage.SearchFullText:
+	case storage.SearchExact, storage.SearchFuzzy:
+		if _, err := storage.ParseSubstringQuery(query.Query); err != nil {
+			return apierrors.New("invalid-search-query", err.Error(), http.StatusBadRequest)
+		}
+		query.Mode = mode
+	default:
+		return ErrInvalidSearchMode
+	}
+	if err := api.checkSearch(query.Query, query.Mode); err != nil {
+		return err
+	}
 
 	// check if bot have been removed from the channel
 	if channel := ctx.r.FormValue("channel"); channel != "" {
 		// TODO: Check our Archive bot is still a member of this channel
-		qry.WhereStruct(&models.Message{
-			ChannelID: channel,
-		})
-
+		query.ChannelID = channel
 	} else {
 		// TODO: Only the channels where our Archive bot is still a member of this channel
 		//panic("Not implemented - no channel")
@@ -356,87 +446,79 @@ func (api *api) messagesHandler(ctx *Context) error {
 		panic("Not implemented - thread")
 	}
 
-	var from, to *time.Time
-	if from, err = models.TimestampToTime(ctx.r.FormValue("from")); err != nil {
+	if query.From, err = models.TimestampToTime(ctx.r.FormValue("from")); err != nil {
 		return errwrap.Wrap(err, "Invalid from value")
 	}
 
-	if to, err = models.TimestampToTime(ctx.r.FormValue("to")); err != nil {
+	if query.To, err = models.TimestampToTime(ctx.r.FormValue("to")); err != nil {
 		return errwrap.Wrap(err, "Invalid to value")
 	}
 
-	if from != nil && to != nil {
-		qry.Where("?TableAlias.timestamp BETWEEN ? AND ?", from, to)
-	} else if from != nil {
-		qry.Where("?TableAlias.timestamp >= ?", from)
-	} else if to != nil {
-		qry.Where("?TableAlias.timestamp <= ?", to)
+	result, err := ctx.db.Messages().Search(query)
+	if err == storage.ErrSearchTimeout {
+		return ErrSearchTimeout
+	} else if err != nil {
+		return err
 	}
+	messages := result.Messages
+	if err := api.redactMessages(messages...); err != nil {
+		return err
+	}
+	if !query.NoCount {
+		response.TotalCount = &result.TotalCount
+	}
+	if result.Next != nil {
+		response.Next = result.Next.String()
+	}
+	if result.Prev != nil {
+		response.Prev = result.Prev.String()
+	}
+	response.Aggs.Buckets = result.Buckets
 
-	qry.Where(`NOT ?TableAlias."msg" @> '{"hidden": true}'`)
-	qry.Where(`?TableAlias."msg"->>'subtype' IS NULL OR ?TableAlias."msg"->>'subtype' NOT IN ('message_changed', 'message_deleted', 'channel_join', 'channel_leave', 'pinned_item')`)
-
-	if val := ctx.r.FormValue("aggs"); val == "1" {
-
-		var res []struct {
-			ChannelID string
-			Matches   int64
-		}
-		agg := qry.Copy()
-		if err := agg.Group("channel_id").Column("channel_id").ColumnExpr("count(*) as matches").Select(&res); err != nil {
-			return errwrap.Wrap(err, "Error performing aggregate search")
-		}
-		for _, c := range res {
-			response.Aggs.Buckets[c.ChannelID] = c.Matches
+	response.Messages = make([]slack.Msg, 0, len(messages))
+	for _, message := range messages {
+		response.Messages = append(response.Messages, *message.Msg)
+		if query.Query != "" {
+			response.Scores = append(response.Scores, message.Score)
 		}
 	}
 
-	if val := ctx.r.FormValue("sort"); val == "asc" {
-		qry.Order("timestamp ASC")
-	} else {
-		qry.Order("timestamp DESC")
+	if response.Related.Users, err = relatedUsers(ctx, messages); err != nil {
+		return err
 	}
 
-	if searchQuery != "" {
-		qry.ColumnExpr(
-			`jsonb_set(?TableAlias.msg, '{text}', ts_headline(?TableAlias.msg->'text', websearch_to_tsquery(?), 'StartSel=[hl] StopSel=[/hl] HighlightAll=true')) AS msg`,
-			searchQuery,
-		)
+	if response.Rendered, err = renderMessages(ctx, team.ID, response.Messages, response.Related.Users); err != nil {
+		return err
 	}
 
-	pager := models.NewPager(ctx.r.Form)
-
-	pager.MaxLimit = 500
-	qry = qry.Apply(pager.Pagination)
-
-	qry = qry.Relation("User")
+	//ctx.w.Header().Set("Content-Type", "application/json")
+	return ctx.Write(response)
+}
 
-	if response.TotalCount, err = qry.SelectAndCount(); err != nil {
-		return errwrap.Wrap(err, "Error selecting messages")
-	}
+var mentionRegexp = regexp.MustCompile(`\<\@(.+?)\>`)
 
-	r := regexp.MustCompile(`\<\@(.+?)\>`)
-	response.Messages = make([]slack.Msg, 0, len(messages))
+// relatedUsers loads the authors of messages, the users they mention and
+// the authors of the threads they reply to.
+func relatedUsers(ctx *Context, messages []models.Message) (map[string]models.User, error) {
+	related := map[string]models.User{}
 
 	// UserIDs to find
 	userids := make(map[string]struct{})
 
 	for _, message := range messages {
-		response.Messages = append(response.Messages, *message.Msg)
-
-		response.Related.Users[message.User.ID] = *message.User
+		related[message.User.ID] = *message.User
 		// If another message asked for this user, we've got it
 		delete(userids, message.User.ID)
 
 		// extract matches from message text
 		func() {
 			var matches [][]string
-			if matches = r.FindAllStringSubmatch(message.Msg.Text, -1); matches == nil {
+			if matches = mentionRegexp.FindAllStringSubmatch(message.Msg.Text, -1); matches == nil {
 				return
 			}
 
 			for _, match := range matches {
-				if _, ok := response.Related.Users[match[1]]; ok {
+				if _, ok := related[match[1]]; ok {
 					// Already loaded from prefetch
 					continue
 				}
@@ -445,16 +527,16 @@ func (api *api) messagesHandler(ctx *Context) error {
 		}()
 
 		if message.Msg.ParentUserId != "" {
-			if _, ok := response.Related.Users[message.Msg.ParentUserId]; ok == false {
+			if _, ok := related[message.Msg.ParentUserId]; ok == false {
 				userids[message.Msg.ParentUserId] = struct{}{}
 			}
 		}
 	}
 
-	users := []models.User{}
+	var users []models.User
 
 	if len(userids) == 0 {
-		log.Debug("No non-prefetched users to query")
+		ctx.log.Debug("No non-prefetched users to query")
 	} else {
 		ids := make([]string, len(userids))
 		i := 0
@@ -462,17 +544,16 @@ func (api *api) messagesHandler(ctx *Context) error {
 			ids[i] = u
 			i++
 		}
-		if err := api.session.Model((*models.User)(nil)).Where("id IN (?)", pg.In(ids)).Select(&users); err != nil {
-			return errwrap.Wrap(err, "Error selectiing related users for message")
+		var err error
+		if users, err = ctx.db.Users().GetMany(ids); err != nil {
+			return nil, errwrap.Wrap(err, "Error selectiing related users for message")
 		}
 	}
 
 	for _, user := range users {
-		response.Related.Users[user.ID] = user
+		related[user.ID] = user
 	}
-
-	//ctx.w.Header().Set("Content-Type", "application/json")
-	return ctx.Write(response)
+	return related, nil
 }
 
 func (api *api) health(ctx *Context) error {
@@ -490,13 +571,37 @@ func (api *api) Serve() {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/health.html", api.ContextHandlerFunc(api.health)).Methods("GET")
+	r.HandleFunc("/healthz", api.ContextHandlerFunc(api.healthzHandler)).Methods("GET")
+	r.HandleFunc("/readyz", api.ContextHandlerFunc(api.readyzHandler)).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	sr := r.PathPrefix("/v1").Subrouter()
 
 	sr.HandleFunc("/messages", api.ContextHandlerFunc(api.messagesHandler)).Methods("GET")
+	sr.HandleFunc("/messages/{channel}/{ts}", api.ContextHandlerFunc(api.messageHandler)).Methods("GET")
+	sr.HandleFunc("/permalink", api.ContextHandlerFunc(api.permalinkHandler)).Methods("GET")
 	sr.HandleFunc("/channels", api.ContextHandlerFunc(api.channelsHandler)).Methods("GET")
+	sr.HandleFunc("/channels/{id}/events", api.ContextHandlerFunc(api.channelEventsHandler)).Methods("GET")
+	sr.HandleFunc("/channels/{id}/pins", api.ContextHandlerFunc(api.pinsHandler)).Methods("GET")
+	sr.HandleFunc("/channels/{id}/bookmarks", api.ContextHandlerFunc(api.bookmarksHandler)).Methods("GET")
 	sr.HandleFunc("/users", api.ContextHandlerFunc(api.usersHandler)).Methods("GET")
+	sr.HandleFunc("/users/{id}", api.ContextHandlerFunc(api.userHandler)).Methods("GET")
 	sr.HandleFunc("/team", api.ContextHandlerFunc(api.teamHandler)).Methods("GET")
+	sr.HandleFunc("/emoji", api.ContextHandlerFunc(api.emojiListHandler)).Methods("GET")
+	sr.HandleFunc("/emoji/{name}", api.ContextHandlerFunc(api.emojiHandler)).Methods("GET")
+	sr.HandleFunc("/saved-searches", api.ContextHandlerFunc(api.savedSearchesHandler)).Methods("GET")
+	sr.HandleFunc("/saved-searches", api.ContextHandlerFunc(api.createSavedSearchHandler)).Methods("POST")
+	sr.HandleFunc("/saved-searches/{id:[0-9]+}", api.ContextHandlerFunc(api.savedSearchHandler)).Methods("GET")
+	sr.HandleFunc("/saved-searches/{id:[0-9]+}", api.ContextHandlerFunc(api.updateSavedSearchHandler)).Methods("PUT")
+	sr.HandleFunc("/saved-searches/{id:[0-9]+}", api.ContextHandlerFunc(api.deleteSavedSearchHandler)).Methods("DELETE")
+	sr.HandleFunc("/saved-searches/{id:[0-9]+}/seen", api.ContextHandlerFunc(api.seenSavedSearchHandler)).Methods("POST")
+	sr.HandleFunc("/admin/holds", api.ContextHandlerFunc(api.legalHoldsHandler)).Methods("GET")
+	sr.HandleFunc("/admin/holds", api.ContextHandlerFunc(api.createLegalHoldHandler)).Methods("POST")
+	sr.HandleFunc("/admin/holds/{id:[0-9]+}", api.ContextHandlerFunc(api.legalHoldHandler)).Methods("GET")
+	sr.HandleFunc("/admin/holds/{id:[0-9]+}/release", api.ContextHandlerFunc(api.releaseLegalHoldHandler)).Methods("POST")
+	sr.HandleFunc("/admin/holds/{id:[0-9]+}/report", api.ContextHandlerFunc(api.legalHoldReportHandler)).Methods("GET")
+	sr.HandleFunc("/admin/audit", api.ContextHandlerFunc(api.auditHandler)).Methods("GET")
+	sr.HandleFunc("/admin/ediscovery", api.ContextHandlerFunc(api.eDiscoveryHandler)).Methods("GET")
 	/*
 		api.HandleFunc("/messages", messagesHandler).Methods("GET")
 		api.HandleFunc("/me", meHandler).Methods("GET")
@@ -515,9 +620,11 @@ func (api *api) Serve() {
 
 	// install middlewares
 	handler = handlers.LoggingHandler(handler)
+	handler = handlers.TracingHandler(handler)
 	handler = handlers.RecoverHandler(handler)
 	handler = handlers.RedirectHandler(handler)
 	handler = handlers.CorsHandler(handler)
+	handler = handlers.RequestIDHandler(handler)
 
 	// disable rate limiter for now
 	// handler = ratelimit.Request(ratelimit.IP).Rate(30, 60*time.Second).LimitBy(memory.New())(sr)
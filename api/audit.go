@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	errwrap "github.com/pkg/errors"
+
+	apierrors "github.com/ashb/slackarchive/api/errors"
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// auditSecretParams are left out of the audit log.
+var auditSecretParams = []string{"code", "state"}
+
+// audit records a call in the audit log once it's answered, as long as
+// someone is signed in, sign ins included. Failing to record it doesn't
+// fail the call.
+func (api *api) audit(ctx *Context) {
+	userID, err := ctx.UserID()
+	if err != nil {
+		return
+	}
+
+	entry := &models.AuditEntry{
+		UserID:     userID,
+		Method:     ctx.r.Method,
+		Path:       ctx.r.URL.Path,
+		Status:     ctx.status,
+		RemoteAddr: ctx.r.RemoteAddr,
+		UserAgent:  ctx.r.UserAgent(),
+		CreatedAt:  time.Now(),
+	}
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	if route := mux.CurrentRoute(ctx.r); route != nil {
+		entry.Route, _ = route.GetPathTemplate()
+	}
+
+	if params := ctx.r.URL.Query(); len(params) > 0 {
+		for _, k := range auditSecretParams {
+			params.Del(k)
+		}
+		entry.Params = params
+	}
+
+	switch {
+	case ctx.Vars["channel"] != "":
+		entry.ChannelID = ctx.Vars["channel"]
+	case strings.Contains(entry.Route, "/channels/{id}"):
+		entry.ChannelID = ctx.Vars["id"]
+	default:
+		entry.ChannelID = ctx.r.URL.Query().Get("channel")
+	}
+
+	if err := api.db.Audit().Add(entry); err != nil {
+		ctx.log.Errorf("Error adding %s %s by %s to the audit log: %s", entry.Method, entry.Path, userID, err)
+	}
+}
+
+// auditHandler lists the audit log newest first, narrowed down by user_id,
+// channel_id, route, from and to (RFC 3339). format=jsonl exports every
+// matching entry, one JSON object per line.
+func (api *api) auditHandler(ctx *Context) error {
+	if _, err := api.admin(ctx); err != nil {
+		return err
+	}
+	ctx.r.ParseForm()
+
+	filter := storage.AuditFilter{
+		UserID:    ctx.r.Form.Get("user_id"),
+		ChannelID: ctx.r.Form.Get("channel_id"),
+		Route:     ctx.r.Form.Get("route"),
+		Pager:     storage.NewPager(ctx.r.Form, 1000),
+	}
+	verr := &apierrors.ValidationError{}
+	for field, t := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		val := ctx.r.Form.Get(field)
+		if val == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			verr.Add(field, "invalid", "Must be an RFC 3339 time, like 2006-01-02T15:04:05Z")
+			continue
+		}
+		*t = &parsed
+	}
+	if !verr.Valid() {
+		return verr
+	}
+
+	switch ctx.r.Form.Get("format") {
+	case "", "json":
+	case "jsonl":
+		return api.auditJSONL(ctx, filter)
+	default:
+		return ErrInvalidAuditFormat
+	}
+
+	entries, total, err := ctx.db.Audit().List(filter)
+	if err != nil {
+		return errwrap.Wrap(err, "Error selecting audit log")
+	}
+	return ctx.Write(struct {
+		Entries []models.AuditEntry `json:"entries"`
+		Total   int                 `json:"total"`
+	}{entries, total})
+}
+
+// auditJSONL writes every entry matching filter as JSON lines, newest
+// first.
+func (api *api) auditJSONL(ctx *Context, filter storage.AuditFilter) error {
+	// Calls made during the export would shift the pages
+	now := time.Now()
+	if filter.To == nil || filter.To.After(now) {
+		filter.To = &now
+	}
+
+	ctx.w.Header().Set("Content-Type", "application/x-ndjson")
+	ctx.w.Header().Set("Content-Disposition", `attachment; filename="audit-log.jsonl"`)
+	ctx.bodyWritten = true
+
+	enc := json.NewEncoder(ctx.w)
+	filter.Pager = storage.Pager{Limit: 1000}
+	for {
+		entries, _, err := ctx.db.Audit().List(filter)
+		if err != nil {
+			// Too late for an error response, leave the export truncated
+			ctx.log.Errorf("Error exporting audit log: %s", err)
+			return nil
+		}
+		for i := range entries {
+			if err := enc.Encode(&entries[i]); err != nil {
+				return err
+			}
+		}
+		if len(entries) < filter.Limit {
+			return nil
+		}
+		filter.Offset += len(entries)
+	}
+}
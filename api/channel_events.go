@@ -0,0 +1,64 @@
+package api
+
+import (
+	errwrap "github.com/pkg/errors"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// channelEventsHandler returns a channel's timeline: when it was created,
+// renamed, archived and so on, oldest first.
+func (api *api) channelEventsHandler(ctx *Context) error {
+	team, err := api.Team(ctx)
+	if err != nil {
+		return err
+	}
+
+	channel, err := teamChannel(ctx, team, ctx.Vars["id"])
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Events     []models.ChannelEvent `json:"events"`
+		TotalCount int                   `json:"total"`
+		Related    struct {
+			Users map[string]models.User `json:"users"`
+		} `json:"related"`
+	}{}
+
+	ctx.r.ParseForm()
+	pager := storage.NewPager(ctx.r.Form, 1000)
+	if response.Events, response.TotalCount, err = ctx.db.Channels().Events(channel.ID, pager); err != nil {
+		return errwrap.Wrap(err, "Error selecting channel events")
+	}
+
+	var userIDs []string
+	for _, e := range response.Events {
+		if e.UserID != "" {
+			userIDs = append(userIDs, e.UserID)
+		}
+	}
+	if response.Related.Users, err = usersByID(ctx, userIDs); err != nil {
+		return err
+	}
+
+	return ctx.Write(response)
+}
+
+// teamChannel loads a channel visible in team, by ID. Channels of the other
+// teams archived here aren't found.
+func teamChannel(ctx *Context, team *models.Team, id string) (*models.Channel, error) {
+	if ok, err := ctx.db.Channels().InTeam(id, team.ID); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrNotFound
+	}
+
+	channel, err := ctx.db.Channels().Get(id)
+	if err == storage.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return channel, err
+}
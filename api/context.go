@@ -6,10 +6,13 @@ import (
 	"strings"
 
 	"github.com/ashb/slackarchive/api/errors"
-	"github.com/go-pg/pg/orm"
+	"github.com/ashb/slackarchive/api/handlers"
+	"github.com/ashb/slackarchive/storage"
+	"github.com/ashb/slackarchive/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions" // /tree/ptrs
+	"github.com/sirupsen/logrus"
 )
 
 func init() {
@@ -18,22 +21,35 @@ func init() {
 type AfterFunc func()
 
 type Context struct {
-	db orm.DB
+	db storage.Store
 
 	w           http.ResponseWriter
 	r           *http.Request
 	afterFn     AfterFunc
 	Vars        map[string]string
 	bodyWritten bool
-	store       *sessions.CookieStore
+	// status is the response's status code when it isn't 200
+	status int
+	store  *sessions.CookieStore
+	// RequestID identifies the request in the logs and the X-Request-ID
+	// response header
+	RequestID string
+	log       *logrus.Entry
 }
 
 type ContextFunc func(*Context) error
 
 func (api *api) ContextHandlerFunc(h ContextFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				handlers.SetRoute(r, tpl)
+			}
+		}
+
+		requestID := handlers.RequestID(r)
 		ctx := Context{
-			db: api.session,
+			db: api.db.WithContext(r.Context()),
 
 			r: r,
 			w: w,
@@ -42,25 +58,32 @@ func (api *api) ContextHandlerFunc(h ContextFunc) http.HandlerFunc {
 			store:       api.store,
 			bodyWritten: false,
 			Vars:        mux.Vars(r),
+			RequestID:   requestID,
+			log:         log.WithField("request_id", requestID).WithFields(tracing.LogFields(r.Context())),
 		}
 
 		var err error
 		defer func() {
+			defer api.audit(&ctx)
+
 			if err == nil {
 				if ctx.bodyWritten {
 				} else {
+					ctx.status = http.StatusNoContent
 					w.WriteHeader(http.StatusNoContent)
 				}
 				return
 			}
 
-			log.Error(err.Error())
+			ctx.log.Error(err)
 
 			switch err.(type) {
 			case errors.APIError:
+				ctx.status = err.(errors.APIError).Code()
 				w.WriteHeader(err.(errors.APIError).Code())
 				json.NewEncoder(w).Encode(err)
 			default:
+				ctx.status = 500
 				http.Error(w, err.Error(), 500)
 			}
 		}()
@@ -70,7 +93,9 @@ func (api *api) ContextHandlerFunc(h ContextFunc) http.HandlerFunc {
 			return
 		}()
 
-		err = h(&ctx)
+		if err = checkCSRF(r); err == nil {
+			err = h(&ctx)
+		}
 		return
 	}
 }
@@ -108,9 +133,21 @@ func (ctx *Context) Read(o interface{}) error {
 	return err
 }
 
+func (ctx *Context) Redirect(url string) {
+	http.Redirect(ctx.w, ctx.r, url, http.StatusFound)
+	ctx.status = http.StatusFound
+	ctx.bodyWritten = true
+}
+
 func (ctx *Context) Write(o interface{}) error {
+	return ctx.WriteStatus(http.StatusOK, o)
+}
+
+// WriteStatus writes o as the response with another status than 200.
+func (ctx *Context) WriteStatus(code int, o interface{}) error {
 	ctx.w.Header().Add("Content-Type", "application/json")
-	ctx.w.WriteHeader(http.StatusOK)
+	ctx.w.WriteHeader(code)
+	ctx.status = code
 	ctx.bodyWritten = true
 	err := json.NewEncoder(ctx.w).Encode(o)
 	return err
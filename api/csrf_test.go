@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckCSRF(t *testing.T) {
+	tests := []struct {
+		method      string
+		contentType string
+		header      string
+		ok          bool
+	}{
+		{"GET", "", "", true},
+		{"POST", "application/json", "", true},
+		{"POST", "application/json; charset=utf-8", "", true},
+		{"DELETE", "", "XMLHttpRequest", true},
+		{"POST", "text/plain", "", false},
+		{"POST", "application/x-www-form-urlencoded", "", false},
+		{"POST", "multipart/form-data; boundary=x", "", false},
+		{"PUT", "", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/v1/admin/holds", nil)
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.header != "" {
+			r.Header.Set(csrfHeader, tt.header)
+		}
+		if err := checkCSRF(r); (err == nil) != tt.ok {
+			t.Errorf("%s %q %q: got %v", tt.method, tt.contentType, tt.header, err)
+		}
+	}
+}
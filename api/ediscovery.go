@@ -0,0 +1,90 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	errwrap "github.com/pkg/errors"
+
+	apierrors "github.com/ashb/slackarchive/api/errors"
+	"github.com/ashb/slackarchive/ediscovery"
+)
+
+// eDiscoveryHandler exports the messages posted by any of user_id in any
+// of channel_id, matching keywords between from and to, as a zip of EML or,
+// with format=html, printable HTML documents, a load file and a manifest.
+// The parameters are in the query string, so the audit log has them.
+func (api *api) eDiscoveryHandler(ctx *Context) error {
+	adminID, err := api.admin(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.r.ParseForm()
+
+	req := ediscovery.Request{Format: ctx.r.Form.Get("format")}
+	req.UserIDs = ctx.r.Form["user_id"]
+	req.ChannelIDs = ctx.r.Form["channel_id"]
+	req.Keywords = ctx.r.Form.Get("keywords")
+
+	verr := &apierrors.ValidationError{}
+	if req.From, err = ediscovery.ParseDate(ctx.r.Form.Get("from"), false); err != nil {
+		verr.Add("from", "invalid", err.Error())
+	}
+	if req.To, err = ediscovery.ParseDate(ctx.r.Form.Get("to"), true); err != nil {
+		verr.Add("to", "invalid", err.Error())
+	}
+	if len(req.UserIDs) == 0 && len(req.ChannelIDs) == 0 && req.Keywords == "" {
+		verr.Add("user_id", "required", "Select messages by users, channels or keywords")
+	}
+	if verr.Valid() {
+		if err := req.Validate(); err != nil {
+			verr.Add("query", "invalid", err.Error())
+		}
+	}
+	if !verr.Valid() {
+		return verr
+	}
+
+	// Build the zip first, so a failed export is an error response rather
+	// than a download that looks complete
+	tmp, err := ioutil.TempFile("", "ediscovery-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+	manifest, err := ediscovery.Export(ctx.db, req, adminID, ediscovery.Zip{Writer: zw})
+	if err != nil {
+		return errwrap.Wrapf(err, "Error exporting eDiscovery request by %s", adminID)
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	ctx.log.Infof("eDiscovery export by %s: %d messages", adminID, manifest.Messages)
+
+	ctx.w.Header().Set("Content-Type", "application/zip")
+	ctx.w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	ctx.w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="ediscovery-%s.zip"`, time.Now().UTC().Format("20060102-150405")))
+	ctx.bodyWritten = true
+
+	if _, err := io.Copy(ctx.w, tmp); err != nil {
+		// Too late for an error response
+		ctx.log.Errorf("Error sending eDiscovery export: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/ashb/slackarchive/blobs"
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/render"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// maxAliasDepth stops aliases of aliases going round in circles
+const maxAliasDepth = 5
+
+func emojiURL(name string) string {
+	return "/v1/emoji/" + url.PathEscape(name)
+}
+
+// resolveEmoji follows the aliases of the emoji called name to the custom
+// emoji with an image, or the name of a standard emoji. get looks them up.
+func resolveEmoji(name string, get func(name string) (*models.Emoji, error)) (*models.Emoji, string, error) {
+	for i := 0; i < maxAliasDepth; i++ {
+		e, err := get(name)
+		if err == storage.ErrNotFound {
+			// An alias for a standard emoji
+			return nil, name, nil
+		} else if err != nil {
+			return nil, "", err
+		}
+		if e.AliasFor == "" {
+			return e, "", nil
+		}
+		name = e.AliasFor
+	}
+	return nil, "", storage.ErrNotFound
+}
+
+// emojiListHandler lists the team's custom emoji, deleted ones too so old
+// messages can still show them.
+func (api *api) emojiListHandler(ctx *Context) error {
+	team, err := api.Team(ctx)
+	if err != nil {
+		return err
+	}
+
+	list, err := ctx.db.Emoji().List(team.ID)
+	if err != nil {
+		return err
+	}
+
+	type EmojiResponse struct {
+		models.Emoji
+		// URL of the image in the archive, or in Slack until we have it
+		URL string `json:"url,omitempty"`
+	}
+
+	response := struct {
+		Emoji []EmojiResponse `json:"emoji"`
+	}{
+		Emoji: make([]EmojiResponse, 0, len(list)),
+	}
+	for _, e := range list {
+		er := EmojiResponse{Emoji: e}
+		if e.BlobKey != "" {
+			er.URL = emojiURL(e.Name)
+		} else if render.SafeURL(e.URL) {
+			er.URL = e.URL
+		}
+		response.Emoji = append(response.Emoji, er)
+	}
+
+	return ctx.Write(response)
+}
+
+// emojiHandler serves the image of a custom emoji, following aliases.
+func (api *api) emojiHandler(ctx *Context) error {
+	team, err := api.Team(ctx)
+	if err != nil {
+		return err
+	}
+
+	e, _, err := resolveEmoji(ctx.Vars["name"], func(name string) (*models.Emoji, error) {
+		return ctx.db.Emoji().Get(team.ID, name)
+	})
+	if err == storage.ErrNotFound || (err == nil && (e == nil || e.BlobKey == "")) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	blob, err := api.blobs.Open(e.BlobKey)
+	if err == blobs.ErrNotFound {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	if e.ContentType != "" {
+		ctx.w.Header().Set("Content-Type", e.ContentType)
+	}
+	// Blobs never change, but the emoji a name points at can
+	ctx.w.Header().Set("Cache-Control", "public, max-age=3600")
+	ctx.w.Header().Set("ETag", `"`+e.BlobKey+`"`)
+	ctx.bodyWritten = true
+
+	if rs, ok := blob.(io.ReadSeeker); ok {
+		http.ServeContent(ctx.w, ctx.r, "", e.UpdatedAt, rs)
+		return nil
+	}
+	_, err = io.Copy(ctx.w, blob)
+	return err
+}
@@ -18,6 +18,7 @@ var (
 	ErrPaymentChecksumFailed         error = errors.New("payment_checksumfailed", "Payment checksum failed", 404)
 	ErrNotAuthorized                 error = errors.New("authentication_failed", "Authentication failed", http.StatusUnauthorized)
 	ErrNotFound                            = errors.New("not-found", "Not authorized", 404)
+	ErrForbidden                           = errors.New("forbidden", "Only admins can do this", http.StatusForbidden)
 	ErrValidationFailed                    = errors.New("validation-failed", "Validation errors", 417)
 	ErrTimeout                             = errors.New("Timeout", "timeout", 500)
 	ErrUnknownMethod                       = errors.New("Method not supported", "method-not-supported", 500)
@@ -35,4 +36,14 @@ var (
 	ErrDatabaseAlreadyExists               = errors.New("already-exists", "Already exists", 409)
 	ErrDatabaseOther                       = errors.New("other", "Other", 500)
 	ErrCertificateVerificationFailed       = errors.New("certificate-verification-failed", "Certificate verification failed", 417)
+	ErrInvalidSearchMode                   = errors.New("invalid-search-mode", "Search mode must be exact or fuzzy", http.StatusBadRequest)
+	ErrInvalidCursor                       = errors.New("invalid-cursor", "Invalid cursor, pass one of next or prev as after or before", http.StatusBadRequest)
+	ErrCursorRelevance                     = errors.New("cursor-relevance", "Results sorted by relevance can't be paged by cursor", http.StatusBadRequest)
+	ErrInvalidFormat                       = errors.New("invalid-format", "Format must be html or text", http.StatusBadRequest)
+	ErrInvalidReportFormat                 = errors.New("invalid-format", "Format must be json or csv", http.StatusBadRequest)
+	ErrInvalidAuditFormat                  = errors.New("invalid-format", "Format must be json or jsonl", http.StatusBadRequest)
+	ErrCSRF                                = errors.New("csrf", "Send a JSON body or an X-Requested-With header", http.StatusForbidden)
+	ErrSearchTimeout                       = errors.New("search-timeout", "The search took too long, try a more specific regular expression", http.StatusBadRequest)
+	ErrSearchRedacted                      = errors.New("search-redacted", "Searching for redacted text, or by regular expression, isn't allowed", http.StatusBadRequest)
+	ErrTeamNotArchived                     = errors.New("team-not-archived", "Your team isn't archived here", http.StatusForbidden)
 )
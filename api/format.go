@@ -0,0 +1,113 @@
+package api
+
+import (
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/render"
+	"github.com/ashb/slackarchive/storage"
+	"github.com/slack-go/slack"
+)
+
+// resolver names mentions from the users already loaded for a response,
+// looking up the rest once each. The team's emoji are loaded the first time
+// a message uses one we don't know.
+type resolver struct {
+	ctx      *Context
+	teamID   string
+	users    map[string]models.User
+	channels map[string]*models.Channel
+	emoji    map[string]*models.Emoji
+}
+
+func newResolver(ctx *Context, teamID string, related map[string]models.User) *resolver {
+	users := make(map[string]models.User, len(related))
+	for id, user := range related {
+		users[id] = user
+	}
+	return &resolver{ctx: ctx, teamID: teamID, users: users, channels: map[string]*models.Channel{}}
+}
+
+func (r *resolver) UserName(id string) (string, bool) {
+	user, ok := r.users[id]
+	if !ok {
+		if u, err := r.ctx.db.Users().Get(id); err == nil {
+			user = *u
+		} else if err != storage.ErrNotFound {
+			r.ctx.log.Errorf("Error loading user %s: %s", id, err)
+		}
+		r.users[id] = user
+	}
+	return user.Name, user.Name != ""
+}
+
+func (r *resolver) ChannelName(id string) (string, bool) {
+	channel, ok := r.channels[id]
+	if !ok {
+		var err error
+		if channel, err = r.ctx.db.Channels().Get(id); err != nil && err != storage.ErrNotFound {
+			r.ctx.log.Errorf("Error loading channel %s: %s", id, err)
+		}
+		r.channels[id] = channel
+	}
+	if channel == nil {
+		return "", false
+	}
+	return channel.Name, true
+}
+
+func (r *resolver) Emoji(name string) (string, string, bool) {
+	if r.emoji == nil {
+		r.emoji = map[string]*models.Emoji{}
+		list, err := r.ctx.db.Emoji().List(r.teamID)
+		if err != nil {
+			r.ctx.log.Errorf("Error loading emoji of team %s: %s", r.teamID, err)
+		}
+		for i := range list {
+			r.emoji[list[i].Name] = &list[i]
+		}
+	}
+	if _, ok := r.emoji[name]; !ok {
+		return "", "", false
+	}
+
+	e, aliasFor, err := resolveEmoji(name, func(name string) (*models.Emoji, error) {
+		if e, ok := r.emoji[name]; ok {
+			return e, nil
+		}
+		return nil, storage.ErrNotFound
+	})
+	switch {
+	case err != nil:
+		return "", "", false
+	case e == nil:
+		return "", aliasFor, true
+	case e.BlobKey != "":
+		return emojiURL(e.Name), "", true
+	case e.URL != "":
+		return e.URL, "", true
+	}
+	return "", "", false
+}
+
+// renderMessages renders msgs as asked for by the format parameter, html or
+// text, in the same order. Without one there's nothing to render.
+func renderMessages(ctx *Context, teamID string, msgs []slack.Msg, users map[string]models.User) ([]string, error) {
+	format := ctx.r.FormValue("format")
+	switch format {
+	case "":
+		return nil, nil
+	case "html", "text":
+	default:
+		return nil, ErrInvalidFormat
+	}
+
+	renderer := render.New(newResolver(ctx, teamID, users))
+	rendered := make([]string, len(msgs))
+	for i := range msgs {
+		if format == "html" {
+			rendered[i] = renderer.HTML(&msgs[i])
+		} else {
+			rendered[i] = renderer.Text(&msgs[i])
+		}
+	}
+	return rendered, nil
+}
@@ -2,12 +2,22 @@ package handlers
 
 import (
 	"bufio"
-	"log"
+	"context"
 	"net"
 	"net/http"
 	"time"
+
+	"github.com/sirupsen/logrus"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ashb/slackarchive/logs"
+	"github.com/ashb/slackarchive/metrics"
+	"github.com/ashb/slackarchive/tracing"
 )
 
+var log = logs.New("http")
+
 type statusLoggingResponseWriter struct {
 	status int
 	http.ResponseWriter
@@ -22,11 +32,40 @@ func (w *statusLoggingResponseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+type routeKey struct{}
+
+// SetRoute names the route a request matched, for its metrics and trace.
+// The router only knows once LoggingHandler has passed the request on.
+func SetRoute(r *http.Request, route string) {
+	if p, ok := r.Context().Value(routeKey{}).(*string); ok {
+		*p = route
+	}
+	span := trace.SpanFromContext(r.Context())
+	span.SetName(r.Method + " " + route)
+	span.SetAttributes(semconv.HTTPRouteKey.String(route))
+}
+
 func LoggingHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		began := time.Now()
+		route := new(string)
+		req = req.WithContext(context.WithValue(req.Context(), routeKey{}, route))
 		slrw := &statusLoggingResponseWriter{-1, w}
 		h.ServeHTTP(slrw, req)
-		log.Printf("%s\t%s\t%s\t%s\t%d", req.RemoteAddr, req.Method, req.URL, time.Since(began), slrw.status)
+		elapsed := time.Since(began)
+		status := slrw.status
+		if status == -1 {
+			// Nothing called WriteHeader, so net/http sent a 200
+			status = http.StatusOK
+		}
+		log.WithFields(logrus.Fields{
+			"request_id":  RequestID(req),
+			"remote_addr": req.RemoteAddr,
+			"method":      req.Method,
+			"url":         req.URL.String(),
+			"status":      status,
+			"duration_ms": float64(elapsed) / float64(time.Millisecond),
+		}).WithFields(tracing.LogFields(req.Context())).Info("request")
+		metrics.HTTPRequest(*route, req.Method, status, elapsed)
 	})
 }
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 	"runtime"
 )
@@ -10,10 +9,11 @@ func RecoverHandler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				trace := make([]byte, 1024)
-				count := runtime.Stack(trace, true)
-				fmt.Printf("Recover from panic: %s\n", err)
-				fmt.Printf("Stack of %d bytes: %s\n", count, trace)
+				trace := make([]byte, 64<<10)
+				count := runtime.Stack(trace, false)
+				log.WithField("request_id", RequestID(r)).
+					WithField("stack", string(trace[:count])).
+					Errorf("Recover from panic: %s", err)
 				http.Error(w, http.StatusText(500), 500) // What we had before
 				return
 			}
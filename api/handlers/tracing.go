@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ashb/slackarchive/tracing"
+)
+
+// TracingHandler traces every request, continuing the trace of the
+// caller's traceparent header. SetRoute names the span after the route.
+func TracingHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracing.Tracer().Start(ctx, "HTTP "+req.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(serverAttributes(req)...))
+		defer span.End()
+		span.SetAttributes(attribute.String("http.request_id", RequestID(req)))
+
+		srw := &statusLoggingResponseWriter{-1, w}
+		h.ServeHTTP(srw, req.WithContext(ctx))
+
+		status := srw.status
+		if status == -1 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+	})
+}
+
+// serverAttributes are the semantic convention attributes of req, with
+// http.target cut down to the path. Query strings have search terms and
+// OAuth codes in them, which aren't for the collector.
+func serverAttributes(req *http.Request) []attribute.KeyValue {
+	attrs := semconv.HTTPServerAttributesFromHTTPRequest("slackarchive", "", req)
+	for i := range attrs {
+		if attrs[i].Key == semconv.HTTPTargetKey {
+			attrs[i] = semconv.HTTPTargetKey.String(req.URL.EscapedPath())
+		}
+	}
+	return attrs
+}
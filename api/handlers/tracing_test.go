@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerAttributes(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/oauth/callback?code=secret-code&state=secret-state", nil)
+
+	var target string
+	for _, attr := range serverAttributes(req) {
+		if strings.Contains(attr.Value.Emit(), "secret") {
+			t.Errorf("%s = %q has the query string", attr.Key, attr.Value.Emit())
+		}
+		if attr.Key == "http.target" {
+			target = attr.Value.AsString()
+		}
+	}
+	if target != "/v1/oauth/callback" {
+		t.Errorf("http.target = %q, want /v1/oauth/callback", target)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/bot"
+)
+
+// tokenCheckInterval is how long readiness checks reuse the result of
+// asking Slack whether the bot tokens still work.
+const tokenCheckInterval = time.Minute
+
+// Syncer is the bot, as far as readiness is concerned.
+type Syncer interface {
+	SyncStatus() []bot.SyncStatus
+}
+
+type check struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func (c *check) fail(err error) {
+	c.OK = false
+	c.Error = err.Error()
+}
+
+type migrationsCheck struct {
+	check
+	Version int64 `json:"version"`
+	Latest  int64 `json:"latest"`
+}
+
+type tokenCheck struct {
+	check
+	// Index is the token's position under bot_tokens in the config
+	Index  int    `json:"index"`
+	TeamID string `json:"team_id,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+type syncCheck struct {
+	check
+	bot.SyncStatus
+	Lag float64 `json:"lag_seconds"`
+}
+
+type readiness struct {
+	Ready      bool            `json:"ready"`
+	Database   check           `json:"database"`
+	Migrations migrationsCheck `json:"migrations"`
+	Tokens     []tokenCheck    `json:"tokens"`
+	Syncs      []syncCheck     `json:"syncs"`
+}
+
+// tokenChecks caches the token checks for tokenCheckInterval, so frequent
+// probes don't eat into Slack's rate limits.
+type tokenChecks struct {
+	mu      sync.Mutex
+	checked time.Time
+	checks  []tokenCheck
+}
+
+// healthzHandler tells whether the API is up at all.
+func (api *api) healthzHandler(ctx *Context) error {
+	return ctx.Write(check{OK: true})
+}
+
+// readyzHandler tells whether the archive is working: the database is
+// reachable and migrated, Slack accepts the bot tokens and every team has
+// synced recently. It answers 503 when it isn't.
+func (api *api) readyzHandler(ctx *Context) error {
+	r := readiness{
+		Database:   check{OK: true},
+		Migrations: migrationsCheck{check: check{OK: true}},
+		Tokens:     api.checkTokens(ctx.r.Context()),
+		Syncs:      []syncCheck{},
+	}
+
+	version, latest, err := api.db.SchemaVersion()
+	r.Migrations.Version, r.Migrations.Latest = version, latest
+	if err != nil {
+		r.Database.fail(err)
+		r.Migrations.fail(err)
+	} else if version != latest {
+		r.Migrations.OK = false
+		r.Migrations.Error = "database schema isn't up to date"
+	}
+
+	if api.syncer != nil {
+		threshold := time.Duration(api.config.SyncLagMinute) * time.Minute
+		for _, s := range api.syncer.SyncStatus() {
+			c := syncCheck{check: check{OK: true}, SyncStatus: s}
+			// Teams that haven't finished syncing yet get the threshold
+			// from when the bot started archiving them
+			since := s.Started
+			if s.LastSync != nil {
+				since = *s.LastSync
+			}
+			lag := time.Since(since)
+			c.Lag = lag.Seconds()
+			if lag > threshold {
+				c.OK = false
+				c.Error = "no successful sync for " + lag.Round(time.Second).String()
+			}
+			r.Syncs = append(r.Syncs, c)
+		}
+	}
+
+	r.Ready = r.Database.OK && r.Migrations.OK
+	for _, c := range r.Tokens {
+		r.Ready = r.Ready && c.OK
+	}
+	for _, c := range r.Syncs {
+		r.Ready = r.Ready && c.OK
+	}
+
+	if !r.Ready {
+		return ctx.WriteStatus(http.StatusServiceUnavailable, r)
+	}
+	return ctx.Write(r)
+}
+
+// checkTokens asks Slack whether each of the configured bot tokens works.
+func (api *api) checkTokens(ctx context.Context) []tokenCheck {
+	api.tokens.mu.Lock()
+	defer api.tokens.mu.Unlock()
+	if api.tokens.checks != nil && time.Since(api.tokens.checked) < tokenCheckInterval {
+		return api.tokens.checks
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	checks := make([]tokenCheck, len(api.config.BotTokens))
+	for i, token := range api.config.BotTokens {
+		checks[i] = tokenCheck{check: check{OK: true}, Index: i}
+		// The OAuth token does the syncing and the bot token the real time
+		// API, so both have to work
+		for _, t := range []string{token.OAuthToken, token.BotToken} {
+			if t == "" {
+				continue
+			}
+			resp, err := slack.New(t).AuthTestContext(ctx)
+			if err != nil {
+				checks[i].fail(err)
+				break
+			}
+			checks[i].TeamID, checks[i].UserID = resp.TeamID, resp.UserID
+		}
+	}
+
+	api.tokens.checks, api.tokens.checked = checks, time.Now()
+	return checks
+}
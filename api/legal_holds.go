@@ -0,0 +1,291 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	errwrap "github.com/pkg/errors"
+
+	apierrors "github.com/ashb/slackarchive/api/errors"
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type legalHoldInput struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	UserIDs     []string   `json:"user_ids"`
+	ChannelIDs  []string   `json:"channel_ids"`
+	From        *time.Time `json:"from"`
+	To          *time.Time `json:"to"`
+}
+
+func (in *legalHoldInput) validate() error {
+	verr := &apierrors.ValidationError{}
+	if in.Name == "" {
+		verr.Add("name", "required", "Name is required")
+	}
+	if len(in.UserIDs) == 0 && len(in.ChannelIDs) == 0 {
+		verr.Add("user_ids", "required", "A hold needs users, channels or both")
+	}
+	if in.From != nil && in.To != nil && in.To.Before(*in.From) {
+		verr.Add("to", "invalid", "To must be after from")
+	}
+	if !verr.Valid() {
+		return verr
+	}
+	return nil
+}
+
+// legalHold loads the hold in the URL, for admins only.
+func (api *api) legalHold(ctx *Context) (*models.LegalHold, string, error) {
+	adminID, err := api.admin(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := strconv.ParseInt(ctx.Vars["id"], 10, 64)
+	if err != nil {
+		return nil, "", ErrNotFound
+	}
+
+	hold, err := ctx.db.LegalHolds().Get(id)
+	if err == storage.ErrNotFound {
+		return nil, "", ErrNotFound
+	}
+	return hold, adminID, err
+}
+
+// legalHoldsHandler lists the active legal holds, and released ones too
+// with released=1.
+func (api *api) legalHoldsHandler(ctx *Context) error {
+	if _, err := api.admin(ctx); err != nil {
+		return err
+	}
+	ctx.r.ParseForm()
+
+	holds, err := ctx.db.LegalHolds().List(ctx.r.Form.Get("released") == "1")
+	if err != nil {
+		return errwrap.Wrap(err, "Error selecting legal holds")
+	}
+	return ctx.Write(holds)
+}
+
+func (api *api) createLegalHoldHandler(ctx *Context) error {
+	adminID, err := api.admin(ctx)
+	if err != nil {
+		return err
+	}
+
+	var in legalHoldInput
+	if err := ctx.Read(&in); err != nil {
+		return err
+	}
+	if err := in.validate(); err != nil {
+		return err
+	}
+
+	hold := &models.LegalHold{
+		Name:        in.Name,
+		Description: in.Description,
+		UserIDs:     in.UserIDs,
+		ChannelIDs:  in.ChannelIDs,
+		PostedFrom:  in.From,
+		PostedTo:    in.To,
+		CreatedBy:   adminID,
+		CreatedAt:   time.Now(),
+	}
+	if err := ctx.db.LegalHolds().Create(hold); err != nil {
+		return err
+	}
+	ctx.log.Infof("Legal hold %d (%s) created by %s", hold.ID, hold.Name, adminID)
+	return ctx.Write(hold)
+}
+
+func (api *api) legalHoldHandler(ctx *Context) error {
+	hold, _, err := api.legalHold(ctx)
+	if err != nil {
+		return err
+	}
+	return ctx.Write(hold)
+}
+
+// releaseLegalHoldHandler lifts a hold. Messages deleted in Slack while it
+// was active are removed, and the rest are purged like any others from then
+// on, unless another hold covers them.
+func (api *api) releaseLegalHoldHandler(ctx *Context) error {
+	hold, adminID, err := api.legalHold(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.db.LegalHolds().Release(hold.ID, adminID, time.Now()); err == storage.ErrNotFound {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	ctx.log.Infof("Legal hold %d (%s) released by %s", hold.ID, hold.Name, adminID)
+
+	// Messages deleted in Slack were only kept for the hold
+	holds, err := ctx.db.LegalHolds().List(false)
+	if err != nil {
+		return err
+	}
+	if n, err := ctx.db.Purges().Deleted(holds); err != nil {
+		return errwrap.Wrap(err, "Error removing deleted messages")
+	} else if n > 0 {
+		ctx.log.Infof("Removed %d messages deleted while under legal hold %d", n, hold.ID)
+	}
+
+	if hold, err = ctx.db.LegalHolds().Get(hold.ID); err != nil {
+		return err
+	}
+	return ctx.Write(hold)
+}
+
+// legalHoldReportCounts counts the messages a hold covers.
+type legalHoldReportCounts struct {
+	Messages  int `json:"messages"`
+	Deleted   int `json:"deleted"`
+	Edited    int `json:"edited"`
+	Revisions int `json:"revisions"`
+}
+
+func (c *legalHoldReportCounts) add(m *storage.HeldMessage) {
+	c.Messages++
+	if m.DeletedAt != nil {
+		c.Deleted++
+	}
+	if m.Revisions > 0 {
+		c.Edited++
+	}
+	c.Revisions += m.Revisions
+}
+
+// legalHoldReportHandler reports what a hold preserves: how many messages
+// per channel, how many of them were deleted or edited in Slack since.
+// format=csv exports every held message instead.
+func (api *api) legalHoldReportHandler(ctx *Context) error {
+	hold, adminID, err := api.legalHold(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.r.ParseForm()
+
+	switch ctx.r.Form.Get("format") {
+	case "", "json":
+	case "csv":
+		return api.legalHoldCSV(ctx, hold)
+	default:
+		return ErrInvalidReportFormat
+	}
+
+	type channelCounts struct {
+		ChannelID string `json:"channel_id"`
+		Name      string `json:"name"`
+		legalHoldReportCounts
+	}
+	response := struct {
+		Hold        *models.LegalHold     `json:"hold"`
+		GeneratedAt time.Time             `json:"generated_at"`
+		GeneratedBy string                `json:"generated_by"`
+		Totals      legalHoldReportCounts `json:"totals"`
+		Channels    []*channelCounts      `json:"channels"`
+	}{
+		Hold:        hold,
+		GeneratedAt: time.Now(),
+		GeneratedBy: adminID,
+		Channels:    []*channelCounts{},
+	}
+
+	byChannel := map[string]*channelCounts{}
+	err = eachHeldMessage(ctx, hold, func(m *storage.HeldMessage) error {
+		c, ok := byChannel[m.ChannelID]
+		if !ok {
+			c = &channelCounts{ChannelID: m.ChannelID, Name: channelName(ctx, m.ChannelID)}
+			byChannel[m.ChannelID] = c
+			response.Channels = append(response.Channels, c)
+		}
+		c.add(m)
+		response.Totals.add(m)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.Write(response)
+}
+
+// legalHoldCSV writes every message a hold covers as CSV, oldest first.
+func (api *api) legalHoldCSV(ctx *Context, hold *models.LegalHold) error {
+	ctx.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	ctx.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="legal-hold-%d.csv"`, hold.ID))
+	ctx.bodyWritten = true
+
+	w := csv.NewWriter(ctx.w)
+	w.Write([]string{"channel_id", "channel_name", "user_id", "user_name", "timestamp", "thread_timestamp", "deleted_at", "revisions", "text"})
+
+	channels := map[string]string{}
+	users := map[string]string{}
+	err := eachHeldMessage(ctx, hold, func(m *storage.HeldMessage) error {
+		if _, ok := channels[m.ChannelID]; !ok {
+			channels[m.ChannelID] = channelName(ctx, m.ChannelID)
+		}
+		if _, ok := users[m.UserID]; !ok {
+			users[m.UserID] = ""
+			if u, err := ctx.db.Users().Get(m.UserID); err == nil {
+				users[m.UserID] = u.Name
+			}
+		}
+
+		var threadTs, deletedAt, text string
+		if m.ThreadTimestamp != nil {
+			threadTs = models.TimeToTimestamp(*m.ThreadTimestamp)
+		}
+		if m.DeletedAt != nil {
+			deletedAt = m.DeletedAt.UTC().Format(time.RFC3339)
+		}
+		if m.Msg != nil {
+			text = m.Msg.Text
+		}
+		return w.Write([]string{
+			m.ChannelID, channels[m.ChannelID], m.UserID, users[m.UserID],
+			models.TimeToTimestamp(*m.Timestamp), threadTs, deletedAt, strconv.Itoa(m.Revisions), text,
+		})
+	})
+	if err != nil {
+		// Too late for an error response, leave the export truncated
+		ctx.log.Errorf("Error exporting legal hold %d: %s", hold.ID, err)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// eachHeldMessage calls fn with every message hold covers, oldest first.
+func eachHeldMessage(ctx *Context, hold *models.LegalHold, fn func(m *storage.HeldMessage) error) error {
+	pager := storage.Pager{Limit: 1000}
+	for {
+		messages, err := ctx.db.LegalHolds().Messages(hold, pager)
+		if err != nil {
+			return errwrap.Wrap(err, "Error selecting held messages")
+		}
+		for i := range messages {
+			if err := fn(&messages[i]); err != nil {
+				return err
+			}
+		}
+		if len(messages) < pager.Limit {
+			return nil
+		}
+		pager.Offset += len(messages)
+	}
+}
+
+func channelName(ctx *Context, id string) string {
+	if c, err := ctx.db.Channels().Get(id); err == nil {
+		return c.Name
+	}
+	return ""
+}
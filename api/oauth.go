@@ -1,12 +1,12 @@
 package api
 
 import (
-	"crypto/sha1"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"strings"
-
-	"github.com/ashb/slackarchive/utils"
+	"net/http"
 
 	"github.com/gorilla/sessions"
 	"github.com/slack-go/slack"
@@ -33,10 +33,8 @@ func (cookie *OAuthCookie) Get() error {
 	var err error
 	cookie.s, err = cookie.ctx.store.Get(cookie.ctx.r, "oauth")
 
-	cookie.s.Options = &sessions.Options{
-		Path:     "/",
-		HttpOnly: true,
-	}
+	// Long enough to sign in with Slack
+	cookie.s.Options = cookieOptions(cookie.ctx.r, 15*60)
 
 	return err
 }
@@ -78,17 +76,38 @@ func (api *api) validateOAuthResponse(ctx *Context) error {
 		return errors.New("Slack oauth request denied.")
 	}
 
-	stateParts := strings.Split(state, "-")
-	if len(stateParts) != 2 {
+	// The state is only good for one sign in, started from this browser
+	cookie, _ := GetOAuthCookie(ctx)
+	expected := cookie.State()
+	cookie.Delete()
+	if expected == "" || subtle.ConstantTimeCompare([]byte(state), []byte(expected)) != 1 {
 		return errors.New("Invalid state.")
 	}
 
-	hash := sha1.Sum([]byte(fmt.Sprintf("%saiN4gea6lau6", stateParts[0])))
-	if fmt.Sprintf("%x", hash) != stateParts[1] {
-		return errors.New("Invalid state.")
+	return nil
+}
+
+// archivedTeam tells whether people of a team may sign in, which they may
+// when the archive is set up for or has archived their team.
+func (api *api) archivedTeam(ctx *Context, teamID string) (bool, error) {
+	for _, t := range api.config.BotTokens {
+		for _, id := range t.Teams {
+			if id == teamID {
+				return true, nil
+			}
+		}
 	}
 
-	return nil
+	teams, err := ctx.db.Teams().List()
+	if err != nil {
+		return false, err
+	}
+	for _, t := range teams {
+		if t.ID == teamID {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (api *api) oAuthCallbackHandler(ctx *Context) error {
@@ -98,7 +117,7 @@ func (api *api) oAuthCallbackHandler(ctx *Context) error {
 
 	code := ctx.r.FormValue("code")
 
-	client := api.config.NewSlackOAuthClient("")
+	client := api.config.NewSlackOAuthClient(oAuthRedirectUri(ctx.r))
 	accessToken, err := client.RedeemCode(code)
 	if err != nil {
 		return err
@@ -111,22 +130,51 @@ func (api *api) oAuthCallbackHandler(ctx *Context) error {
 		return err
 	}
 
-	log.Info("%#v", response)
+	if ok, err := api.archivedTeam(ctx, response.TeamID); err != nil {
+		return err
+	} else if !ok {
+		ctx.log.Warnf("Refused sign in of user %s of team %s, which isn't archived", response.UserID, response.TeamID)
+		return ErrTeamNotArchived
+	}
 
-	return ctx.Write(struct {
-	}{})
+	ctx.log.Infof("Signed in user %s of team %s", response.UserID, response.TeamID)
+
+	cookie, _ := GetSessionCookie(ctx)
+	cookie.SetUserID(response.UserID)
+	cookie.Save()
+
+	ctx.Redirect("/")
+	return nil
 }
 
-func (api *api) oAuthLoginHandler(ctx *Context) error {
-	state := utils.RandSeq(12)
+// baseURL is the address the archive was reached at, behind a proxy or not.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
 
-	redirectUri := "http://slackarchive.io/oauth/callback"
+// oAuthRedirectUri sends people back to the archive they signed in from.
+func oAuthRedirectUri(r *http.Request) string {
+	return baseURL(r) + "/v1/oauth/callback"
+}
+
+func (api *api) oAuthLoginHandler(ctx *Context) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	state := hex.EncodeToString(b)
 
-	client := api.config.NewSlackOAuthClient(redirectUri)
+	cookie, _ := GetOAuthCookie(ctx)
+	cookie.SetState(state)
+	cookie.Save()
 
-	hash := sha1.Sum([]byte(fmt.Sprintf("%saiN4gea6lau6", state)))
+	client := api.config.NewSlackOAuthClient(oAuthRedirectUri(ctx.r))
 
-	url := client.LoginUrl(fmt.Sprintf("%s-%x", state, hash))
+	url := client.LoginUrl(state)
 
 	ctx.Write(struct {
 		Url string `json:"url"`
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	apierrors "github.com/ashb/slackarchive/api/errors"
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+	"github.com/slack-go/slack"
+)
+
+const (
+	defaultContextSize = 10
+	maxContextSize     = 100
+)
+
+// messageContext loads up to size messages posted in the channel either
+// side of ts, oldest first.
+func messageContext(ctx *Context, teamID, channelID string, ts time.Time, size int) (before []models.Message, after []models.Message, err error) {
+	to := ts.Add(-time.Microsecond)
+	result, err := ctx.db.Messages().Search(storage.MessageQuery{
+		TeamID:    teamID,
+		ChannelID: channelID,
+		To:        &to,
+		NoCount:   true,
+		Pager:     storage.Pager{Limit: size},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	before = result.Messages
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+
+	from := ts.Add(time.Microsecond)
+	if result, err = ctx.db.Messages().Search(storage.MessageQuery{
+		TeamID:    teamID,
+		ChannelID: channelID,
+		From:      &from,
+		Ascending: true,
+		NoCount:   true,
+		Pager:     storage.Pager{Limit: size},
+	}); err != nil {
+		return nil, nil, err
+	}
+	return before, result.Messages, nil
+}
+
+func slackMsgs(messages []models.Message) []slack.Msg {
+	msgs := make([]slack.Msg, 0, len(messages))
+	for _, message := range messages {
+		msgs = append(msgs, *message.Msg)
+	}
+	return msgs
+}
+
+// messageHandler returns a single message with the ones posted around it in
+// the channel. The timestamp can be given as in Slack permalinks.
+func (api *api) messageHandler(ctx *Context) error {
+	team, err := api.Team(ctx)
+	if err != nil {
+		return err
+	}
+
+	channelID := ctx.Vars["channel"]
+	ts, err := models.ParsePermalinkTimestamp(ctx.Vars["ts"])
+	if err != nil {
+		return ErrNotFound
+	}
+	t, err := models.TimestampToTime(ts)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	size := defaultContextSize
+	if val := ctx.r.FormValue("context"); val != "" {
+		if size, err = strconv.Atoi(val); err != nil || size < 0 {
+			return apierrors.New("invalid-context", "Context must be a number of messages", http.StatusBadRequest)
+		}
+		if size > maxContextSize {
+			size = maxContextSize
+		}
+	}
+
+	result, err := ctx.db.Messages().Search(storage.MessageQuery{
+		TeamID:    team.ID,
+		ChannelID: channelID,
+		From:      t,
+		To:        t,
+		NoCount:   true,
+		Pager:     storage.Pager{Limit: 1},
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Messages) == 0 {
+		return ErrNotFound
+	}
+	message := result.Messages[0]
+
+	var before, after []models.Message
+	if size > 0 {
+		if before, after, err = messageContext(ctx, team.ID, channelID, *t, size); err != nil {
+			return err
+		}
+	}
+	all := append(append(append([]models.Message{}, before...), message), after...)
+	if err := api.redactMessages(all...); err != nil {
+		return err
+	}
+
+	response := struct {
+		Message        slack.Msg   `json:"message"`
+		Before         []slack.Msg `json:"before"`
+		After          []slack.Msg `json:"after"`
+		Permalink      string      `json:"permalink"`
+		SlackPermalink string      `json:"slack_permalink"`
+		Related        struct {
+			Users map[string]models.User `json:"users"`
+		} `json:"related"`
+	}{
+		Message:        *message.Msg,
+		Before:         slackMsgs(before),
+		After:          slackMsgs(after),
+		Permalink:      baseURL(ctx.r) + models.PermalinkPath(channelID, ts),
+		SlackPermalink: models.SlackPermalink(team.Domain, channelID, ts, message.Msg.ThreadTimestamp),
+	}
+
+	if response.Related.Users, err = relatedUsers(ctx, all); err != nil {
+		return err
+	}
+
+	return ctx.Write(response)
+}
+
+// permalinkHandler maps a Slack permalink to the archive's and back.
+func (api *api) permalinkHandler(ctx *Context) error {
+	team, err := api.Team(ctx)
+	if err != nil {
+		return err
+	}
+
+	channelID, ts, threadTs, err := models.ParsePermalink(ctx.r.FormValue("url"))
+	if err != nil {
+		return apierrors.New("invalid-permalink", err.Error(), http.StatusBadRequest)
+	}
+
+	return ctx.Write(struct {
+		Channel        string `json:"channel"`
+		Ts             string `json:"ts"`
+		ThreadTs       string `json:"thread_ts,omitempty"`
+		Permalink      string `json:"permalink"`
+		SlackPermalink string `json:"slack_permalink"`
+	}{
+		Channel:        channelID,
+		Ts:             ts,
+		ThreadTs:       threadTs,
+		Permalink:      baseURL(ctx.r) + models.PermalinkPath(channelID, ts),
+		SlackPermalink: models.SlackPermalink(team.Domain, channelID, ts, threadTs),
+	})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	errwrap "github.com/pkg/errors"
+
+	"github.com/ashb/slackarchive/models"
+)
+
+// pinsHandler returns what's pinned to a channel, newest first. Pass
+// removed=1 for unpinned items too.
+func (api *api) pinsHandler(ctx *Context) error {
+	channel, err := api.pinsChannel(ctx)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Pins    []models.Pin `json:"pins"`
+		Related struct {
+			Users map[string]models.User `json:"users"`
+		} `json:"related"`
+	}{}
+
+	if response.Pins, err = ctx.db.Pins().List(channel.ID, ctx.r.Form.Get("removed") == "1"); err != nil {
+		return errwrap.Wrap(err, "Error selecting pins")
+	}
+	if err := api.redactPins(response.Pins); err != nil {
+		return err
+	}
+
+	var userIDs []string
+	for _, p := range response.Pins {
+		if p.PinnedBy != "" {
+			userIDs = append(userIDs, p.PinnedBy)
+		}
+	}
+	if response.Related.Users, err = usersByID(ctx, userIDs); err != nil {
+		return err
+	}
+
+	return ctx.Write(response)
+}
+
+// bookmarksHandler returns the bookmarks of a channel in their order. Pass
+// removed=1 for removed ones too.
+func (api *api) bookmarksHandler(ctx *Context) error {
+	channel, err := api.pinsChannel(ctx)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Bookmarks []models.Bookmark `json:"bookmarks"`
+		Related   struct {
+			Users map[string]models.User `json:"users"`
+		} `json:"related"`
+	}{}
+
+	if response.Bookmarks, err = ctx.db.Bookmarks().List(channel.ID, ctx.r.Form.Get("removed") == "1"); err != nil {
+		return errwrap.Wrap(err, "Error selecting bookmarks")
+	}
+	api.redactBookmarks(response.Bookmarks)
+
+	var userIDs []string
+	for _, b := range response.Bookmarks {
+		if b.UpdatedBy != "" {
+			userIDs = append(userIDs, b.UpdatedBy)
+		}
+	}
+	if response.Related.Users, err = usersByID(ctx, userIDs); err != nil {
+		return err
+	}
+
+	return ctx.Write(response)
+}
+
+func (api *api) pinsChannel(ctx *Context) (*models.Channel, error) {
+	team, err := api.Team(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx.r.ParseForm()
+
+	return teamChannel(ctx, team, ctx.Vars["id"])
+}
+
+// usersByID loads the users a response mentions, by ID.
+func usersByID(ctx *Context, ids []string) (map[string]models.User, error) {
+	users, err := ctx.db.Users().GetMany(ids)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "Error selecting users")
+	}
+	related := map[string]models.User{}
+	for _, u := range users {
+		related[u.ID] = u
+	}
+	return related, nil
+}
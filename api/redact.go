@@ -0,0 +1,64 @@
+package api
+
+import (
+	errwrap "github.com/pkg/errors"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// redactMessages redacts messages on their way out when the redaction mode
+// is output. The admin exports don't, they're for seeing what was said.
+func (api *api) redactMessages(messages ...models.Message) error {
+	for _, m := range messages {
+		if _, err := api.redactor.Message(m.Msg); err != nil {
+			return errwrap.Wrap(err, "Error redacting message")
+		}
+	}
+	return nil
+}
+
+func (api *api) redactPins(pins []models.Pin) error {
+	for _, p := range pins {
+		if _, err := api.redactor.Item(p.Item); err != nil {
+			return errwrap.Wrap(err, "Error redacting pin")
+		}
+	}
+	return nil
+}
+
+func (api *api) redactBookmarks(bookmarks []models.Bookmark) {
+	for i := range bookmarks {
+		api.redactor.Bookmark(&bookmarks[i])
+	}
+}
+
+// checkSearch refuses searches that could find what's redacted when the
+// redaction mode is output. The database keeps the original text there, so
+// searching for a secret, and reading the total, would tell whether it's
+// in the archive. Regular expressions could probe it a character at a time.
+func (api *api) checkSearch(query string, mode storage.SearchMode) error {
+	if api.redactor == nil {
+		return nil
+	}
+	if mode == storage.SearchFullText {
+		if _, n := api.redactor.String(query); n > 0 {
+			return ErrSearchRedacted
+		}
+		return nil
+	}
+
+	terms, err := storage.ParseSubstringQuery(query)
+	if err != nil {
+		return nil
+	}
+	for _, t := range terms {
+		if t.Regexp {
+			return ErrSearchRedacted
+		}
+		if _, n := api.redactor.String(t.Text); n > 0 {
+			return ErrSearchRedacted
+		}
+	}
+	return nil
+}
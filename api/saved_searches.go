@@ -0,0 +1,230 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	apierrors "github.com/ashb/slackarchive/api/errors"
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type savedSearchInput struct {
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	Mode      string `json:"mode"`
+	ChannelID string `json:"channel_id"`
+	Notify    bool   `json:"notify"`
+}
+
+func (in *savedSearchInput) validate() error {
+	verr := &apierrors.ValidationError{}
+	if in.Name == "" {
+		verr.Add("name", "required", "Name is required")
+	}
+	if in.Query == "" {
+		verr.Add("query", "required", "Query is required")
+	}
+	switch storage.SearchMode(in.Mode) {
+	case storage.SearchFullText:
+	case storage.SearchExact, storage.SearchFuzzy:
+		if _, err := storage.ParseSubstringQuery(in.Query); err != nil {
+			verr.Add("query", "invalid", err.Error())
+		}
+	default:
+		verr.Add("mode", "invalid", "Mode must be exact or fuzzy")
+	}
+	if !verr.Valid() {
+		return verr
+	}
+	return nil
+}
+
+// checkChannel makes sure the channel a search is limited to, if any, is
+// one of teamID's.
+func (in *savedSearchInput) checkChannel(ctx *Context, teamID string) error {
+	if in.ChannelID == "" {
+		return nil
+	}
+	if ok, err := ctx.db.Channels().InTeam(in.ChannelID, teamID); err != nil {
+		return err
+	} else if !ok {
+		verr := &apierrors.ValidationError{}
+		verr.Add("channel_id", "invalid", "No such channel")
+		return verr
+	}
+	return nil
+}
+
+func (in *savedSearchInput) apply(search *models.SavedSearch) {
+	search.Name = in.Name
+	search.Query = in.Query
+	search.Mode = in.Mode
+	search.ChannelID = in.ChannelID
+	search.Notify = in.Notify
+}
+
+// countNewMatches sets how many messages matching search were posted since
+// its owner last looked.
+func countNewMatches(ctx *Context, search *models.SavedSearch) error {
+	result, err := ctx.db.Messages().Search(storage.SavedSearchQuery(search, search.LastSeenAt, 1))
+	if err == storage.ErrSearchTimeout {
+		return ErrSearchTimeout
+	} else if err != nil {
+		return err
+	}
+	search.NewMatches = result.TotalCount
+	return nil
+}
+
+// savedSearch loads the search in the URL, as long as it belongs to the
+// signed in user.
+func (api *api) savedSearch(ctx *Context) (*models.SavedSearch, error) {
+	userID, err := ctx.UserID()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseInt(ctx.Vars["id"], 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	search, err := ctx.db.SavedSearches().Get(id)
+	if err == storage.ErrNotFound || (err == nil && search.UserID != userID) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return search, nil
+}
+
+func (api *api) savedSearchesHandler(ctx *Context) error {
+	userID, err := ctx.UserID()
+	if err != nil {
+		return err
+	}
+
+	searches, err := ctx.db.SavedSearches().List(userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range searches {
+		if err := countNewMatches(ctx, &searches[i]); err != nil {
+			return err
+		}
+	}
+
+	if searches == nil {
+		searches = []models.SavedSearch{}
+	}
+	return ctx.Write(searches)
+}
+
+func (api *api) createSavedSearchHandler(ctx *Context) error {
+	userID, err := ctx.UserID()
+	if err != nil {
+		return err
+	}
+
+	team, err := api.Team(ctx)
+	if err != nil {
+		return err
+	}
+
+	var in savedSearchInput
+	if err := ctx.Read(&in); err != nil {
+		return err
+	}
+	if err := in.validate(); err != nil {
+		return err
+	}
+	if err := in.checkChannel(ctx, team.ID); err != nil {
+		return err
+	}
+	if err := api.checkSearch(in.Query, storage.SearchMode(in.Mode)); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	search := &models.SavedSearch{
+		UserID:     userID,
+		TeamID:     team.ID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	in.apply(search)
+
+	if err := ctx.db.SavedSearches().Create(search); err != nil {
+		return err
+	}
+	return ctx.Write(search)
+}
+
+func (api *api) savedSearchHandler(ctx *Context) error {
+	search, err := api.savedSearch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := countNewMatches(ctx, search); err != nil {
+		return err
+	}
+	return ctx.Write(search)
+}
+
+func (api *api) updateSavedSearchHandler(ctx *Context) error {
+	search, err := api.savedSearch(ctx)
+	if err != nil {
+		return err
+	}
+
+	var in savedSearchInput
+	if err := ctx.Read(&in); err != nil {
+		return err
+	}
+	if err := in.validate(); err != nil {
+		return err
+	}
+	if err := in.checkChannel(ctx, search.TeamID); err != nil {
+		return err
+	}
+	if err := api.checkSearch(in.Query, storage.SearchMode(in.Mode)); err != nil {
+		return err
+	}
+	in.apply(search)
+
+	if err := ctx.db.SavedSearches().Update(search); err != nil {
+		return err
+	}
+
+	if err := countNewMatches(ctx, search); err != nil {
+		return err
+	}
+	return ctx.Write(search)
+}
+
+func (api *api) deleteSavedSearchHandler(ctx *Context) error {
+	search, err := api.savedSearch(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ctx.db.SavedSearches().Delete(search.ID)
+}
+
+// seenSavedSearchHandler marks the matches of a saved search as seen, so
+// only messages posted from now on count as new.
+func (api *api) seenSavedSearchHandler(ctx *Context) error {
+	search, err := api.savedSearch(ctx)
+	if err != nil {
+		return err
+	}
+
+	search.LastSeenAt = time.Now()
+	if err := ctx.db.SavedSearches().Update(search); err != nil {
+		return err
+	}
+	return ctx.Write(search)
+}
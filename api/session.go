@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// SessionCookie remembers who signed in with Slack.
+type SessionCookie struct {
+	ctx *Context
+	s   *sessions.Session
+}
+
+func GetSessionCookie(ctx *Context) (*SessionCookie, error) {
+	cookie := SessionCookie{ctx: ctx}
+	return &cookie, cookie.Get()
+}
+
+func (cookie *SessionCookie) Get() error {
+	var err error
+	cookie.s, err = cookie.ctx.store.Get(cookie.ctx.r, "session")
+
+	cookie.s.Options = cookieOptions(cookie.ctx.r, 30*24*60*60)
+
+	return err
+}
+
+// cookieOptions keeps cookies away from scripts and, but for following
+// links to the archive, other sites. They're only sent over https when the
+// archive is served over it.
+func cookieOptions(r *http.Request, maxAge int) *sessions.Options {
+	return &sessions.Options{
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(baseURL(r), "https:"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (cookie *SessionCookie) UserID() string {
+	if userID, ok := cookie.s.Values["user_id"]; ok {
+		return userID.(string)
+	}
+
+	return ""
+}
+
+func (cookie *SessionCookie) SetUserID(v string) {
+	cookie.s.Values["user_id"] = v
+}
+
+func (cookie *SessionCookie) Save() {
+	cookie.s.Save(cookie.ctx.r, cookie.ctx.w)
+}
+
+func (cookie *SessionCookie) Delete() {
+	cookie.s.Options.MaxAge = -1
+	cookie.s.Save(cookie.ctx.r, cookie.ctx.w)
+}
+
+// UserID is the Slack user signed in to this session, see
+// oAuthCallbackHandler.
+func (ctx *Context) UserID() (string, error) {
+	cookie, err := GetSessionCookie(ctx)
+	if err != nil || cookie.UserID() == "" {
+		return "", ErrNotAuthorized
+	}
+	return cookie.UserID(), nil
+}
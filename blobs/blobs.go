@@ -0,0 +1,86 @@
+// Package blobs keeps the files the archive downloads from Slack, such as
+// custom emoji images, so they still show once Slack no longer has them.
+// Blobs are named by the SHA-256 of their contents, so the same image is
+// only stored once.
+package blobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrNotFound is returned when there's no blob with the key asked for.
+var ErrNotFound = errors.New("blobs: not found")
+
+var validKey = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Store keeps blobs.
+type Store interface {
+	// Put stores the contents of r and returns their key
+	Put(r io.Reader) (string, error)
+	Open(key string) (io.ReadCloser, error)
+}
+
+// FileStore keeps blobs in a directory, as <dir>/ab/abcdef...
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+func (s *FileStore) Put(r io.Reader) (string, error) {
+	// Hash while writing to a temporary file, then move it in place once
+	// we know its name
+	tmp, err := ioutil.TempFile(s.dir, ".put-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	key := hex.EncodeToString(h.Sum(nil))
+	if _, err := os.Stat(s.path(key)); err == nil {
+		return key, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path(key)), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+	return key, os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *FileStore) Open(key string) (io.ReadCloser, error) {
+	if !validKey.MatchString(key) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
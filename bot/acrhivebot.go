@@ -4,33 +4,44 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"runtime"
 	"time"
 
-	"github.com/go-pg/pg"
-	"github.com/go-pg/pg/orm"
-	logging "github.com/op/go-logging"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/slack-go/slack"
 
+	"github.com/ashb/slackarchive/blobs"
 	"github.com/ashb/slackarchive/config"
+	"github.com/ashb/slackarchive/logs"
+	"github.com/ashb/slackarchive/metrics"
 	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/redact"
+	"github.com/ashb/slackarchive/storage"
 	"github.com/ashb/slackarchive/utils"
 )
 
-var log = logging.MustGetLogger("archivebot")
+var log = logs.New("bot")
 
 type archiveBot struct {
-	session orm.DB
+	store storage.Store
+	blobs blobs.Store
+	// redactor is nil when redaction is off
+	redactor *redact.Redactor
 
 	archivers map[string]*archiveClient
 	config    *config.Config
 	work      chan func()
+
+	syncs syncStatuses
 }
 
-func New(config *config.Config, db orm.DB) *archiveBot {
+func New(config *config.Config, store storage.Store, blobs blobs.Store, redactor *redact.Redactor) *archiveBot {
 	return &archiveBot{
-		session:   db,
+		store:     store,
+		blobs:     blobs,
+		redactor:  redactor,
 		config:    config,
 		work:      make(chan func(), 100),
 		archivers: map[string]*archiveClient{},
@@ -43,22 +54,32 @@ type archiveClient struct {
 	ab     *archiveBot
 	tokens config.TokenConfig
 	Team   *models.Team
+	// teamID is the workspace an org-wide Enterprise Grid token is scoped to,
+	// empty for workspace tokens
+	teamID     string
+	httpClient *http.Client
 	SyncIntervalMinute int
 	SyncRecentDay int
 }
 
-// Map of last-message we've received per channel.
-type lastMessageDates []struct {
-	ID         string
-	FirstSince *time.Time
+// logger is the bot's logger for the client's team.
+func (ac *archiveClient) logger() *logrus.Entry {
+	teamID := ac.teamID
+	if ac.Team != nil {
+		teamID = ac.Team.ID
+	}
+	return log.WithField("team", teamID)
 }
 
-func (ac *archiveClient) Sync(ctx context.Context, since *time.Time) error {
-	db := ac.ab.session
+// channelLogger is the bot's logger for a channel of the client's team.
+func (ac *archiveClient) channelLogger(channelID string) *logrus.Entry {
+	return ac.logger().WithField("channel", channelID)
+}
 
-	log.Info("Syncing team (%s)", ac.Team.ID)
+func (ac *archiveClient) Sync(ctx context.Context, since *time.Time) error {
+	ac.logger().Info("Syncing team")
 
-	team, err := ac.GetTeamInfo()
+	team, err := ac.getTeamInfo(ctx)
 	if err != nil {
 		return errors.WithMessage(err, "GetTeamInfo")
 	}
@@ -71,22 +92,13 @@ func (ac *archiveClient) Sync(ctx context.Context, since *time.Time) error {
 		return errors.Wrapf(err, "could not merging team(%s)", team.ID)
 	}
 
-	var exists bool
-	if exists, err = db.Model(ac.Team).WherePK().Exists(); exists {
-		_, err = db.Model(ac.Team).WherePK().Update(ac.Team)
-	} else if err == nil {
-		_, err = db.Model(ac.Team).Insert()
-	} else {
-		log.Error(err.Error())
-	}
-
-	if err != nil {
+	if err = ac.ab.store.Teams().Upsert(ac.Team); err != nil {
 		return errors.Wrapf(err, "could not upsert team(%s)", team.ID)
 	}
 
-	log.Info("Syncing team (%s) finished", ac.Team.ID)
+	ac.logger().Info("Syncing team finished")
 
-	log.Info("Syncing users(%s)", ac.Team.ID)
+	ac.logger().Info("Syncing users")
 
 	pageNum := 0
 	count := 0
@@ -96,17 +108,17 @@ func (ac *archiveClient) Sync(ctx context.Context, since *time.Time) error {
 		page, err = page.Next(ctx)
 		if err == nil {
 			pageNum++
-			log.Infof("User page %d", pageNum)
+			ac.logger().Infof("User page %d", pageNum)
 			for _, user := range page.Users {
 				if err := ac.UpsertUser(user); err != nil {
-					log.Errorf("%s", err.Error())
+					ac.logger().Error(err)
 				}
 
 				count++
 			}
 
 		} else if rateLimitedError, ok := err.(*slack.RateLimitedError); ok {
-			log.Infof("Rate limited for %s", rateLimitedError.RetryAfter)
+			ac.logger().Infof("Rate limited for %s", rateLimitedError.RetryAfter)
 			select {
 			case <-ctx.Done():
 				err = ctx.Err()
@@ -116,9 +128,9 @@ func (ac *archiveClient) Sync(ctx context.Context, since *time.Time) error {
 		}
 	}
 
-	log.Info("Syncing users completed(%s): %#v %d", ac.Team.ID, err, count)
+	ac.logger().Infof("Syncing users completed: %d users (%v)", count, err)
 
-	log.Info("Syncing channels (%s)", ac.Team.ID)
+	ac.logger().Info("Syncing channels")
 
 	params := slack.GetConversationsParameters{
 		ExcludeArchived: false,
@@ -133,21 +145,28 @@ func (ac *archiveClient) Sync(ctx context.Context, since *time.Time) error {
 		channels, nextCursor, err = ac.GetConversationsContext(ctx, &params)
 
 		if err == nil {
-			log.Info("Updating info for %d channels", len(channels))
+			ac.logger().Infof("Updating info for %d channels", len(channels))
 			for _, channel := range channels {
 				c := models.Channel{TeamID: ac.Team.ID}
 
 				if err := utils.Merge(&c, channel); err != nil {
-					log.Error("Error merging channel(%s): %s", channel.ID, err.Error())
+					ac.channelLogger(channel.ID).Errorf("Error merging channel: %s", err)
 					continue
 				}
 
-				_, err := db.Model(&c).OnConflict("(id) DO UPDATE").Insert()
+				old, err := ac.ab.store.Channels().Get(c.ID)
+				if err != nil && err != storage.ErrNotFound {
+					ac.channelLogger(channel.ID).Errorf("Error loading channel: %s", err)
+					continue
+				}
 
-				if err != nil {
-					log.Error("Error upserting channel(%s): %s", channel.ID, err.Error())
+				if err := ac.ab.store.Channels().Upsert(&c); err != nil {
+					ac.channelLogger(channel.ID).Errorf("Error upserting channel: %s", err)
 					continue
 				}
+				if old != nil {
+					ac.recordChannelChanges(old, &c)
+				}
 			}
 			if nextCursor == "" {
 				break
@@ -156,7 +175,7 @@ func (ac *archiveClient) Sync(ctx context.Context, since *time.Time) error {
 
 		} else if rateLimitedError, ok := err.(*slack.RateLimitedError); ok {
 
-			log.Infof("Rate limited for %s", rateLimitedError.RetryAfter)
+			ac.logger().Infof("Rate limited for %s", rateLimitedError.RetryAfter)
 			select {
 			case <-ctx.Done():
 				err = ctx.Err()
@@ -170,44 +189,50 @@ func (ac *archiveClient) Sync(ctx context.Context, since *time.Time) error {
 		return errors.Wrapf(err, "could not sync channels(%s)", ac.Team.ID)
 	}
 
+	if err := ac.syncEmoji(ctx); err != nil {
+		ac.logger().Errorf("Error syncing emoji: %s", err)
+	}
+
 	// Now that we have users and channels in the DB we can get a "snapshot" of
 	// the latest message date per channel and "backfill" any gaps. Since we
 	// have captured the date we can also start the RTM stream and capture new
 	// messages without worrying about missing anything
-	latest, err := ac.getFirstMessageDatePerChannelSince(since)
+	latest, err := ac.ab.store.Channels().FirstMessagesSince(ac.Team.ID, since)
 	if err != nil {
 		return errors.Wrapf(err, "could not get latest message dates per channel (%s)", ac.Team.ID)
 	}
 
+	// Tokens granted before we archived pins and bookmarks may lack the
+	// scopes, only ask once then
+	syncPins, syncBookmarks := true, true
+
 	for _, chanInfo := range latest {
 		if err := ac.syncChannelMessages(ctx, chanInfo.ID, chanInfo.FirstSince); err != nil {
-			log.Error("Error syncing channel messages(%s): %s", chanInfo.ID, err.Error())
+			ac.channelLogger(chanInfo.ID).Errorf("Error syncing channel messages: %s", err)
+		}
+		if syncPins {
+			if err := ac.syncPins(ctx, chanInfo.ID); missingScope(err) {
+				ac.logger().Warning("Not syncing pins, the token lacks pins:read")
+				syncPins = false
+			} else if err != nil {
+				ac.channelLogger(chanInfo.ID).Errorf("Error syncing pins: %s", err)
+			}
+		}
+		if syncBookmarks {
+			if err := ac.syncBookmarks(ctx, chanInfo.ID); missingScope(err) {
+				ac.logger().Warning("Not syncing bookmarks, the token lacks bookmarks:read")
+				syncBookmarks = false
+			} else if err != nil {
+				ac.channelLogger(chanInfo.ID).Errorf("Error syncing bookmarks: %s", err)
+			}
 		}
 	}
 
 	return nil
 }
 
-func (ac *archiveClient) getFirstMessageDatePerChannelSince(since *time.Time) (res lastMessageDates, err error) {
-	db := ac.ab.session
-	query := db.Model((*models.Channel)(nil)).
-		Column("id").
-		ColumnExpr("min(messages.timestamp) AS first_since").
-		Join("LEFT JOIN messages ON channel.id = messages.channel_id")
-	if since != nil {
-		query = query.Where("messages.timestamp > ?", since)
-	}
-	query = query.Group("channel.id")
-	err = query.Select(&res)
-
-	if err != nil && err != pg.ErrNoRows {
-		return res, err
-	}
-	return res, nil
-}
-
 func (ac *archiveClient) syncChannelMessages(ctx context.Context, ChannelID string, Since *time.Time) error {
-	log.Info("Syncing latest channel messages: %s (%s)", ChannelID, ac.Team.ID)
+	ac.channelLogger(ChannelID).Info("Syncing latest channel messages")
 
 	params := &slack.GetConversationHistoryParameters{
 		ChannelID: ChannelID,
@@ -216,7 +241,7 @@ func (ac *archiveClient) syncChannelMessages(ctx context.Context, ChannelID stri
 
 	if Since != nil {
 		params.Oldest = models.TimeToTimestamp(*Since)
-		log.Debug("Asking for messages after %s", params.Oldest)
+		ac.channelLogger(ChannelID).Debugf("Asking for messages after %s", params.Oldest)
 	}
 
 	var history *slack.GetConversationHistoryResponse
@@ -225,7 +250,7 @@ func (ac *archiveClient) syncChannelMessages(ctx context.Context, ChannelID stri
 
 	for err == nil {
 		// joining a channel that the bot is already in should not get error
-		_, _, _, err = ac.JoinConversation(params.ChannelID)
+		_, _, _, err = ac.JoinConversationContext(ctx, params.ChannelID)
 		if err != nil {
 			return errors.Wrap(err, "Error joining channel")
 		}
@@ -255,11 +280,11 @@ func (ac *archiveClient) syncChannelMessages(ctx context.Context, ChannelID stri
 				break
 			}
 			params.Oldest = history.Messages[0].Timestamp
-			log.Debug("Asking for messages after %s", params.Oldest)
+			ac.channelLogger(ChannelID).Debugf("Asking for messages after %s", params.Oldest)
 
 		} else if rateLimitedError, ok := err.(*slack.RateLimitedError); ok {
 
-			log.Infof("Rate limited for %s", rateLimitedError.RetryAfter)
+			ac.channelLogger(ChannelID).Infof("Rate limited for %s", rateLimitedError.RetryAfter)
 			select {
 			case <-ctx.Done():
 				err = ctx.Err()
@@ -272,12 +297,12 @@ func (ac *archiveClient) syncChannelMessages(ctx context.Context, ChannelID stri
 		return errors.Wrap(err, "Error retrieving channel history")
 	}
 
-	log.Info("Syncing latest channel messages completed: %s - %d new messages", ChannelID, imported)
+	ac.channelLogger(ChannelID).Infof("Syncing latest channel messages completed: %d new messages", imported)
 	return nil
 }
 
 func (ac *archiveClient) syncThreadMessages(ctx context.Context, ChannelID string, parentTimeStamp string) (int, error) {
-	log.Debug("Syncing thread messages: %s", parentTimeStamp)
+	ac.channelLogger(ChannelID).Debugf("Syncing thread messages: %s", parentTimeStamp)
 	params := &slack.GetConversationRepliesParameters{
 		ChannelID: ChannelID,
 		Timestamp: parentTimeStamp,
@@ -301,7 +326,7 @@ func (ac *archiveClient) syncThreadMessages(ctx context.Context, ChannelID strin
 				break
 			}
 		} else if rateLimitedError, ok := e.(*slack.RateLimitedError); ok {
-			log.Infof("Rate limited for %s", rateLimitedError.RetryAfter)
+			ac.channelLogger(ChannelID).Infof("Rate limited for %s", rateLimitedError.RetryAfter)
 			select {
 			case <-ctx.Done():
 				err = ctx.Err()
@@ -312,7 +337,7 @@ func (ac *archiveClient) syncThreadMessages(ctx context.Context, ChannelID strin
 	}
 
 	if err == nil {
-		log.Debug("Syncing thread messages completed: %s - %d messages", parentTimeStamp, imported)
+		ac.channelLogger(ChannelID).Debugf("Syncing thread messages completed: %s - %d messages", parentTimeStamp, imported)
 	}
 
 	return imported, err
@@ -324,16 +349,13 @@ func (ac *archiveClient) syncThreadMessages(ctx context.Context, ChannelID strin
 *  It will ask the Slack API for info about this bot user
  */
 func (ac *archiveClient) ImportBotUser(botID string) error {
-	u := &models.User{ID: botID, TeamID: ac.Team.ID}
-	if err := ac.ab.session.Model(u).WherePK().Select(); err == nil {
-		return nil
-	} else if err != pg.ErrNoRows {
+	if exists, err := ac.ab.store.Users().Exists(botID); err != nil || exists {
 		return err
 	}
 
 	bot, err := ac.GetBotInfo(botID)
 	if err != nil {
-		return fmt.Errorf("Error querying bot(%s): %s", u.ID, err.Error())
+		return fmt.Errorf("Error querying bot(%s): %s", botID, err.Error())
 	}
 	return ac.UpsertBotUser(*bot)
 
@@ -346,17 +368,37 @@ func (ac *archiveClient) UpsertUser(user slack.User) error {
 	}
 
 	u.TeamID = ac.Team.ID
-	_, err := ac.ab.session.Model(u).OnConflict("(id) DO UPDATE").Insert()
-	return errors.Wrapf(err, "error upserting user (%s)", user.ID)
+	u.MergeEnterprise(&user)
+	if err := ac.ab.store.Users().Upsert(u); err != nil {
+		return errors.Wrapf(err, "error upserting user (%s)", user.ID)
+	}
+	return ac.recordHistory(u)
 }
 
 func (ac *archiveClient) UpsertBotUser(bot slack.Bot) error {
 	u := &models.User{ID: bot.ID, TeamID: ac.Team.ID}
 	u.MergeBot(&bot)
 
-	_, err := ac.ab.session.Model(u).OnConflict("(id) DO UPDATE").Set("name = excluded.NAME, deleted = excluded.deleted, profile = excluded.profile").Insert()
+	if err := ac.ab.store.Users().UpsertBot(u); err != nil {
+		return errors.Wrapf(err, "error upserting bot user(%s)", u.ID)
+	}
+	return ac.recordHistory(u)
+}
+
+// recordHistory adds an entry to the user's profile history when they
+// changed since the last one.
+func (ac *archiveClient) recordHistory(u *models.User) error {
+	last, err := ac.ab.store.Users().LatestHistory(u.ID)
+	if err != nil && err != storage.ErrNotFound {
+		return errors.Wrapf(err, "error loading history of user(%s)", u.ID)
+	}
 
-	return errors.Wrapf(err, "error upserting bot user(%s)", u.ID)
+	entry := models.NextProfileHistory(u, last, time.Now())
+	if entry == nil {
+		return nil
+	}
+	err = ac.ab.store.Users().AddHistory(entry)
+	return errors.Wrapf(err, "error adding history of user(%s)", u.ID)
 }
 
 func (ac *archiveClient) NewMessage(msg *slack.Msg) error {
@@ -375,10 +417,37 @@ func (ac *archiveClient) NewMessageForChannel(msg *slack.Msg, channelID string)
 			return errors.Wrap(err, "error importing bot")
 		}
 		m.UserID = msg.BotID
+	} else if msg.User != "" && msg.Team != "" && msg.Team != ac.Team.ID {
+		// Slack Connect, or another workspace of our Enterprise Grid org
+		if err := ac.ImportExternalUser(context.Background(), msg.User, msg.Team); err != nil {
+			return errors.Wrap(err, "error importing external user")
+		}
+	}
+
+	if _, err := ac.ab.storeRedactor().Message(m.Msg); err != nil {
+		return errors.Wrap(err, "error redacting message")
+	}
+
+	if m.Timestamp != nil {
+		held, err := ac.ab.held(m.ChannelID, m.UserID, *m.Timestamp)
+		if err != nil {
+			return err
+		}
+		if held {
+			if err := ac.keepRevision(m); err != nil {
+				return err
+			}
+		} else if ac.ab.config.Retention.Expired(ac.Team.ID, m.ChannelID, *m.Timestamp, time.Now()) {
+			// Backfills mustn't bring back what the purge deleted
+			return nil
+		}
 	}
 
-	_, err := ac.ab.session.Model(m).OnConflict(`(channel_id, user_id, "timestamp") DO UPDATE`).Insert()
-	return errors.Wrap(err, "error upserting message")
+	if err := ac.ab.store.Messages().Upsert(m); err != nil {
+		return errors.Wrap(err, "error upserting message")
+	}
+	metrics.MessageIngested(ac.Team.ID, m.ChannelID)
+	return nil
 }
 
 func (ac *archiveClient) Bot() {
@@ -388,6 +457,7 @@ func (ac *archiveClient) Bot() {
 	rtm := slack.New(
 		ac.tokens.BotToken,
 		slack.OptionDebug(ac.Client.Debug()),
+		slack.OptionHTTPClient(ac.httpClient),
 	).NewRTM()
 	go rtm.ManageConnection()
 
@@ -399,7 +469,7 @@ Loop:
 			case *slack.HelloEvent:
 				// Ignore hello
 			case *slack.ConnectedEvent:
-				log.Debug("Connection counter: %d %s", ev.ConnectionCount, ev.Info.Team.Domain)
+				ac.logger().Debugf("Connection counter: %d", ev.ConnectionCount)
 
 				// Re-sync as we might have missed messages in the mean time
 				if ev.ConnectionCount > 0 {
@@ -421,81 +491,66 @@ Loop:
 				case "message_changed":
 					err = ac.NewMessageForChannel(msg.SubMessage, msg.Channel)
 				case "message_deleted":
-					m := &models.Message{}
-					m.Merge(&msg.Msg)
-					ac.ab.session.Model(&m).Delete()
+					err = ac.deleteMessage(msg.Channel, msg.DeletedTimestamp, msg.Timestamp)
+				case "channel_topic", "channel_purpose", "channel_name", "group_topic", "group_purpose", "group_name":
+					if err = ac.NewMessage(&msg.Msg); err == nil {
+						err = ac.channelEvent(ctx, messageChannelEvent(&msg.Msg))
+					}
 				case "channel_join":
 					// Ignore this subtype
 				default:
-					log.Debug("Unknwon message subtype %s", msg.SubType)
+					ac.channelLogger(msg.Channel).Debugf("Unknown message subtype %s", msg.SubType)
 					continue
 				}
 
 				if err != nil {
-					log.Error("Message upsert error: %s", err.Error())
+					ac.channelLogger(msg.Channel).Errorf("Message upsert error: %s", err)
 					continue
 				}
 			case *slack.LatencyReport:
 			//	log.Debug("Current latency: %v", ev.Value)
 			case *slack.UserTypingEvent:
-				log.Debug("User(%s) typing...", msg.Data.(*slack.UserTypingEvent).User)
+				ac.channelLogger(ev.Channel).Debugf("User(%s) typing...", ev.User)
 			case *slack.RTMError:
-				log.Debug("Error: %s", ev.Error())
+				ac.logger().Debugf("Error: %s", ev.Error())
 			case *slack.ConnectingEvent:
-				log.Debug("Connecting to Real-Time Messages API...")
+				ac.logger().Debug("Connecting to Real-Time Messages API...")
 			case *slack.RTMEvent:
 				// 2016/09/04 12:19:18 Unexpected: slack.RTMEvent{Type:"connection_error", Data:(*slack.ConnectionErrorEvent)(0xc4243f6380
 				if err, ok := ev.Data.(error); ok {
-					log.Error("Event: %s %s", ev.Type, err.Error())
+					ac.logger().Errorf("Event: %s %s", ev.Type, err)
 				} else {
-					log.Debug("Event: %s", ev.Type)
+					ac.logger().Debugf("Event: %s", ev.Type)
 				}
 			case *slack.ReconnectUrlEvent:
 				// log.Debug("ReconnectURL: %#v", ev)
 			case *slack.InvalidAuthEvent:
-				log.Debug("Invalid credentials")
+				ac.logger().Warning("Invalid credentials")
 				break Loop
 			// case *slack.DesktopNotification:
 			case *slack.TeamJoinEvent:
-				// TODO: Add new user
 				user := msg.Data.(*slack.TeamJoinEvent).User
-
-				u := &models.User{}
-				if err := utils.Merge(u, user); err != nil {
-					log.Error("Error merging user(%s): %s", user.ID, err.Error())
-					continue
-				}
-
-				u.Team = ac.Team
-
-				if _, err := ac.ab.session.Model(u).Insert(); err != nil {
-					log.Error("Error inserting user(%s): %s", user.ID, err.Error())
+				if err := ac.UpsertUser(user); err != nil {
+					ac.logger().Errorf("Error inserting user(%s): %s", user.ID, err)
 					continue
 				}
 			case *slack.UserChangeEvent:
+				// Deactivations come as changes too, with deleted set
 				user := msg.Data.(*slack.UserChangeEvent).User
-				u := &models.User{}
-				if err := utils.Merge(u, user); err != nil {
-					log.Error("Error merging user(%s): %s", user.ID, err.Error())
-					continue
-				}
-
-				u.Team = ac.Team
-
-				if _, err := ac.ab.session.Model(u).WherePK().Update(); err != nil {
-					log.Error("Error updating user(%s): %s", user.ID, err.Error())
+				if err := ac.UpsertUser(user); err != nil {
+					ac.logger().Errorf("Error updating user(%s): %s", user.ID, err)
 					continue
 				}
 			case *slack.BotAddedEvent:
 				evt := msg.Data.(*slack.BotAddedEvent)
 				if err := ac.UpsertBotUser(evt.Bot); err != nil {
-					log.Errorf("error upserting bot user(%s): %s", evt.Bot.ID, err)
+					ac.logger().Errorf("error upserting bot user(%s): %s", evt.Bot.ID, err)
 					continue
 				}
 			case *slack.BotChangedEvent:
 				evt := msg.Data.(*slack.BotChangedEvent)
 				if err := ac.UpsertBotUser(evt.Bot); err != nil {
-					log.Errorf("error upserting bot user(%s): %s", evt.Bot.ID, err)
+					ac.logger().Errorf("error upserting bot user(%s): %s", evt.Bot.ID, err)
 					continue
 				}
 
@@ -505,27 +560,93 @@ Loop:
 				c := models.Channel{TeamID: ac.Team.ID}
 
 				if err := utils.Merge(&c, evt.Channel); err != nil {
-					log.Error("Error merging channel(%s): %s", evt.Channel.ID, err.Error())
+					ac.channelLogger(evt.Channel.ID).Errorf("Error merging channel: %s", err)
 					continue
 				}
 
-				_, err := ac.ab.session.Model(&c).OnConflict("(id) DO UPDATE").Insert()
-
-				if err != nil {
-					log.Error("Error upserting channel(%s): %s", evt.Channel.ID, err.Error())
+				if err := ac.ab.store.Channels().Upsert(&c); err != nil {
+					ac.channelLogger(evt.Channel.ID).Errorf("Error upserting channel: %s", err)
 					continue
 				}
+			case *slack.ChannelCreatedEvent:
+				evt := msg.Data.(*slack.ChannelCreatedEvent)
+				err := ac.channelEvent(ctx, &models.ChannelEvent{
+					ChannelID: evt.Channel.ID,
+					Type:      models.ChannelCreated,
+					UserID:    evt.Channel.Creator,
+					Timestamp: eventTime(evt.EventTimestamp),
+				})
+				if err != nil {
+					ac.channelLogger(evt.Channel.ID).Errorf("error recording channel created: %s", err)
+				}
 			case *slack.ChannelRenameEvent:
-				evt := msg.Data.(*slack.ChannelRenameInfo)
-				ac.ab.session.Model(&models.Channel{
-					ID:     evt.ID,
-					TeamID: ac.Team.ID,
-					Name:   evt.Name,
-				}).WherePK().Update()
+				evt := msg.Data.(*slack.ChannelRenameEvent)
+				err := ac.channelEvent(ctx, &models.ChannelEvent{
+					ChannelID: evt.Channel.ID,
+					Type:      models.ChannelRenamed,
+					Name:      evt.Channel.Name,
+					Timestamp: eventTime(evt.Timestamp),
+				})
+				if err != nil {
+					ac.channelLogger(evt.Channel.ID).Errorf("error renaming channel: %s", err)
+				}
+			case *slack.GroupRenameEvent:
+				evt := msg.Data.(*slack.GroupRenameEvent)
+				err := ac.channelEvent(ctx, &models.ChannelEvent{
+					ChannelID: evt.Group.ID,
+					Type:      models.ChannelRenamed,
+					Name:      evt.Group.Name,
+					Timestamp: eventTime(evt.Timestamp),
+				})
+				if err != nil {
+					ac.channelLogger(evt.Group.ID).Errorf("error renaming channel: %s", err)
+				}
+			case *slack.ChannelArchiveEvent:
+				evt := slack.ChannelInfoEvent(*msg.Data.(*slack.ChannelArchiveEvent))
+				if err := ac.channelEvent(ctx, channelInfoEvent(models.ChannelArchived, &evt)); err != nil {
+					ac.channelLogger(evt.Channel).Errorf("error archiving channel: %s", err)
+				}
+			case *slack.GroupArchiveEvent:
+				evt := slack.ChannelInfoEvent(*msg.Data.(*slack.GroupArchiveEvent))
+				if err := ac.channelEvent(ctx, channelInfoEvent(models.ChannelArchived, &evt)); err != nil {
+					ac.channelLogger(evt.Channel).Errorf("error archiving channel: %s", err)
+				}
+			case *slack.ChannelUnarchiveEvent:
+				evt := slack.ChannelInfoEvent(*msg.Data.(*slack.ChannelUnarchiveEvent))
+				if err := ac.channelEvent(ctx, channelInfoEvent(models.ChannelUnarchived, &evt)); err != nil {
+					ac.channelLogger(evt.Channel).Errorf("error unarchiving channel: %s", err)
+				}
+			case *slack.GroupUnarchiveEvent:
+				evt := slack.ChannelInfoEvent(*msg.Data.(*slack.GroupUnarchiveEvent))
+				if err := ac.channelEvent(ctx, channelInfoEvent(models.ChannelUnarchived, &evt)); err != nil {
+					ac.channelLogger(evt.Channel).Errorf("error unarchiving channel: %s", err)
+				}
+			case *slack.ChannelDeletedEvent:
+				evt := slack.ChannelInfoEvent(*msg.Data.(*slack.ChannelDeletedEvent))
+				if err := ac.channelEvent(ctx, channelInfoEvent(models.ChannelDeleted, &evt)); err != nil {
+					ac.channelLogger(evt.Channel).Errorf("error deleting channel: %s", err)
+				}
+
+			case *slack.EmojiChangedEvent:
+				evt := msg.Data.(*slack.EmojiChangedEvent)
+				if err := ac.emojiChanged(context.Background(), evt); err != nil {
+					ac.logger().Errorf("error applying emoji change(%s): %s", evt.SubType, err)
+				}
+
+			case *slack.PinAddedEvent:
+				evt := msg.Data.(*slack.PinAddedEvent)
+				if err := ac.pinChanged(context.Background(), evt.Channel, &evt.Item, evt.User, evt.EventTimestamp, true); err != nil {
+					ac.channelLogger(evt.Channel).Errorf("error adding pin: %s", err)
+				}
+			case *slack.PinRemovedEvent:
+				evt := msg.Data.(*slack.PinRemovedEvent)
+				if err := ac.pinChanged(context.Background(), evt.Channel, &evt.Item, evt.User, evt.EventTimestamp, false); err != nil {
+					ac.channelLogger(evt.Channel).Errorf("error removing pin: %s", err)
+				}
 
 			case *slack.ReactionAddedEvent:
 				// TODO: Work out if we want to store Reactions at all
-				log.Debug("Unexpected: %s, %s, %#v", ac.Team.ID, ac.Team.Domain, msg.Data.(*slack.ReactionAddedEvent))
+				ac.logger().Debugf("Unexpected: %#v", msg.Data.(*slack.ReactionAddedEvent))
 
 				/*
 					-- Check if the reaction type exists:
@@ -539,11 +660,11 @@ Loop:
 								AND "timestamp" = to_timestamp(1548207132.053000);
 				*/
 			case *slack.ReactionRemovedEvent:
-				log.Debug("Unexpected: %s, %s, %#v", ac.Team.ID, ac.Team.Domain, msg.Data.(*slack.ReactionRemovedEvent))
+				ac.logger().Debugf("Unexpected: %#v", msg.Data.(*slack.ReactionRemovedEvent))
 			case *slack.FilePublicEvent:
-				log.Debug("Unexpected: %s, %s, %#v", ac.Team.ID, ac.Team.Domain, msg.Data.(*slack.FilePublicEvent))
+				ac.logger().Debugf("Unexpected: %#v", msg.Data.(*slack.FilePublicEvent))
 			case *slack.FileSharedEvent:
-				log.Debug("Unexpected: %s, %s, %#v", ac.Team.ID, ac.Team.Domain, msg.Data.(*slack.FileSharedEvent))
+				ac.logger().Debugf("Unexpected: %#v", msg.Data.(*slack.FileSharedEvent))
 
 			// Events to ignore as we don't care about them
 			case *slack.MemberJoinedChannelEvent:
@@ -551,28 +672,39 @@ Loop:
 
 			default:
 				// Ignore other events..
-				log.Debug("Unexpected: %s, %s, %#v", ac.Team.ID, ac.Team.Domain, msg)
+				ac.logger().Debugf("Unexpected: %#v", msg)
 			}
 		}
 	}
 }
 
-func (ab *archiveBot) NewArchiveClient(token config.TokenConfig, config config.Config) (*archiveClient, error) {
+// NewArchiveClient creates the client archiving a single workspace. teamID
+// selects the workspace for org-wide Enterprise Grid tokens and must be empty
+// for workspace tokens.
+func (ab *archiveBot) NewArchiveClient(token config.TokenConfig, teamID string, config config.Config) (*archiveClient, error) {
+	httpClient := &http.Client{Transport: &tracingTransport{Base: &metricsTransport{}}}
+	if teamID != "" {
+		httpClient.Transport = &teamScopedTransport{TeamID: teamID, Base: httpClient.Transport}
+	}
+
 	ac := archiveClient{
 		slack.New(
 			token.OAuthToken,
 			slack.OptionDebug(false),
+			slack.OptionHTTPClient(httpClient),
 		),
 		ab,
 		token,
 		nil,
+		teamID,
+		httpClient,
 		config.SyncIntervalMinute,
 		config.SyncRecentDay,
 	}
 
-	var team *slack.TeamInfo
+	var team *teamInfo
 	var err error
-	if team, err = ac.GetTeamInfo(); err != nil {
+	if team, err = ac.getTeamInfo(context.Background()); err != nil {
 		return nil, errors.Wrap(err, "error getting team info")
 	}
 	ac.Team = &models.Team{}
@@ -587,24 +719,68 @@ func (ab *archiveBot) NewArchiveClient(token config.TokenConfig, config config.C
 	return &ac, nil
 }
 
+// NewArchiveClients creates an archive client for token, or one per
+// workspace when token is an org-wide Enterprise Grid token.
+func (ab *archiveBot) NewArchiveClients(token config.TokenConfig, config config.Config) ([]*archiveClient, error) {
+	if !token.Enterprise {
+		ac, err := ab.NewArchiveClient(token, "", config)
+		if err != nil {
+			return nil, err
+		}
+		return []*archiveClient{ac}, nil
+	}
+
+	teamIDs := token.Teams
+	if len(teamIDs) == 0 {
+		// An unscoped client is enough to ask which workspaces we can see
+		org := &archiveClient{ab: ab, tokens: token, httpClient: &http.Client{Transport: &tracingTransport{Base: &metricsTransport{}}}}
+
+		var err error
+		if teamIDs, err = org.listOrgTeams(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	var clients []*archiveClient
+	for _, teamID := range teamIDs {
+		ac, err := ab.NewArchiveClient(token, teamID, config)
+		if err != nil {
+			log.WithField("team", teamID).Errorf("Error starting client: %s", err)
+			continue
+		}
+		clients = append(clients, ac)
+	}
+	return clients, nil
+}
+
 func (ac *archiveClient) Start() {
+	ac.ab.syncStarted(ac.Team.ID, ac.Team.Domain)
+
 	go func() {
 		defer func() {
 			if err := recover(); err != nil {
-				trace := make([]byte, 1024)
-				count := runtime.Stack(trace, true)
-				log.Error("Error: %s", err)
-				log.Debug("Stack of %d bytes: %s", count, trace)
+				trace := make([]byte, 64<<10)
+				count := runtime.Stack(trace, false)
+				ac.logger().WithField("stack", string(trace[:count])).Errorf("Error: %s", err)
 				return
 			}
 		}()
 
 		syncFunc := func(){
 			since := time.Now().Add(time.Hour * time.Duration(-24 * ac.SyncRecentDay))
-			if err := ac.Sync(context.Background(), &since); err != nil {
-				log.Error("Sync error: %s", err.Error())
+			ctx, span := ac.startSync("sync")
+			started := time.Now()
+			err := ac.Sync(ctx, &since)
+			ac.ab.syncFinished(ac.Team.ID, started, err)
+			if err != nil {
+				endSync(span, err)
+				ac.logger().Errorf("Sync error: %s", err)
 				panic(err)
 			}
+			if err := ac.SendDigests(ctx); err != nil {
+				ac.logger().Errorf("Digest error: %s", err)
+			}
+			endSync(span, nil)
 		}
 
 		syncFunc()
@@ -618,20 +794,24 @@ func (ac *archiveClient) Start() {
 func (ac *archiveClient) RetrieveAll() {
 	defer func() {
 		if err := recover(); err != nil {
-			trace := make([]byte, 1024)
-			count := runtime.Stack(trace, true)
-			log.Error("Error: %s", err)
-			log.Debug("Stack of %d bytes: %s", count, trace)
+			trace := make([]byte, 64<<10)
+			count := runtime.Stack(trace, false)
+			ac.logger().WithField("stack", string(trace[:count])).Errorf("Error: %s", err)
 			return
 		}
 	}()
 
-	if err := ac.Sync(context.Background(), nil); err != nil {
-		log.Error("Sync error: %s", err.Error())
+	ctx, span := ac.startSync("full sync")
+	started := time.Now()
+	err := ac.Sync(ctx, nil)
+	ac.ab.syncFinished(ac.Team.ID, started, err)
+	endSync(span, err)
+	if err != nil {
+		ac.logger().Errorf("Sync error: %s", err)
 		panic(err)
 	}
 
-	log.Info("Init finished. Press 'Ctrl + C' to terminate.")
+	ac.logger().Info("Init finished. Press 'Ctrl + C' to terminate.")
 }
 
 func (ab *archiveBot) worker() {
@@ -644,7 +824,7 @@ func (ab *archiveBot) worker() {
 		// Wait 0 to 2 seconds between operations to avoid hammering rate limits
 		wait := rand.Float32() * 2
 
-		log.Info("Waiting for %.02f seconds", wait)
+		log.Infof("Waiting for %.02f seconds", wait)
 
 		time.Sleep(time.Duration(wait) * time.Second)
 
@@ -658,7 +838,11 @@ func (ab *archiveBot) Reload() {
 func (ab *archiveBot) Start() {
 	go ab.worker()
 
-	for _, token := range ab.config.BotTokens {
+	if ab.config.Retention.Enabled() {
+		go ab.purgeLoop()
+	}
+
+	for i, token := range ab.config.BotTokens {
 		/*var team models.Team
 		if err := db.Teams.Find(bson.M{
 			"token": token.BotToken,
@@ -667,36 +851,40 @@ func (ab *archiveBot) Start() {
 		} else {
 			log.Info("Starting archive bot for token: %s", token.BotToken)
 		}*/
-		log.Info("Starting archive bot for token: %s", token.BotToken)
+		log.Infof("Starting archive bot for token %d", i)
 
-		ac, err := ab.NewArchiveClient(token, *ab.config)
+		clients, err := ab.NewArchiveClients(token, *ab.config)
 		if err == nil {
 		} else if err.Error() == "invalid_auth" || err.Error() == "account_inactive" {
 			continue
 		} else if err != nil {
-			log.Error("Error starting client %s: %s", token, err)
+			log.Errorf("Error starting client for token %d: %s", i, err)
 			continue
 		}
 
-		ac.Start()
+		for _, ac := range clients {
+			ac.Start()
+		}
 	}
 }
 
 func (ab *archiveBot) RetrieveAll() {
 	go ab.worker()
 
-	for _, token := range ab.config.BotTokens {
-		log.Info("Starting archive bot for token: %s", token.BotToken)
+	for i, token := range ab.config.BotTokens {
+		log.Infof("Starting archive bot for token %d", i)
 
-		ac, err := ab.NewArchiveClient(token, *ab.config)
+		clients, err := ab.NewArchiveClients(token, *ab.config)
 		if err == nil {
 		} else if err.Error() == "invalid_auth" || err.Error() == "account_inactive" {
 			continue
 		} else if err != nil {
-			log.Error("Error starting client %s: %s", token, err)
+			log.Errorf("Error starting client for token %d: %s", i, err)
 			continue
 		}
 
-		ac.RetrieveAll()
+		for _, ac := range clients {
+			ac.RetrieveAll()
+		}
 	}
 }
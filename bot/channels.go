@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+	"github.com/ashb/slackarchive/utils"
+)
+
+// channelEvent applies evt to its channel and adds it to the channel's
+// timeline, unless the channel already reflected it.
+func (ac *archiveClient) channelEvent(ctx context.Context, evt *models.ChannelEvent) error {
+	c, err := ac.channel(ctx, evt.ChannelID)
+	if err != nil {
+		return err
+	}
+	if !c.Apply(evt) {
+		return nil
+	}
+
+	if err := ac.ab.store.Channels().Upsert(c); err != nil {
+		return errors.Wrapf(err, "error upserting channel(%s)", c.ID)
+	}
+	err = ac.ab.store.Channels().AddEvent(evt)
+	return errors.Wrapf(err, "error adding %s event of channel(%s)", evt.Type, c.ID)
+}
+
+// channel loads a channel, asking Slack about ones we haven't archived yet.
+func (ac *archiveClient) channel(ctx context.Context, id string) (*models.Channel, error) {
+	c, err := ac.ab.store.Channels().Get(id)
+	if err != storage.ErrNotFound {
+		return c, err
+	}
+
+	info, err := ac.GetConversationInfoContext(ctx, id, false)
+	if err != nil {
+		return nil, errors.WithMessage(err, "GetConversationInfo")
+	}
+	c = &models.Channel{TeamID: ac.Team.ID}
+	if err := utils.Merge(c, *info); err != nil {
+		return nil, errors.Wrapf(err, "error merging channel(%s)", id)
+	}
+	if err := ac.ab.store.Channels().Upsert(c); err != nil {
+		return nil, errors.Wrapf(err, "error upserting channel(%s)", id)
+	}
+	return c, nil
+}
+
+// recordChannelChanges adds the changes a sync found in a channel, which
+// we missed the events of, to its timeline.
+func (ac *archiveClient) recordChannelChanges(old *models.Channel, c *models.Channel) {
+	events := models.ChannelChanges(old, c, time.Now())
+	for i := range events {
+		if err := ac.ab.store.Channels().AddEvent(&events[i]); err != nil {
+			ac.channelLogger(c.ID).Errorf("Error adding %s event of channel: %s", events[i].Type, err)
+		}
+	}
+}
+
+// eventTime is when an event with the Slack timestamp ts happened, now if
+// it didn't come with one.
+func eventTime(ts string) time.Time {
+	if t, err := models.TimestampToTime(ts); err == nil && t != nil {
+		return *t
+	}
+	return time.Now()
+}
+
+func channelInfoEvent(typ string, evt *slack.ChannelInfoEvent) *models.ChannelEvent {
+	return &models.ChannelEvent{
+		ChannelID: evt.Channel,
+		Type:      typ,
+		UserID:    evt.User,
+		Timestamp: eventTime(evt.Timestamp),
+	}
+}
+
+// messageChannelEvent is the event of a channel_topic, channel_purpose or
+// channel_name message, or their group_ equivalents.
+func messageChannelEvent(msg *slack.Msg) *models.ChannelEvent {
+	evt := &models.ChannelEvent{
+		ChannelID: msg.Channel,
+		UserID:    msg.User,
+		Timestamp: eventTime(msg.Timestamp),
+	}
+	switch msg.SubType {
+	case slack.MsgSubTypeChannelTopic, slack.MsgSubTypeGroupTopic:
+		evt.Type, evt.Value = models.ChannelTopic, msg.Topic
+	case slack.MsgSubTypeChannelPurpose, slack.MsgSubTypeGroupPurpose:
+		evt.Type, evt.Value = models.ChannelPurpose, msg.Purpose
+	default:
+		evt.Type, evt.Name, evt.OldName = models.ChannelRenamed, msg.Name, msg.OldName
+	}
+	return evt
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/utils"
+)
+
+/* ImportExternalUser will create a record for the given userID if it doesn't
+*  already exist in the database.
+*
+*  Messages in Slack Connect channels come from users of other organisations
+*  that users.list never returns, so they are looked up one at a time the
+*  first time we see them. homeTeamID is the team the message says the user
+*  belongs to.
+ */
+func (ac *archiveClient) ImportExternalUser(ctx context.Context, userID string, homeTeamID string) error {
+	exists, err := ac.ab.store.Users().Exists(userID)
+	if err != nil || exists {
+		return err
+	}
+
+	user, err := ac.GetUserInfoContext(ctx, userID)
+	if err != nil {
+		// Users of an organisation that has since disconnected can't be looked
+		// up any more; keep a stub so their messages are still archived.
+		ac.logger().Warningf("Error querying external user(%s), storing a placeholder: %s", userID, err)
+		user = &slack.User{ID: userID, Name: userID, TeamID: homeTeamID, IsStranger: true}
+	}
+
+	return ac.UpsertExternalUser(ctx, *user)
+}
+
+func (ac *archiveClient) UpsertExternalUser(ctx context.Context, user slack.User) error {
+	u := &models.User{}
+	if err := utils.Merge(u, user); err != nil {
+		return errors.Wrapf(err, "error merging user(%s)", user.ID)
+	}
+
+	u.TeamID = ac.Team.ID
+	u.MergeEnterprise(&user)
+	u.MergeExternal(&user, ac.Team)
+
+	if u.IsExternal && u.HomeTeamID != "" {
+		if team, err := ac.getExternalTeamInfo(ctx, u.HomeTeamID); err == nil {
+			u.HomeTeamName = team.Name
+		} else {
+			ac.logger().Debugf("Error querying external team(%s): %s", u.HomeTeamID, err)
+		}
+	}
+
+	if err := ac.ab.store.Users().UpsertExternal(u); err != nil {
+		return errors.Wrapf(err, "error upserting external user(%s)", u.ID)
+	}
+	return ac.recordHistory(u)
+}
+
+// getExternalTeamInfo asks for the name of an organisation we share a Slack
+// Connect channel with.
+func (ac *archiveClient) getExternalTeamInfo(ctx context.Context, teamID string) (*teamInfo, error) {
+	response := struct {
+		slack.SlackResponse
+		Team teamInfo `json:"team"`
+	}{}
+
+	if err := ac.callMethod(ctx, "team.info", url.Values{"team": {teamID}}, &response); err != nil {
+		return nil, err
+	}
+	return &response.Team, nil
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// digestSize is how many matches a digest lists, the rest are only counted
+const digestSize = 10
+
+// SendDigests DMs the owners of the saved searches in this workspace that
+// asked for it the messages matching them archived since the last digest.
+// It runs after every sync, so the digest covers what the sync brought in.
+func (ac *archiveClient) SendDigests(ctx context.Context) error {
+	searches, err := ac.ab.store.SavedSearches().ListNotifying(ac.Team.ID)
+	if err != nil {
+		return errors.Wrap(err, "error listing saved searches")
+	}
+
+	for i := range searches {
+		if err := ac.sendDigest(ctx, &searches[i]); err != nil {
+			ac.logger().Errorf("Error sending digest of saved search %d to %s: %s", searches[i].ID, searches[i].UserID, err)
+		}
+	}
+	return nil
+}
+
+func (ac *archiveClient) sendDigest(ctx context.Context, search *models.SavedSearch) error {
+	since := search.CreatedAt
+	if search.LastNotifiedAt != nil {
+		since = *search.LastNotifiedAt
+	}
+	now := time.Now()
+
+	result, err := ac.ab.store.Messages().Search(storage.SavedSearchQuery(search, since, digestSize))
+	if err != nil {
+		return err
+	}
+
+	if result.TotalCount > 0 {
+		for _, m := range result.Messages {
+			if _, err := ac.ab.redactor.Message(m.Msg); err != nil {
+				return errors.Wrap(err, "error redacting message")
+			}
+		}
+
+		// Posting to a user ID lands in the app's DM with them
+		if _, _, err := ac.PostMessageContext(ctx, search.UserID,
+			slack.MsgOptionText(digestText(search, result), false),
+			slack.MsgOptionDisableLinkUnfurl(),
+		); err != nil {
+			return err
+		}
+	}
+
+	search.LastNotifiedAt = &now
+	return ac.ab.store.SavedSearches().Update(search)
+}
+
+func digestText(search *models.SavedSearch, result *storage.MessageResult) string {
+	var b strings.Builder
+
+	matches := "messages match"
+	if result.TotalCount == 1 {
+		matches = "message matches"
+	}
+	fmt.Fprintf(&b, "%d new %s your saved search *%s* (`%s`)", result.TotalCount, matches, search.Name, search.Query)
+
+	for _, m := range result.Messages {
+		fmt.Fprintf(&b, "\n• <#%s> <@%s>: %s", m.ChannelID, m.UserID, digestSnippet(m.Msg.Text))
+	}
+	if more := result.TotalCount - len(result.Messages); more > 0 {
+		fmt.Fprintf(&b, "\n…and %d more in the archive", more)
+	}
+	return b.String()
+}
+
+// digestSnippet turns search highlights in to bold and keeps the text of a
+// message to a line.
+func digestSnippet(text string) string {
+	text = strings.NewReplacer("[hl]", "*", "[/hl]", "*", "\n", " ").Replace(text)
+	if runes := []rune(text); len(runes) > 200 {
+		text = string(runes[:200]) + "…"
+	}
+	return text
+}
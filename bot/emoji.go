@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// downloadClient fetches emoji images, which Slack serves from its CDN
+// without needing a token
+var downloadClient = &http.Client{Timeout: time.Minute}
+
+// syncEmoji brings the team's custom emoji up to date with emoji.list,
+// downloading the images of new and changed ones. Emoji removed from Slack
+// are only marked deleted, old messages still use them.
+func (ac *archiveClient) syncEmoji(ctx context.Context) error {
+	ac.logger().Info("Syncing emoji")
+
+	var list map[string]string
+	err := retry(ctx, func() (err error) {
+		list, err = ac.GetEmojiContext(ctx)
+		return err
+	})
+	if err != nil {
+		return errors.WithMessage(err, "GetEmoji")
+	}
+
+	existing, err := ac.ab.store.Emoji().List(ac.Team.ID)
+	if err != nil {
+		return errors.Wrap(err, "error listing emoji")
+	}
+	known := make(map[string]*models.Emoji, len(existing))
+	for i := range existing {
+		known[existing[i].Name] = &existing[i]
+	}
+
+	now := time.Now()
+	for name, value := range list {
+		if err := ac.saveEmoji(ctx, known, name, value, now); err != nil {
+			ac.logger().Errorf("Error saving emoji(%s): %s", name, err)
+		}
+	}
+
+	for _, e := range existing {
+		if _, ok := list[e.Name]; !ok && e.DeletedAt == nil {
+			if err := ac.ab.store.Emoji().Delete(ac.Team.ID, e.Name, now); err != nil {
+				ac.logger().Errorf("Error deleting emoji(%s): %s", e.Name, err)
+			}
+		}
+	}
+
+	ac.logger().Infof("Syncing emoji finished: %d emoji", len(list))
+	return nil
+}
+
+// saveEmoji stores the emoji called name with its value from emoji.list,
+// an image URL or alias:name. Images already downloaded for any of the
+// known emoji, say before a rename, aren't downloaded again.
+func (ac *archiveClient) saveEmoji(ctx context.Context, known map[string]*models.Emoji, name, value string, now time.Time) error {
+	url, aliasFor := models.ParseEmojiValue(value)
+	e := models.Emoji{
+		TeamID:    ac.Team.ID,
+		Name:      name,
+		AliasFor:  aliasFor,
+		URL:       url,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if old := known[name]; old != nil {
+		if old.DeletedAt == nil && old.URL == url && old.AliasFor == aliasFor && (url == "" || old.BlobKey != "") {
+			return nil
+		}
+		e.CreatedAt = old.CreatedAt
+	}
+
+	if url != "" {
+		for _, k := range known {
+			if k.URL == url && k.BlobKey != "" {
+				e.BlobKey, e.ContentType = k.BlobKey, k.ContentType
+				break
+			}
+		}
+	}
+	if url != "" && e.BlobKey == "" {
+		var err error
+		if e.BlobKey, e.ContentType, err = ac.download(ctx, url); err != nil {
+			// Keep it without its image, the next sync tries again
+			ac.logger().Errorf("Error downloading emoji(%s): %s", name, err)
+		}
+	}
+
+	if err := ac.ab.store.Emoji().Upsert(&e); err != nil {
+		return err
+	}
+	known[name] = &e
+	return nil
+}
+
+// download stores the file at url in the blob store, returning its key and
+// content type.
+func (ac *archiveClient) download(ctx context.Context, url string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	key, err := ac.ab.blobs.Put(resp.Body)
+	return key, resp.Header.Get("Content-Type"), err
+}
+
+// emojiChanged applies an emoji_changed event. Renames only tell us the new
+// name, so they sync the whole list, which keeps the old name as deleted.
+func (ac *archiveClient) emojiChanged(ctx context.Context, evt *slack.EmojiChangedEvent) error {
+	switch evt.SubType {
+	case "add":
+		known := map[string]*models.Emoji{}
+		if old, err := ac.ab.store.Emoji().Get(ac.Team.ID, evt.Name); err == nil {
+			known[old.Name] = old
+		} else if err != storage.ErrNotFound {
+			return err
+		}
+		return ac.saveEmoji(ctx, known, evt.Name, evt.Value, time.Now())
+	case "remove":
+		now := time.Now()
+		for _, name := range evt.Names {
+			if err := ac.ab.store.Emoji().Delete(ac.Team.ID, name, now); err != nil && err != storage.ErrNotFound {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ac.syncEmoji(ctx)
+	}
+}
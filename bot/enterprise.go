@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// teamScopedTransport adds the workspace to every Slack API call. Org-wide
+// Enterprise Grid tokens can see every workspace of the org, so methods like
+// users.list and conversations.list need to be told which one we mean.
+type teamScopedTransport struct {
+	TeamID string
+	Base   http.RoundTripper
+}
+
+func (t *teamScopedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	method := path.Base(req.URL.Path)
+
+	if req.Method == http.MethodGet {
+		values := req.URL.Query()
+		addTeam(values, method, t.TeamID)
+		req = req.Clone(req.Context())
+		req.URL.RawQuery = values.Encode()
+		return base.RoundTrip(req)
+	}
+
+	if req.Body == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return base.RoundTrip(req)
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	addTeam(values, method, t.TeamID)
+
+	encoded := values.Encode()
+	req = req.Clone(req.Context())
+	req.Body = ioutil.NopCloser(strings.NewReader(encoded))
+	req.ContentLength = int64(len(encoded))
+	return base.RoundTrip(req)
+}
+
+func addTeam(values url.Values, method string, teamID string) {
+	if values.Get("team_id") == "" {
+		values.Set("team_id", teamID)
+	}
+	// team.info is the odd one out
+	if method == "team.info" && values.Get("team") == "" {
+		values.Set("team", teamID)
+	}
+}
+
+// callMethod calls a Slack Web API method the slack library doesn't wrap (or
+// doesn't return all the fields of) and decodes the response in to intf,
+// which should embed slack.SlackResponse.
+func (ac *archiveClient) callMethod(ctx context.Context, method string, values url.Values, intf interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slack.APIURL+method, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+ac.tokens.OAuthToken)
+
+	resp, err := ac.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retry, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64)
+		if err != nil {
+			return err
+		}
+		return &slack.RateLimitedError{RetryAfter: time.Duration(retry) * time.Second}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return slack.StatusCodeError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var envelope slack.SlackResponse
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return errors.Wrapf(err, "decoding %s response", method)
+	}
+	if err := envelope.Err(); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(bytes.NewReader(body)).Decode(intf)
+}
+
+// teamInfo is slack.TeamInfo plus the Enterprise Grid fields team.info
+// returns for workspaces of an org.
+type teamInfo struct {
+	slack.TeamInfo
+	EnterpriseID   string `json:"enterprise_id"`
+	EnterpriseName string `json:"enterprise_name"`
+}
+
+func (ac *archiveClient) getTeamInfo(ctx context.Context) (*teamInfo, error) {
+	response := struct {
+		slack.SlackResponse
+		Team teamInfo `json:"team"`
+	}{}
+
+	if err := ac.callMethod(ctx, "team.info", url.Values{}, &response); err != nil {
+		return nil, err
+	}
+	return &response.Team, nil
+}
+
+// listOrgTeams returns the IDs of every workspace an org-wide token has been
+// granted access to.
+func (ac *archiveClient) listOrgTeams(ctx context.Context) ([]string, error) {
+	var ids []string
+
+	values := url.Values{"limit": {"100"}}
+	for {
+		response := struct {
+			slack.SlackResponse
+			Teams []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"teams"`
+		}{}
+
+		err := ac.callMethod(ctx, "auth.teams.list", values, &response)
+		if rateLimitedError, ok := err.(*slack.RateLimitedError); ok {
+			ac.logger().Infof("Rate limited for %s", rateLimitedError.RetryAfter)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(rateLimitedError.RetryAfter):
+				continue
+			}
+		} else if err != nil {
+			return nil, errors.Wrap(err, "auth.teams.list")
+		}
+
+		for _, team := range response.Teams {
+			ids = append(ids, team.ID)
+		}
+
+		if response.ResponseMetadata.Cursor == "" {
+			return ids, nil
+		}
+		values.Set("cursor", response.ResponseMetadata.Cursor)
+	}
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// held tells whether an active legal hold covers a message.
+func (ab *archiveBot) held(channelID, userID string, ts time.Time) (bool, error) {
+	holds, err := ab.store.LegalHolds().List(false)
+	if err != nil {
+		return false, errors.Wrap(err, "error listing legal holds")
+	}
+	return models.Held(holds, channelID, userID, ts), nil
+}
+
+// keepRevision saves the archived version of a held message before an edit
+// replaces it, unless the edit only updated its replies or reactions.
+func (ac *archiveClient) keepRevision(m *models.Message) error {
+	old, err := ac.ab.store.Messages().Get(m.ChannelID, *m.Timestamp)
+	if err == storage.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !models.ContentChanged(old.Msg, m.Msg) {
+		return nil
+	}
+
+	err = ac.ab.store.Messages().AddRevision(&models.MessageRevision{
+		ChannelID:  old.ChannelID,
+		UserID:     old.UserID,
+		Timestamp:  *old.Timestamp,
+		Msg:        old.Msg,
+		ReplacedAt: time.Now(),
+	})
+	return errors.Wrap(err, "error keeping message revision")
+}
+
+// deleteMessage removes a message deleted in Slack, or only marks it
+// deleted if it's under a legal hold.
+func (ac *archiveClient) deleteMessage(channelID, deletedTs, eventTs string) error {
+	ts, err := models.TimestampToTime(deletedTs)
+	if err != nil || ts == nil {
+		return err
+	}
+
+	m, err := ac.ab.store.Messages().Get(channelID, *ts)
+	if err == storage.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	held, err := ac.ab.held(channelID, m.UserID, *ts)
+	if err != nil {
+		return err
+	}
+	if !held {
+		return ac.ab.store.Messages().Delete(channelID, *ts)
+	}
+
+	err = ac.ab.store.Messages().Tombstone(channelID, *ts, eventTime(eventTs))
+	if err == storage.ErrNotFound {
+		// Already marked deleted
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/ashb/slackarchive/metrics"
+)
+
+// metricsTransport counts Slack API calls by method, and how long Slack
+// asks us to wait when it rate limits them.
+type metricsTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		metrics.SlackCall(path.Base(req.URL.Path), 0, 0)
+		return resp, err
+	}
+
+	var retryAfter time.Duration
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(s) * time.Second
+	}
+	metrics.SlackCall(path.Base(req.URL.Path), resp.StatusCode, retryAfter)
+	return resp, nil
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// syncPins brings a channel's pins up to date with pins.list. Slack doesn't
+// tell us who pinned items or when there, so pins we hadn't seen yet are
+// taken as pinned now.
+func (ac *archiveClient) syncPins(ctx context.Context, channelID string) error {
+	var items []slack.Item
+	err := retry(ctx, func() (err error) {
+		items, _, err = ac.ListPinsContext(ctx, channelID)
+		return err
+	})
+	if err != nil {
+		return errors.WithMessage(err, "ListPins")
+	}
+
+	now := time.Now()
+	current := make(map[string]bool, len(items))
+	for i := range items {
+		pin := models.NewPin(channelID, &items[i], "", now)
+		if pin == nil {
+			continue
+		}
+		current[pin.ItemID] = true
+		if expired, err := ac.expired(channelID, pin.Created()); err != nil {
+			return err
+		} else if expired {
+			continue
+		}
+		if _, err := ac.ab.storeRedactor().Item(pin.Item); err != nil {
+			return errors.Wrap(err, "error redacting pin")
+		}
+		if err := ac.ab.store.Pins().Upsert(pin); err != nil {
+			ac.channelLogger(channelID).Errorf("Error upserting pin(%s): %s", pin.ItemID, err)
+		}
+	}
+
+	existing, err := ac.ab.store.Pins().List(channelID, false)
+	if err != nil {
+		return errors.Wrapf(err, "error listing pins(%s)", channelID)
+	}
+	for _, pin := range existing {
+		if !current[pin.ItemID] {
+			if err := ac.ab.store.Pins().Remove(channelID, pin.ItemID, now); err != nil {
+				ac.channelLogger(channelID).Errorf("Error removing pin(%s): %s", pin.ItemID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// syncBookmarks brings a channel's bookmarks up to date with bookmarks.list.
+func (ac *archiveClient) syncBookmarks(ctx context.Context, channelID string) error {
+	var list []slack.Bookmark
+	err := retry(ctx, func() (err error) {
+		list, err = ac.ListBookmarksContext(ctx, channelID)
+		return err
+	})
+	if err != nil {
+		return errors.WithMessage(err, "ListBookmarks")
+	}
+
+	current := make(map[string]bool, len(list))
+	for i := range list {
+		current[list[i].ID] = true
+		b := models.NewBookmark(&list[i])
+		if expired, err := ac.expired(channelID, b.CreatedAt); err != nil {
+			return err
+		} else if expired {
+			continue
+		}
+		b.ChannelID = channelID
+		ac.ab.storeRedactor().Bookmark(b)
+		if err := ac.ab.store.Bookmarks().Upsert(b); err != nil {
+			ac.channelLogger(channelID).Errorf("Error upserting bookmark(%s): %s", b.ID, err)
+		}
+	}
+
+	existing, err := ac.ab.store.Bookmarks().List(channelID, false)
+	if err != nil {
+		return errors.Wrapf(err, "error listing bookmarks(%s)", channelID)
+	}
+	now := time.Now()
+	for _, b := range existing {
+		if !current[b.ID] {
+			if err := ac.ab.store.Bookmarks().Remove(b.ID, now); err != nil {
+				ac.channelLogger(channelID).Errorf("Error removing bookmark(%s): %s", b.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pinChanged applies a pin_added or pin_removed event.
+func (ac *archiveClient) pinChanged(ctx context.Context, channelID string, item *slack.Item, user, ts string, added bool) error {
+	if _, err := ac.channel(ctx, channelID); err != nil {
+		return err
+	}
+	if !added {
+		// Unpinning items pinned before we archived pins is nothing to record
+		err := ac.ab.store.Pins().Remove(channelID, models.PinItemID(item), eventTime(ts))
+		if err == storage.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	pin := models.NewPin(channelID, item, user, eventTime(ts))
+	if pin == nil {
+		return nil
+	}
+	if expired, err := ac.expired(channelID, pin.Created()); err != nil || expired {
+		return err
+	}
+	if _, err := ac.ab.storeRedactor().Item(pin.Item); err != nil {
+		return errors.Wrap(err, "error redacting pin")
+	}
+	return ac.ab.store.Pins().Upsert(pin)
+}
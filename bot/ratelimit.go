@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// retry calls fn until Slack stops rate limiting it, waiting as long as
+// Slack asks in between.
+func retry(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+		rateLimitedError, ok := err.(*slack.RateLimitedError)
+		if !ok {
+			return err
+		}
+		log.Infof("Rate limited for %s", rateLimitedError.RetryAfter)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rateLimitedError.RetryAfter):
+		}
+	}
+}
+
+// missingScope tells whether Slack refused a call because the token wasn't
+// granted the scope it needs, however many messages err was wrapped in.
+func missingScope(err error) bool {
+	return err != nil && errors.Cause(err).Error() == "missing_scope"
+}
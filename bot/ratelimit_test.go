@@ -0,0 +1,27 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestMissingScope(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("missing_scope"), true},
+		{errors.WithMessage(errors.New("missing_scope"), "ListPins"), true},
+		{errors.WithMessage(errors.New("missing_scope"), "ListBookmarks"), true},
+		{errors.Wrap(errors.New("missing_scope"), "error syncing"), true},
+		{errors.WithMessage(errors.New("channel_not_found"), "ListPins"), false},
+		{errors.New("ListPins: missing_scope"), false},
+	}
+	for _, tt := range tests {
+		if got := missingScope(tt.err); got != tt.want {
+			t.Errorf("missingScope(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
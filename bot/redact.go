@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/redact"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// storeRedactor redacts messages on their way in to the archive, it's nil
+// (redacting nothing) unless the redaction mode is store. Messages the bot
+// sends, like digests, are redacted in either mode.
+func (ab *archiveBot) storeRedactor() *redact.Redactor {
+	if !ab.config.Redaction.Store() {
+		return nil
+	}
+	return ab.redactor
+}
+
+// RedactResult counts what Redact went through.
+type RedactResult struct {
+	Messages int
+	Redacted int
+	// Held are the messages left alone because a legal hold covers them
+	Held int
+	// Pins are the current pins redacted
+	Pins int
+	// Bookmarks are the current bookmarks redacted
+	Bookmarks int
+}
+
+// Redact applies the store redaction to everything archived before it was
+// turned on or its detectors changed. Messages under a legal hold are kept
+// as they are. With dryRun nothing is written, only counted.
+func (ab *archiveBot) Redact(ctx context.Context, dryRun bool) (RedactResult, error) {
+	var result RedactResult
+	redactor := ab.storeRedactor()
+	if redactor == nil {
+		return result, nil
+	}
+
+	holds, err := ab.store.LegalHolds().List(false)
+	if err != nil {
+		return result, errors.Wrap(err, "error listing legal holds")
+	}
+
+	var after *storage.Cursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		messages, err := ab.store.Messages().List(after, 500)
+		if err != nil {
+			return result, errors.Wrap(err, "error listing messages")
+		}
+		if len(messages) == 0 {
+			break
+		}
+		after = storage.CursorOf(&messages[len(messages)-1])
+
+		for i := range messages {
+			m := &messages[i]
+			result.Messages++
+			if models.Held(holds, m.ChannelID, m.UserID, *m.Timestamp) {
+				result.Held++
+				continue
+			}
+
+			n, err := redactor.Message(m.Msg)
+			if err != nil {
+				return result, errors.Wrapf(err, "error redacting message(%s/%s)", m.ChannelID, models.TimeToTimestamp(*m.Timestamp))
+			} else if n == 0 {
+				continue
+			}
+			result.Redacted++
+			if dryRun {
+				continue
+			}
+			if err := ab.store.Messages().Upsert(m); err != nil {
+				return result, errors.Wrap(err, "error upserting message")
+			}
+		}
+	}
+
+	pager := storage.Pager{Limit: 100}
+	for {
+		channels, total, err := ab.store.Channels().List(storage.ChannelFilter{Pager: pager})
+		if err != nil {
+			return result, errors.Wrap(err, "error listing channels")
+		}
+
+		for _, c := range channels {
+			if heldChannel(holds, c.ID) {
+				continue
+			}
+			// Upserting brings back removed pins, so only current ones
+			pins, err := ab.store.Pins().List(c.ID, false)
+			if err != nil {
+				return result, errors.Wrapf(err, "error listing pins(%s)", c.ID)
+			}
+			for i := range pins {
+				n, err := redactor.Item(pins[i].Item)
+				if err != nil {
+					return result, errors.Wrap(err, "error redacting pin")
+				} else if n == 0 {
+					continue
+				}
+				result.Pins++
+				if dryRun {
+					continue
+				}
+				if err := ab.store.Pins().Upsert(&pins[i]); err != nil {
+					return result, errors.Wrap(err, "error upserting pin")
+				}
+			}
+
+			bookmarks, err := ab.store.Bookmarks().List(c.ID, false)
+			if err != nil {
+				return result, errors.Wrapf(err, "error listing bookmarks(%s)", c.ID)
+			}
+			for i := range bookmarks {
+				if redactor.Bookmark(&bookmarks[i]) == 0 {
+					continue
+				}
+				result.Bookmarks++
+				if dryRun {
+					continue
+				}
+				if err := ab.store.Bookmarks().Upsert(&bookmarks[i]); err != nil {
+					return result, errors.Wrap(err, "error upserting bookmark")
+				}
+			}
+		}
+
+		pager.Offset += len(channels)
+		if len(channels) == 0 || pager.Offset >= total {
+			break
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+// Purge deletes the messages past their channel's retention policy, a batch
+// at a time, and adds what it deleted to the purge audit log.
+func (ab *archiveBot) Purge(ctx context.Context) ([]models.Purge, error) {
+	if !ab.config.Retention.Enabled() {
+		return nil, nil
+	}
+	log.Info("Purging messages past retention")
+
+	var (
+		purged []models.Purge
+		now    = time.Now()
+		pager  = storage.Pager{Limit: 100}
+	)
+	for {
+		channels, total, err := ab.store.Channels().List(storage.ChannelFilter{Pager: pager})
+		if err != nil {
+			return purged, errors.Wrap(err, "error listing channels")
+		}
+
+		for i := range channels {
+			p, err := ab.purgeChannel(ctx, &channels[i], now)
+			if p != nil {
+				purged = append(purged, *p)
+			}
+			if err == context.Canceled || err == context.DeadlineExceeded {
+				return purged, err
+			} else if err != nil {
+				log.WithFields(logrus.Fields{"team": channels[i].TeamID, "channel": channels[i].ID}).Errorf("Error purging channel: %s", err)
+			}
+		}
+
+		pager.Offset += len(channels)
+		if len(channels) == 0 || pager.Offset >= total {
+			break
+		}
+	}
+
+	log.Infof("Purging messages past retention finished: %d channels purged", len(purged))
+	return purged, nil
+}
+
+// purgeChannel deletes the messages of a channel past its retention policy
+// at now and records the purge, if there was anything to delete. A purge
+// interrupted by an error is recorded as far as it went.
+func (ab *archiveBot) purgeChannel(ctx context.Context, c *models.Channel, now time.Time) (*models.Purge, error) {
+	retention, policy := ab.config.Retention.Policy(c.TeamID, c.ID)
+	if retention.Forever() {
+		return nil, nil
+	}
+
+	p := &models.Purge{
+		TeamID:    c.TeamID,
+		ChannelID: c.ID,
+		Policy:    policy,
+		Before:    retention.Cutoff(now),
+		StartedAt: time.Now(),
+	}
+
+	holds, err := ab.store.LegalHolds().List(false)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing legal holds")
+	}
+
+	err = ab.purgeMessages(ctx, p, holds)
+	if err == nil && !heldChannel(holds, c.ID) {
+		// Pins and bookmarks don't tell whose message they are, keep them
+		// all while anything in the channel is held
+		p.Pins, err = ab.store.Purges().Pins(c.ID, p.Before)
+		if err == nil {
+			p.Bookmarks, err = ab.store.Purges().Bookmarks(c.ID, p.Before)
+		}
+	}
+	if p.Messages == 0 && p.Pins == 0 && p.Bookmarks == 0 {
+		return nil, err
+	}
+
+	clog := log.WithFields(logrus.Fields{"team": c.TeamID, "channel": c.ID})
+	p.FinishedAt = time.Now()
+	if err := ab.store.Purges().Add(p); err != nil {
+		clog.Errorf("Error recording purge of channel: %s", err)
+	}
+	clog.Infof("Purged %d messages, %d pins and %d bookmarks from before %s", p.Messages, p.Pins, p.Bookmarks, p.Before.Format(time.RFC3339))
+	return p, err
+}
+
+// purgeMessages deletes the messages of p's channel posted before p.Before,
+// except the ones under one of holds.
+func (ab *archiveBot) purgeMessages(ctx context.Context, p *models.Purge, holds []models.LegalHold) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		n, oldest, newest, err := ab.store.Purges().Messages(p.ChannelID, p.Before, holds, ab.config.Retention.BatchSize)
+		if err != nil || n == 0 {
+			return err
+		}
+		p.Messages += n
+		if p.Oldest == nil {
+			p.Oldest = oldest
+		}
+		p.Newest = newest
+	}
+}
+
+// expired tells whether a pin or bookmark of a channel created at created
+// is past retention, and mustn't be archived again. The purge keeps them
+// while anything in the channel is held, so syncs do too.
+func (ac *archiveClient) expired(channelID string, created time.Time) (bool, error) {
+	if !ac.ab.config.Retention.Expired(ac.Team.ID, channelID, created, time.Now()) {
+		return false, nil
+	}
+	holds, err := ac.ab.store.LegalHolds().List(false)
+	if err != nil {
+		return false, errors.Wrap(err, "error listing legal holds")
+	}
+	return !heldChannel(holds, channelID), nil
+}
+
+func heldChannel(holds []models.LegalHold, channelID string) bool {
+	for i := range holds {
+		if holds[i].InChannel(channelID) {
+			return true
+		}
+	}
+	return false
+}
+
+// purgeLoop runs the purge every retention.interval_hour.
+func (ab *archiveBot) purgeLoop() {
+	purge := func() {
+		if _, err := ab.Purge(context.Background()); err != nil {
+			log.Errorf("Purge error: %s", err)
+		}
+	}
+
+	purge()
+	ticker := time.NewTicker(time.Hour * time.Duration(ab.config.Retention.IntervalHour))
+	for range ticker.C {
+		purge()
+	}
+}
@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ashb/slackarchive/metrics"
+)
+
+// SyncStatus is how syncing a team is going.
+type SyncStatus struct {
+	TeamID string `json:"team_id"`
+	Domain string `json:"domain"`
+	// Started is when the bot started archiving the team
+	Started time.Time `json:"started"`
+	// LastSync is when the last successful sync finished, nil until the
+	// first one does
+	LastSync  *time.Time `json:"last_sync"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type syncStatuses struct {
+	mu    sync.Mutex
+	teams map[string]*SyncStatus
+}
+
+// syncStarted starts keeping track of a team's syncs.
+func (ab *archiveBot) syncStarted(teamID, domain string) {
+	ab.syncs.mu.Lock()
+	defer ab.syncs.mu.Unlock()
+	if ab.syncs.teams == nil {
+		ab.syncs.teams = map[string]*SyncStatus{}
+	}
+	if _, ok := ab.syncs.teams[teamID]; !ok {
+		ab.syncs.teams[teamID] = &SyncStatus{TeamID: teamID, Domain: domain, Started: time.Now()}
+	}
+}
+
+// syncFinished records the end of a sync of a team that started at started.
+func (ab *archiveBot) syncFinished(teamID string, started time.Time, err error) {
+	metrics.SyncFinished(teamID, started, err)
+
+	ab.syncs.mu.Lock()
+	defer ab.syncs.mu.Unlock()
+	s, ok := ab.syncs.teams[teamID]
+	if !ok {
+		return
+	}
+	if err != nil {
+		s.LastError = err.Error()
+		return
+	}
+	now := time.Now()
+	s.LastSync = &now
+	s.LastError = ""
+}
+
+// SyncStatus returns how syncing each team is going, by team ID.
+func (ab *archiveBot) SyncStatus() []SyncStatus {
+	ab.syncs.mu.Lock()
+	defer ab.syncs.mu.Unlock()
+	statuses := make([]SyncStatus, 0, len(ab.syncs.teams))
+	for _, s := range ab.syncs.teams {
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].TeamID < statuses[j].TeamID
+	})
+	return statuses
+}
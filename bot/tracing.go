@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"path"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ashb/slackarchive/tracing"
+)
+
+// tracingTransport traces Slack API calls made as part of a sync.
+type tracingTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !tracing.Traced(req.Context()) {
+		return base.RoundTrip(req)
+	}
+
+	method := path.Base(req.URL.Path)
+	ctx, span := tracing.Tracer().Start(req.Context(), "slack "+method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("slack.method", method), semconv.HTTPMethodKey.String(req.Method)))
+	defer span.End()
+
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, trace.SpanKindClient))
+	return resp, nil
+}
+
+// startSync starts the trace of a sync of the client's team.
+func (ac *archiveClient) startSync(name string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(context.Background(), name,
+		trace.WithAttributes(attribute.String("slack.team", ac.Team.ID)))
+}
+
+// endSync ends the trace of a sync that failed with err, if it did.
+func endSync(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
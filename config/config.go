@@ -1,8 +1,14 @@
 package config
 
 import (
+	"fmt"
 	yaml "gopkg.in/yaml.v2"
 	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/tappleby/slack_auth_proxy/slack"
 )
@@ -10,6 +16,236 @@ import (
 type TokenConfig struct {
 	BotToken   string `yaml:"bot"`
 	OAuthToken string `yaml:"oauth"`
+
+	// Enterprise marks an org-wide token from an Enterprise Grid install. The
+	// bot archives every workspace the app is installed in, or only those
+	// listed in Teams.
+	Enterprise bool     `yaml:"enterprise"`
+	Teams      []string `yaml:"teams"`
+}
+
+// SearchConfig picks the Postgres text search configuration (english,
+// german, simple, ...) messages are indexed with. Channels override their
+// team, which overrides Language. "cjk" indexes Chinese, Japanese and Korean
+// text character by character; with the zhparser extension installed, name
+// its configuration instead.
+type SearchConfig struct {
+	Language string            `yaml:"language"`
+	Teams    map[string]string `yaml:"teams"`
+	Channels map[string]string `yaml:"channels"`
+}
+
+// RetentionConfig is how long messages are kept before the purge deletes
+// them: a number of days, weeks, months or years ("90d", "6w", "18m",
+// "3y") or "forever". Channels override their team, which overrides
+// Default. Without any policy nothing is ever deleted.
+type RetentionConfig struct {
+	Default  string            `yaml:"default"`
+	Teams    map[string]string `yaml:"teams"`
+	Channels map[string]string `yaml:"channels"`
+
+	// IntervalHour is how often the purge runs, daily by default
+	IntervalHour int `yaml:"interval_hour"`
+	// BatchSize is how many messages are deleted at a time
+	BatchSize int `yaml:"batch_size"`
+}
+
+// Retention is how long messages are kept, the zero value meaning forever.
+type Retention struct {
+	Years, Months, Days int
+}
+
+func (r Retention) Forever() bool {
+	return r == Retention{}
+}
+
+// Cutoff is the time messages posted before are past retention at now.
+func (r Retention) Cutoff(now time.Time) time.Time {
+	return now.AddDate(-r.Years, -r.Months, -r.Days)
+}
+
+// ParseRetention parses a retention period like "3y" or "forever".
+func ParseRetention(s string) (Retention, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" || s == "forever" {
+		return Retention{}, nil
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return Retention{}, fmt.Errorf("invalid retention period %q", s)
+	}
+	switch s[len(s)-1] {
+	case 'd':
+		return Retention{Days: n}, nil
+	case 'w':
+		return Retention{Days: 7 * n}, nil
+	case 'm':
+		return Retention{Months: n}, nil
+	case 'y':
+		return Retention{Years: n}, nil
+	}
+	return Retention{}, fmt.Errorf("invalid retention period %q, use d, w, m or y", s)
+}
+
+// Policy returns the retention period configured for a channel of a team,
+// and how it was written.
+func (c RetentionConfig) Policy(teamID, channelID string) (Retention, string) {
+	policy := c.Default
+	if p, ok := c.Teams[teamID]; ok {
+		policy = p
+	}
+	if p, ok := c.Channels[channelID]; ok {
+		policy = p
+	}
+	// Load has already checked them all
+	r, _ := ParseRetention(policy)
+	return r, policy
+}
+
+// Expired tells whether a message of a channel posted at posted is past
+// its retention at now, and mustn't be archived again.
+func (c RetentionConfig) Expired(teamID, channelID string, posted, now time.Time) bool {
+	r, _ := c.Policy(teamID, channelID)
+	return !r.Forever() && posted.Before(r.Cutoff(now))
+}
+
+// Enabled tells whether any policy deletes messages.
+func (c RetentionConfig) Enabled() bool {
+	policies := []string{c.Default}
+	for _, p := range c.Teams {
+		policies = append(policies, p)
+	}
+	for _, p := range c.Channels {
+		policies = append(policies, p)
+	}
+	for _, p := range policies {
+		if r, _ := ParseRetention(p); !r.Forever() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c RetentionConfig) check() error {
+	if _, err := ParseRetention(c.Default); err != nil {
+		return err
+	}
+	for id, p := range c.Teams {
+		if _, err := ParseRetention(p); err != nil {
+			return fmt.Errorf("team %s: %s", id, err)
+		}
+	}
+	for id, p := range c.Channels {
+		if _, err := ParseRetention(p); err != nil {
+			return fmt.Errorf("channel %s: %s", id, err)
+		}
+	}
+	return nil
+}
+
+// RedactionConfig replaces secrets and personal data in messages with
+// placeholders like [redacted email]. Mode store redacts messages before
+// they're archived, output only in API responses, keeping the database
+// intact; redaction is off without a mode. Detectors picks built-in ones
+// (api_keys, aws_keys, credit_cards, emails, phone_numbers), all but
+// phone_numbers by default. Patterns adds regular expressions, when one
+// has a group only the group is replaced.
+type RedactionConfig struct {
+	Mode      string             `yaml:"mode"`
+	Detectors []string           `yaml:"detectors"`
+	Patterns  []RedactionPattern `yaml:"patterns"`
+}
+
+type RedactionPattern struct {
+	Name   string `yaml:"name"`
+	Regexp string `yaml:"regexp"`
+}
+
+const (
+	RedactStore  = "store"
+	RedactOutput = "output"
+)
+
+// Store tells whether messages are redacted before they're archived.
+func (c RedactionConfig) Store() bool {
+	return c.Mode == RedactStore
+}
+
+// Output tells whether messages are only redacted in API responses.
+func (c RedactionConfig) Output() bool {
+	return c.Mode == RedactOutput
+}
+
+func (c RedactionConfig) check() error {
+	switch c.Mode {
+	case "", RedactStore, RedactOutput:
+	default:
+		return fmt.Errorf("invalid redaction mode %q, use store or output", c.Mode)
+	}
+	for _, p := range c.Patterns {
+		if p.Name == "" {
+			return fmt.Errorf("redaction pattern %q needs a name", p.Regexp)
+		}
+		if _, err := regexp.Compile(p.Regexp); err != nil {
+			return fmt.Errorf("redaction pattern %s: %s", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// LoggingConfig sets how logs are written: format text (the default) or
+// json, one object per line, and the level, debug, info (the default),
+// warning or error. Levels overrides it per subsystem: main, api, http,
+// bot, storage, importer and tracing.
+type LoggingConfig struct {
+	Format string            `yaml:"format"`
+	Level  string            `yaml:"level"`
+	Levels map[string]string `yaml:"levels"`
+}
+
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+func (c LoggingConfig) check() error {
+	switch c.Format {
+	case "", LogText, LogJSON:
+	default:
+		return fmt.Errorf("invalid logging format %q, use text or json", c.Format)
+	}
+	return nil
+}
+
+// TracingConfig sends OpenTelemetry traces of API requests, database
+// queries and Slack API calls somewhere: exporter otlp sends them to a
+// collector at Endpoint (localhost:4318 by default) over OTLP/HTTP, stdout
+// prints them. Tracing is off without an exporter. SampleRatio is the
+// share of traces kept, all of them by default.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+const (
+	TraceOTLP   = "otlp"
+	TraceStdout = "stdout"
+)
+
+func (c TracingConfig) check() error {
+	switch c.Exporter {
+	case "", TraceOTLP, TraceStdout:
+	default:
+		return fmt.Errorf("invalid tracing exporter %q, use otlp or stdout", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample_ratio %v isn't between 0 and 1", c.SampleRatio)
+	}
+	return nil
 }
 
 type Config struct {
@@ -24,6 +260,8 @@ type Config struct {
 
 	BotTokens []TokenConfig `yaml:"bot_tokens"`
 
+	Search SearchConfig `yaml:"search"`
+
 	Slack struct {
 		ClientId     string `yaml:"client_id"`
 		ClientSecret string `yaml:"client_secret"`
@@ -34,10 +272,31 @@ type Config struct {
 		EncryptionKey     string `yaml:"encryption_key"`
 	} `yaml:"cookies"`
 
+	// Admins are the Slack user IDs allowed to use /v1/admin once signed
+	// in with Slack
+	Admins []string `yaml:"admins"`
+
 	Data string `yaml:"data"`
 
+	// Blobs is where downloaded files like custom emoji are kept, blobs
+	// in the data directory by default
+	Blobs struct {
+		Path string `yaml:"path"`
+	} `yaml:"blobs"`
+
+	Retention RetentionConfig `yaml:"retention"`
+
+	Redaction RedactionConfig `yaml:"redaction"`
+
+	Logging LoggingConfig `yaml:"logging"`
+
+	Tracing TracingConfig `yaml:"tracing"`
+
 	SyncIntervalMinute int `yaml:"sync_interval_minute"`
 	SyncRecentDay int `yaml:"sync_recent_day"`
+	// SyncLagMinute is how long a team can go without a successful sync
+	// before /readyz fails, three sync intervals by default
+	SyncLagMinute int `yaml:"sync_lag_minute"`
 }
 
 func Load(path string) (*Config, error) {
@@ -68,6 +327,10 @@ func (c *Config) Load(path string) error {
 		c.Data = "."
 	}
 
+	if c.Blobs.Path == "" {
+		c.Blobs.Path = filepath.Join(c.Data, "blobs")
+	}
+
 	if c.Listen == "" {
 		c.Listen = "127.0.0.1:8080"
 	}
@@ -80,6 +343,42 @@ func (c *Config) Load(path string) error {
 		c.SyncRecentDay = 30
 	}
 
+	if c.SyncLagMinute <= 0 {
+		c.SyncLagMinute = 3 * c.SyncIntervalMinute
+	}
+
+	if err = c.Retention.check(); err != nil {
+		return err
+	}
+
+	if err = c.Redaction.check(); err != nil {
+		return err
+	}
+
+	if err = c.Logging.check(); err != nil {
+		return err
+	}
+
+	if err = c.Tracing.check(); err != nil {
+		return err
+	}
+
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = "slackarchive"
+	}
+
+	if c.Tracing.SampleRatio == 0 {
+		c.Tracing.SampleRatio = 1
+	}
+
+	if c.Retention.IntervalHour <= 0 {
+		c.Retention.IntervalHour = 24
+	}
+
+	if c.Retention.BatchSize <= 0 {
+		c.Retention.BatchSize = 1000
+	}
+
 	err = c.init()
 	return err
 }
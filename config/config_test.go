@@ -0,0 +1,105 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Retention
+	}{
+		{"", Retention{}},
+		{"forever", Retention{}},
+		{" Forever ", Retention{}},
+		{"90d", Retention{Days: 90}},
+		{"6w", Retention{Days: 42}},
+		{"18m", Retention{Months: 18}},
+		{"3y", Retention{Years: 3}},
+		{"3Y", Retention{Years: 3}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRetention(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRetention(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"d", "0d", "-1y", "1.5y", "10", "10h", "y3", "never"} {
+		if got, err := ParseRetention(in); err == nil {
+			t.Errorf("ParseRetention(%q) = %+v, want an error", in, got)
+		}
+	}
+}
+
+func TestRetentionExpired(t *testing.T) {
+	conf := RetentionConfig{
+		Default:  "1y",
+		Teams:    map[string]string{"T2": "30d", "T3": "forever"},
+		Channels: map[string]string{"C2": "forever", "C3": "1w"},
+	}
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		team, channel string
+		posted        time.Time
+		want          bool
+	}{
+		{"T1", "C1", now.AddDate(-1, 0, -1), true},
+		{"T1", "C1", now.AddDate(-1, 0, 0), false},
+		{"T1", "C1", now.AddDate(-1, 0, 0).Add(-time.Second), true},
+		{"T1", "C1", now, false},
+		{"T2", "C1", now.AddDate(0, 0, -31), true},
+		{"T2", "C1", now.AddDate(0, 0, -29), false},
+		{"T3", "C1", time.Unix(0, 0), false},
+		// Channels override their team
+		{"T2", "C2", time.Unix(0, 0), false},
+		{"T3", "C3", now.AddDate(0, 0, -8), true},
+		{"T3", "C3", now.AddDate(0, 0, -6), false},
+	}
+	for _, tt := range tests {
+		if got := conf.Expired(tt.team, tt.channel, tt.posted, now); got != tt.want {
+			t.Errorf("Expired(%s, %s, %s) = %v, want %v", tt.team, tt.channel, tt.posted, got, tt.want)
+		}
+	}
+
+	if (RetentionConfig{}).Expired("T1", "C1", time.Unix(0, 0), now) {
+		t.Error("Expired without a policy")
+	}
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		r    Retention
+		want time.Time
+	}{
+		{Retention{Days: 90}, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Retention{Months: 1}, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{Retention{Years: 1}, time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Cutoff(now); !got.Equal(tt.want) {
+			t.Errorf("%+v Cutoff() = %s, want %s", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestRetentionEnabled(t *testing.T) {
+	tests := []struct {
+		conf RetentionConfig
+		want bool
+	}{
+		{RetentionConfig{}, false},
+		{RetentionConfig{Default: "forever", Channels: map[string]string{"C1": "forever"}}, false},
+		{RetentionConfig{Default: "1y"}, true},
+		{RetentionConfig{Teams: map[string]string{"T1": "30d"}}, true},
+		{RetentionConfig{Default: "forever", Channels: map[string]string{"C1": "1w"}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.conf.Enabled(); got != tt.want {
+			t.Errorf("%+v Enabled() = %v, want %v", tt.conf, got, tt.want)
+		}
+	}
+}
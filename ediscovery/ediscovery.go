@@ -0,0 +1,321 @@
+// Package ediscovery exports the messages legal asks for, say everything
+// three people said between two dates, in a form review tools take: one
+// EML or printable HTML document per message, a CSV load file listing them
+// and a manifest with the query and the SHA-256 hash of every file, for the
+// chain of custody.
+package ediscovery
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/render"
+	"github.com/ashb/slackarchive/storage"
+)
+
+const (
+	FormatEML  = "eml"
+	FormatHTML = "html"
+)
+
+const (
+	LoadFileName = "loadfile.csv"
+	ManifestName = "manifest.json"
+)
+
+// Request is what to export and how.
+type Request struct {
+	storage.DiscoveryQuery
+	Format string `json:"format"`
+}
+
+// Validate checks the format, defaulting to EML, and the dates.
+func (r *Request) Validate() error {
+	switch r.Format {
+	case "":
+		r.Format = FormatEML
+	case FormatEML, FormatHTML:
+	default:
+		return fmt.Errorf("unknown format %q, use eml or html", r.Format)
+	}
+	if r.From != nil && r.To != nil && r.To.Before(*r.From) {
+		return errors.New("to must be after from")
+	}
+	return nil
+}
+
+// ParseDate reads an RFC 3339 time or a date, 2006-01-02, in UTC. Dates
+// are the start of the day, or its end with end set, so a range of dates
+// takes in both days.
+func ParseDate(s string, end bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, use 2006-01-02 or an RFC 3339 time", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+	return &t, nil
+}
+
+// Archive is where an export is written: a directory or a zip file.
+type Archive interface {
+	Add(name string, data []byte) error
+}
+
+// Dir writes an export in to a directory.
+type Dir string
+
+func (d Dir) Add(name string, data []byte) error {
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, 0644)
+}
+
+// Zip writes an export in to a zip file.
+type Zip struct {
+	*zip.Writer
+}
+
+func (z Zip) Add(name string, data []byte) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Item is a file of an export.
+type Item struct {
+	// DocID identifies the message in the load file
+	DocID  string `json:"doc_id,omitempty"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// Manifest records how an export was made and what's in it.
+type Manifest struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	GeneratedBy string    `json:"generated_by"`
+	Query       Request   `json:"query"`
+	Messages    int       `json:"messages"`
+	Items       []Item    `json:"items"`
+	LoadFile    Item      `json:"load_file"`
+}
+
+var loadFileHeader = []string{
+	"doc_id", "path", "sha256", "channel_id", "channel_name", "user_id", "user_name",
+	"sent_at", "timestamp", "thread_timestamp", "deleted_at",
+}
+
+// batchSize is how many messages are loaded at a time.
+const batchSize = 500
+
+// Export writes every message req selects to archive, oldest first, then
+// the load file and the manifest, which it also returns. by says who asked
+// for the export.
+func Export(store storage.Store, req Request, by string, archive Archive) (*Manifest, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	e := &exporter{
+		store:    store,
+		resolver: newResolver(store),
+		manifest: &Manifest{GeneratedAt: time.Now().UTC(), GeneratedBy: by, Query: req, Items: []Item{}},
+	}
+	e.renderer = render.New(e.resolver)
+
+	var loadFile bytes.Buffer
+	w := csv.NewWriter(&loadFile)
+	w.Write(loadFileHeader)
+
+	var after *storage.Cursor
+	for {
+		messages, err := store.Messages().Discover(req.DiscoveryQuery, after, batchSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "error selecting messages")
+		}
+		for i := range messages {
+			m := &messages[i]
+			item, err := e.add(m, req.Format, archive)
+			if err != nil {
+				return nil, err
+			}
+			w.Write(e.loadFileRow(m, item))
+		}
+		if len(messages) < batchSize {
+			break
+		}
+		after = storage.CursorOf(&messages[len(messages)-1])
+	}
+
+	if w.Flush(); w.Error() != nil {
+		return nil, w.Error()
+	}
+	item, err := put(archive, LoadFileName, loadFile.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "error writing load file")
+	}
+	e.manifest.LoadFile = item
+
+	b, err := json.MarshalIndent(e.manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := put(archive, ManifestName, append(b, '\n')); err != nil {
+		return nil, errors.Wrap(err, "error writing manifest")
+	}
+	return e.manifest, nil
+}
+
+type exporter struct {
+	store    storage.Store
+	resolver *resolver
+	renderer *render.Renderer
+	manifest *Manifest
+}
+
+// add writes a message as a document of its own.
+func (e *exporter) add(m *models.Message, format string, archive Archive) (Item, error) {
+	e.manifest.Messages++
+	docID := fmt.Sprintf("MSG%07d", e.manifest.Messages)
+
+	var (
+		data []byte
+		err  error
+	)
+	switch format {
+	case FormatEML:
+		data, err = e.eml(m, docID)
+	case FormatHTML:
+		data = []byte(e.page(m, docID))
+	}
+	if err != nil {
+		return Item{}, errors.Wrapf(err, "error exporting message(%s/%s)", m.ChannelID, models.TimeToTimestamp(*m.Timestamp))
+	}
+
+	name := path.Join("messages", fileName(e.resolver.channelName(m.ChannelID), m.ChannelID), docID+"."+format)
+	item, err := put(archive, name, data)
+	if err != nil {
+		return Item{}, errors.Wrapf(err, "error writing %s", name)
+	}
+	item.DocID = docID
+	e.manifest.Items = append(e.manifest.Items, item)
+	return item, nil
+}
+
+func (e *exporter) loadFileRow(m *models.Message, item Item) []string {
+	var threadTs, deletedAt string
+	if m.ThreadTimestamp != nil {
+		threadTs = models.TimeToTimestamp(*m.ThreadTimestamp)
+	}
+	if m.DeletedAt != nil {
+		deletedAt = m.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		item.DocID, item.Path, item.SHA256, m.ChannelID, e.resolver.channelName(m.ChannelID),
+		m.UserID, e.resolver.user(m.UserID).Name,
+		m.Timestamp.UTC().Format(time.RFC3339), models.TimeToTimestamp(*m.Timestamp), threadTs, deletedAt,
+	}
+}
+
+// put adds a file to archive and hashes it.
+func put(archive Archive, name string, data []byte) (Item, error) {
+	sum := sha256.Sum256(data)
+	return Item{Path: name, SHA256: hex.EncodeToString(sum[:]), Size: len(data)}, archive.Add(name, data)
+}
+
+// fileName is the directory of a channel: its name, and its ID in case two
+// channels had the same name.
+func fileName(name, id string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return id
+	}
+	return name + "-" + id
+}
+
+// resolver looks up and remembers the users and channels of the exported
+// messages. Custom emoji are left as :name:, exports shouldn't depend on
+// images served by the archive.
+type resolver struct {
+	store    storage.Store
+	users    map[string]*models.User
+	channels map[string]*models.Channel
+}
+
+func newResolver(store storage.Store) *resolver {
+	return &resolver{store: store, users: map[string]*models.User{}, channels: map[string]*models.Channel{}}
+}
+
+func (r *resolver) user(id string) *models.User {
+	u, ok := r.users[id]
+	if !ok {
+		var err error
+		if u, err = r.store.Users().Get(id); err != nil {
+			u = &models.User{ID: id}
+		}
+		r.users[id] = u
+	}
+	return u
+}
+
+func (r *resolver) channel(id string) *models.Channel {
+	c, ok := r.channels[id]
+	if !ok {
+		var err error
+		if c, err = r.store.Channels().Get(id); err != nil {
+			c = &models.Channel{ID: id}
+		}
+		r.channels[id] = c
+	}
+	return c
+}
+
+func (r *resolver) channelName(id string) string {
+	return r.channel(id).Name
+}
+
+func (r *resolver) UserName(id string) (string, bool) {
+	name := r.user(id).Name
+	return name, name != ""
+}
+
+func (r *resolver) ChannelName(id string) (string, bool) {
+	name := r.channelName(id)
+	return name, name != ""
+}
+
+func (r *resolver) Emoji(name string) (string, string, bool) {
+	return "", "", false
+}
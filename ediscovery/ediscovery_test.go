@@ -0,0 +1,268 @@
+package ediscovery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/render"
+	"github.com/ashb/slackarchive/storage"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		s    string
+		end  bool
+		want string
+	}{
+		{"", false, ""},
+		{"2020-05-01", false, "2020-05-01T00:00:00Z"},
+		{"2020-05-01", true, "2020-05-01T23:59:59.999999Z"},
+		{"2020-12-31", true, "2020-12-31T23:59:59.999999Z"},
+		{"2020-05-01T12:30:00+02:00", false, "2020-05-01T12:30:00+02:00"},
+		// Times are taken as they are, end or not
+		{"2020-05-01T12:30:00Z", true, "2020-05-01T12:30:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.s, tt.end)
+		if err != nil {
+			t.Errorf("ParseDate(%q, %v) error: %s", tt.s, tt.end, err)
+			continue
+		}
+		var s string
+		if got != nil {
+			s = got.Format(time.RFC3339Nano)
+		}
+		if s != tt.want {
+			t.Errorf("ParseDate(%q, %v) = %s, want %s", tt.s, tt.end, s, tt.want)
+		}
+	}
+
+	for _, s := range []string{"yesterday", "2020-13-01", "01/05/2020"} {
+		if _, err := ParseDate(s, false); err == nil {
+			t.Errorf("ParseDate(%q) didn't fail", s)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name, id, want string
+	}{
+		{"general", "C1", "general-C1"},
+		{"", "C1", "C1"},
+		{".", "C1", "C1"},
+		{"..", "C1", "C1"},
+		{"../etc", "C1", ".._etc-C1"},
+		{`a\b`, "C1", "a_b-C1"},
+		{"a\nb\x00", "C1", "a_b_-C1"},
+		{"ünïcödé", "C1", "ünïcödé-C1"},
+	}
+	for _, tt := range tests {
+		if got := fileName(tt.name, tt.id); got != tt.want {
+			t.Errorf("fileName(%q, %q) = %q, want %q", tt.name, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestEML(t *testing.T) {
+	parent := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	reply := parent.Add(time.Minute)
+	store := newFakeStore()
+	e := &exporter{store: store, resolver: newResolver(store)}
+	e.renderer = render.New(e.resolver)
+
+	tests := []struct {
+		name      string
+		msg       models.Message
+		from      string
+		subject   string
+		messageID string
+		inReplyTo string
+		deletedAt string
+	}{
+		{
+			name:      "thread parent",
+			msg:       models.Message{ChannelID: "C1", UserID: "U1", Timestamp: &parent, ThreadTimestamp: &parent, Msg: &slack.Msg{Text: "hello\nsecond line"}},
+			from:      `"Jane Doe" <jane@example.com>`,
+			subject:   "#general: hello",
+			messageID: "<1588334400.000000.C1@slack.invalid>",
+		},
+		{
+			name:      "reply",
+			msg:       models.Message{ChannelID: "C1", UserID: "U2", Timestamp: &reply, ThreadTimestamp: &parent, Msg: &slack.Msg{Text: "héllo back"}},
+			from:      `"bob" <U2@slack.invalid>`,
+			subject:   "#general: héllo back",
+			messageID: "<1588334460.000000.C1@slack.invalid>",
+			inReplyTo: "<1588334400.000000.C1@slack.invalid>",
+		},
+		{
+			name:      "deleted",
+			msg:       models.Message{ChannelID: "C1", UserID: "U1", Timestamp: &reply, DeletedAt: &reply, Msg: &slack.Msg{Text: "gone"}},
+			from:      `"Jane Doe" <jane@example.com>`,
+			subject:   "#general: gone",
+			messageID: "<1588334460.000000.C1@slack.invalid>",
+			deletedAt: reply.Format(time.RFC1123Z),
+		},
+	}
+	for _, tt := range tests {
+		b, err := e.eml(&tt.msg, "MSG0000001")
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		m, err := mail.ReadMessage(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: not an email: %s", tt.name, err)
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+		if err != nil {
+			t.Fatalf("%s: subject: %s", tt.name, err)
+		}
+
+		for _, h := range []struct{ key, got, want string }{
+			{"From", m.Header.Get("From"), tt.from},
+			{"To", m.Header.Get("To"), `"#general" <C1@slack.invalid>`},
+			{"Subject", subject, tt.subject},
+			{"Date", m.Header.Get("Date"), tt.msg.Timestamp.Format(time.RFC1123Z)},
+			{"Message-ID", m.Header.Get("Message-ID"), tt.messageID},
+			{"In-Reply-To", m.Header.Get("In-Reply-To"), tt.inReplyTo},
+			{"References", m.Header.Get("References"), tt.inReplyTo},
+			{"X-Document-ID", m.Header.Get("X-Document-ID"), "MSG0000001"},
+			{"X-Slack-Deleted-At", m.Header.Get("X-Slack-Deleted-At"), tt.deletedAt},
+		} {
+			if h.got != h.want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, h.key, h.got, h.want)
+			}
+		}
+
+		if mediaType, _, err := mime.ParseMediaType(m.Header.Get("Content-Type")); err != nil || mediaType != "multipart/alternative" {
+			t.Errorf("%s: Content-Type = %q", tt.name, m.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func TestExportManifest(t *testing.T) {
+	first := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	store := newFakeStore()
+	store.messages = []models.Message{
+		{ChannelID: "C1", UserID: "U1", Timestamp: &first, Msg: &slack.Msg{Text: "one"}},
+		{ChannelID: "C2", UserID: "U2", Timestamp: &second, Msg: &slack.Msg{Text: "two"}},
+	}
+
+	for _, format := range []string{FormatEML, FormatHTML} {
+		archive := memArchive{}
+		req := Request{Format: format, DiscoveryQuery: storage.DiscoveryQuery{Keywords: "o"}}
+		manifest, err := Export(store, req, "tester", archive)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		if manifest.Messages != 2 || len(manifest.Items) != 2 {
+			t.Fatalf("%s: exported %d messages in %d items, want 2", format, manifest.Messages, len(manifest.Items))
+		}
+		if want := "messages/general-C1/MSG0000001." + format; manifest.Items[0].Path != want {
+			t.Errorf("%s: first item is %s, want %s", format, manifest.Items[0].Path, want)
+		}
+		if want := "messages/C2/MSG0000002." + format; manifest.Items[1].Path != want {
+			t.Errorf("%s: second item is %s, want %s", format, manifest.Items[1].Path, want)
+		}
+
+		for _, item := range append(manifest.Items, manifest.LoadFile) {
+			data, ok := archive[item.Path]
+			if !ok {
+				t.Errorf("%s: %s wasn't written", format, item.Path)
+				continue
+			}
+			sum := sha256.Sum256(data)
+			if got := hex.EncodeToString(sum[:]); got != item.SHA256 || len(data) != item.Size {
+				t.Errorf("%s: %s is %d bytes with sha256 %s, manifest says %d bytes with %s", format, item.Path, len(data), got, item.Size, item.SHA256)
+			}
+		}
+
+		var written Manifest
+		if err := json.Unmarshal(archive[ManifestName], &written); err != nil {
+			t.Fatalf("%s: manifest: %s", format, err)
+		}
+		if written.LoadFile != manifest.LoadFile || len(written.Items) != len(manifest.Items) || written.GeneratedBy != "tester" {
+			t.Errorf("%s: written manifest %+v doesn't match %+v", format, written, manifest)
+		}
+	}
+}
+
+// memArchive keeps an export in memory.
+type memArchive map[string][]byte
+
+func (a memArchive) Add(name string, data []byte) error {
+	a[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// fakeStore has the users, channels and messages an export reads and
+// nothing else.
+type fakeStore struct {
+	storage.Store
+	users    map[string]*models.User
+	channels map[string]*models.Channel
+	messages []models.Message
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		users: map[string]*models.User{
+			"U1": {ID: "U1", Name: "jane", Profile: models.UserProfile{RealName: "Jane Doe", Email: "jane@example.com"}},
+			"U2": {ID: "U2", Name: "bob"},
+		},
+		channels: map[string]*models.Channel{
+			"C1": {ID: "C1", Name: "general"},
+		},
+	}
+}
+
+func (s *fakeStore) Users() storage.UserRepository       { return fakeUsers{s: s} }
+func (s *fakeStore) Channels() storage.ChannelRepository { return fakeChannels{s: s} }
+func (s *fakeStore) Messages() storage.MessageRepository { return fakeMessages{s: s} }
+
+type fakeUsers struct {
+	storage.UserRepository
+	s *fakeStore
+}
+
+func (r fakeUsers) Get(id string) (*models.User, error) {
+	if u, ok := r.s.users[id]; ok {
+		return u, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+type fakeChannels struct {
+	storage.ChannelRepository
+	s *fakeStore
+}
+
+func (r fakeChannels) Get(id string) (*models.Channel, error) {
+	if c, ok := r.s.channels[id]; ok {
+		return c, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+type fakeMessages struct {
+	storage.MessageRepository
+	s *fakeStore
+}
+
+func (r fakeMessages) Discover(query storage.DiscoveryQuery, after *storage.Cursor, limit int) ([]models.Message, error) {
+	if after != nil {
+		return nil, nil
+	}
+	return r.s.messages, nil
+}
@@ -0,0 +1,114 @@
+package ediscovery
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+)
+
+// domain is what the addresses of users and channels without an email end
+// in. .invalid is reserved, they can't be mistaken for real ones.
+const domain = "slack.invalid"
+
+// eml writes a message as an email from its author to its channel, with the
+// text and the HTML of the message as alternatives.
+func (e *exporter) eml(m *models.Message, docID string) ([]byte, error) {
+	var b bytes.Buffer
+	ts := models.TimeToTimestamp(*m.Timestamp)
+	channel := e.resolver.channel(m.ChannelID)
+
+	from := e.from(m.UserID)
+	to := mail.Address{Name: "#" + channel.Name, Address: m.ChannelID + "@" + domain}
+	header := [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Date", m.Timestamp.Format(time.RFC1123Z)},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject(channel.Name, e.renderer.Text(m.Msg)))},
+		{"Message-ID", messageID(m.ChannelID, ts)},
+	}
+	if m.ThreadTimestamp != nil && !m.ThreadTimestamp.Equal(*m.Timestamp) {
+		parent := messageID(m.ChannelID, models.TimeToTimestamp(*m.ThreadTimestamp))
+		header = append(header, [2]string{"In-Reply-To", parent}, [2]string{"References", parent})
+	}
+	header = append(header,
+		[2]string{"X-Document-ID", docID},
+		[2]string{"X-Slack-Channel-ID", m.ChannelID},
+		[2]string{"X-Slack-User-ID", m.UserID},
+		[2]string{"X-Slack-Timestamp", ts},
+	)
+	if m.DeletedAt != nil {
+		header = append(header, [2]string{"X-Slack-Deleted-At", m.DeletedAt.UTC().Format(time.RFC1123Z)})
+	}
+
+	mw := multipart.NewWriter(&b)
+	header = append(header,
+		[2]string{"MIME-Version", "1.0"},
+		[2]string{"Content-Type", `multipart/alternative; boundary="` + mw.Boundary() + `"`},
+	)
+	for _, h := range header {
+		fmt.Fprintf(&b, "%s: %s\r\n", h[0], h[1])
+	}
+	b.WriteString("\r\n")
+
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", e.renderer.Text(m.Msg)},
+		{"text/html; charset=utf-8", e.page(m, docID)},
+	}
+	for _, p := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(p.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// from is the address of a user, their email if Slack told us it.
+func (e *exporter) from(userID string) mail.Address {
+	u := e.resolver.user(userID)
+	name := u.Profile.RealName
+	if name == "" {
+		name = u.Name
+	}
+	address := u.Profile.Email
+	if address == "" {
+		address = userID + "@" + domain
+	}
+	return mail.Address{Name: name, Address: address}
+}
+
+func messageID(channelID, ts string) string {
+	return "<" + ts + "." + channelID + "@" + domain + ">"
+}
+
+// subject is the channel and the start of the first line of the text.
+func subject(channel, text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	if r := []rune(text); len(r) > 60 {
+		text = string(r[:60]) + "…"
+	}
+	return strings.TrimSpace("#" + channel + ": " + text)
+}
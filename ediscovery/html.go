@@ -0,0 +1,78 @@
+package ediscovery
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+)
+
+// pageTemplate is a standalone page for a message, with the details review
+// needs above it, that prints one message per page.
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.DocID}}</title>
+<style>
+body { font-family: sans-serif; font-size: 11pt; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th { text-align: left; padding-right: 1em; vertical-align: top; }
+td, th { padding-bottom: 0.2em; }
+.message { border-top: 1px solid #999; padding-top: 1em; }
+.deleted { color: #b00; }
+@page { size: A4; margin: 2cm; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<table>
+<tr><th>Document</th><td>{{.DocID}}</td></tr>
+<tr><th>Channel</th><td>#{{.Channel}} ({{.ChannelID}})</td></tr>
+<tr><th>From</th><td>{{.From}} ({{.UserID}})</td></tr>
+<tr><th>Sent</th><td>{{.Sent}}</td></tr>
+<tr><th>Timestamp</th><td>{{.Timestamp}}</td></tr>
+{{- if .Thread}}
+<tr><th>In thread</th><td>{{.Thread}}</td></tr>
+{{- end}}
+{{- if .Deleted}}
+<tr class="deleted"><th>Deleted</th><td>{{.Deleted}}</td></tr>
+{{- end}}
+</table>
+<div class="message">{{.Body}}</div>
+</body>
+</html>
+`))
+
+// page renders a message as a page of its own, ready to print to PDF.
+func (e *exporter) page(m *models.Message, docID string) string {
+	data := struct {
+		DocID, Channel, ChannelID, From, UserID string
+		Sent, Timestamp, Thread, Deleted        string
+		Body                                    template.HTML
+	}{
+		DocID:     docID,
+		Channel:   e.resolver.channelName(m.ChannelID),
+		ChannelID: m.ChannelID,
+		From:      e.from(m.UserID).Name,
+		UserID:    m.UserID,
+		Sent:      m.Timestamp.UTC().Format(time.RFC1123),
+		Timestamp: models.TimeToTimestamp(*m.Timestamp),
+		// render escapes everything Slack sent
+		Body: template.HTML(e.renderer.HTML(m.Msg)),
+	}
+	if m.ThreadTimestamp != nil && !m.ThreadTimestamp.Equal(*m.Timestamp) {
+		data.Thread = models.TimeToTimestamp(*m.ThreadTimestamp)
+	}
+	if m.DeletedAt != nil {
+		data.Deleted = m.DeletedAt.UTC().Format(time.RFC1123)
+	}
+
+	var b bytes.Buffer
+	if err := pageTemplate.Execute(&b, data); err != nil {
+		// Only a bug in the template fails it
+		panic(err)
+	}
+	return b.String()
+}
@@ -16,6 +16,12 @@ const routes = [
     name: 'home',
     component: Home
   },
+  {
+    // Same path as Slack's permalinks, so swapping the host opens a message here
+    path: '/archives/:channel_id/p:id',
+    name: 'permalink',
+    component: Messages
+  },
   {
     path: '/:channel_name',
     name: 'channel',
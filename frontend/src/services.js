@@ -12,19 +12,26 @@ export default {
   getChannels(teamId){
     return axios.get(apiUrl + 'channels', {params: {team_id: teamId}})
   },
-  getMessages (team_id, channel_id, size, offset, search, sort = 'desc', tsTo, tsFrom) {
-    let params = {size: size, team: team_id};
+  // cursor is {after: next} or {before: prev} from an earlier page, which
+  // doesn't need counting again
+  getMessages (team_id, channel_id, size, offset, search, sort = 'desc', tsTo, tsFrom, cursor) {
+    let params = {size: size, team: team_id, format: 'html'};
     if (channel_id)
       params.channel = channel_id
-    if (offset !== undefined)
+    if (cursor) {
+      Object.assign(params, cursor)
+      params.count = 0
+    } else if (offset !== undefined)
       params.offset = offset
     if (search) {
       params.q = search.query
+      if (search.mode === 'exact' || search.mode === 'fuzzy')
+        params.mode = search.mode
       if (size > 0) {
         params.aggs = 1
       }
     }
-    if (sort === 'asc')
+    if (sort === 'asc' || sort === 'relevance')
       params.sort = sort
     if (tsTo)
       params.to = tsTo/1000000
@@ -6,37 +6,44 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/ashb/slackarchive/config"
+	"github.com/ashb/slackarchive/logs"
+	"github.com/ashb/slackarchive/metrics"
 	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/redact"
+	"github.com/ashb/slackarchive/storage"
 	"github.com/ashb/slackarchive/utils"
-	"github.com/go-pg/pg"
-	"github.com/go-pg/pg/orm"
-	"github.com/op/go-logging"
 	"github.com/slack-go/slack"
 )
 
-var log = logging.MustGetLogger("importer")
+var log = logs.New("importer")
 
 func New(conf *config.Config, debug bool) *Importer {
-	opts, err := pg.ParseURL(conf.Database.DSN)
+	db, err := storage.Open(conf.Database.DSN, debug)
 	if err != nil {
 		panic(err)
 	}
-	db := pg.Connect(opts)
-	if debug {
-		db.AddQueryHook(models.DBLogger{Logger: log})
+
+	var redactor *redact.Redactor
+	if conf.Redaction.Store() {
+		if redactor, err = redact.New(conf.Redaction); err != nil {
+			panic(err)
+		}
 	}
 
 	return &Importer{
-		db:   db,
-		conf: conf,
+		db:       db,
+		conf:     conf,
+		redactor: redactor,
 	}
 }
 
 type Importer struct {
-	db   orm.DB
-	conf *config.Config
+	db       storage.Store
+	conf     *config.Config
+	redactor *redact.Redactor
 }
 
 type TeamImporter struct {
@@ -57,31 +64,23 @@ func (i *Importer) importTeam(client *slack.Client, token string) (*models.Team,
 		return nil, err
 	}
 
-	if err := i.db.Model(&t).WherePK().Select(); err == nil {
-		log.Debug("Team already exists: ", t.ID)
-		_, err = i.db.Model(&t).WherePK().Update(&t)
-	} else if err != pg.ErrNoRows {
-		return nil, err
-	} else {
-		_, err = i.db.Model(&t).Insert()
-	}
-
-	return &t, err
+	return &t, i.db.Teams().Upsert(&t)
 }
 
 func (i *TeamImporter) importUser(user slack.User) error {
 	u := models.User{ID: user.ID, TeamID: i.team.ID}
-	if err := i.db.Model(&u).WherePK().Select(); err == nil {
+	if exists, err := i.db.Users().Exists(u.ID); err != nil {
+		return err
+	} else if exists {
 		log.Debugf("User already exists: %s", u.ID)
 		return nil
-	} else if err != pg.ErrNoRows {
-		return err
 	}
 	if err := utils.Merge(&u, user); err != nil {
 		return fmt.Errorf("Error merging user(%s): %s", user.ID, err.Error())
 	}
+	u.MergeEnterprise(&user)
 
-	if _, err := i.db.Model(&u).Insert(); err != nil {
+	if err := i.db.Users().Create(&u); err != nil {
 		return fmt.Errorf("Error upserting user(%s): %s", user.ID, err.Error())
 	}
 	return nil
@@ -89,11 +88,11 @@ func (i *TeamImporter) importUser(user slack.User) error {
 
 func (i *TeamImporter) importBotUser(bot_id string) error {
 	u := &models.User{ID: bot_id, TeamID: i.team.ID}
-	if err := i.db.Model(u).WherePK().Select(); err == nil {
+	if exists, err := i.db.Users().Exists(u.ID); err != nil {
+		return err
+	} else if exists {
 		log.Debugf("Bot already exists: %s", u.ID)
 		return nil
-	} else if err != pg.ErrNoRows {
-		return err
 	}
 
 	bot, err := i.client.GetBotInfo(bot_id)
@@ -103,12 +102,39 @@ func (i *TeamImporter) importBotUser(bot_id string) error {
 
 	u.MergeBot(bot)
 
-	if _, err := i.db.Model(u).Insert(); err != nil {
+	if err := i.db.Users().Create(u); err != nil {
 		return fmt.Errorf("Error upserting bot user(%s): %s", u.ID, err.Error())
 	}
 	return nil
 }
 
+// importExternalUser looks up users from other organisations that post in
+// Slack Connect channels. They aren't part of users.json.
+func (i *TeamImporter) importExternalUser(userID string, homeTeamID string) error {
+	u := &models.User{ID: userID, TeamID: i.team.ID}
+	if exists, err := i.db.Users().Exists(userID); err != nil || exists {
+		return err
+	}
+
+	user, err := i.client.GetUserInfo(userID)
+	if err != nil {
+		log.Warningf("Error querying external user(%s), storing a placeholder: %s", userID, err)
+		user = &slack.User{ID: userID, Name: userID, TeamID: homeTeamID, IsStranger: true}
+	}
+
+	if err := utils.Merge(u, *user); err != nil {
+		return fmt.Errorf("Error merging user(%s): %s", userID, err.Error())
+	}
+	u.TeamID = i.team.ID
+	u.MergeEnterprise(user)
+	u.MergeExternal(user, i.team)
+
+	if err := i.db.Users().Create(u); err != nil {
+		return fmt.Errorf("Error inserting external user(%s): %s", userID, err.Error())
+	}
+	return nil
+}
+
 func (i *TeamImporter) importUsers(path string) error {
 	f, err := os.Open(path)
 	if err != nil {
@@ -121,7 +147,7 @@ func (i *TeamImporter) importUsers(path string) error {
 		TeamID: i.team.ID,
 		ID:     "USLACKBOT",
 	}
-	if _, err := i.db.Model(&slackbot).OnConflict("DO NOTHING").Insert(); err != nil {
+	if err := i.db.Users().Create(&slackbot); err != nil {
 		return err
 	}
 
@@ -157,13 +183,17 @@ func (i *TeamImporter) importChannels(path string) (map[string]models.Channel, e
 	for _, channel := range channels {
 		c := models.Channel{ID: channel.ID, TeamID: i.team.ID}
 
-		if err := i.db.Model(&c).WhereStruct(c).Select(); err == nil {
-			channelsMap[c.Name] = c
+		if existing, err := i.db.Channels().Get(c.ID); err == nil {
+			channelsMap[existing.Name] = *existing
+
+			if err := i.db.Channels().LinkTeam(c.ID, i.team.ID); err != nil {
+				return nil, err
+			}
 
 			log.Debugf("Channel already exists: %s", c.ID)
 			// found
 			continue
-		} else if err != pg.ErrNoRows {
+		} else if err != storage.ErrNotFound {
 			return nil, err
 		}
 
@@ -172,7 +202,7 @@ func (i *TeamImporter) importChannels(path string) (map[string]models.Channel, e
 			continue
 		}
 
-		if _, err = i.db.Model(&c).Insert(); err != nil {
+		if err := i.db.Channels().Upsert(&c); err != nil {
 			log.Errorf("Error inserting channel(%s): %s", channel.ID, err.Error())
 			continue
 		}
@@ -205,6 +235,11 @@ func (ti *TeamImporter) importMessages(channelID string, path string) error {
 				continue
 			}
 			message.User = message.BotID
+		} else if message.User != "" && message.Team != "" && message.Team != ti.team.ID {
+			if err := ti.importExternalUser(message.User, message.Team); err != nil {
+				log.Errorf("Error importing external user: %s", err.Error())
+				continue
+			}
 		}
 
 		m := &models.Message{ChannelID: channelID}
@@ -212,17 +247,17 @@ func (ti *TeamImporter) importMessages(channelID string, path string) error {
 			log.Errorf("Error merging message: %s", err.Error())
 			continue
 		}
-		if err := ti.db.Model(m).WherePK().Select(); err == nil {
-			//	log.Debug("Message already exists: %s", m.ID)
-		} else if err != pg.ErrNoRows {
+		if m.Timestamp != nil && ti.conf.Retention.Expired(ti.team.ID, channelID, *m.Timestamp, time.Now()) {
+			continue
+		}
+		if _, err := ti.redactor.Message(m.Msg); err != nil {
+			log.Errorf("Error redacting message: %s", err.Error())
+			continue
+		}
+		if err := ti.db.Messages().Create(m); err != nil {
 			return err
-		} else {
-			_, err = ti.db.Model(m).Insert()
-			if err != nil {
-
-				panic(err)
-			}
 		}
+		metrics.MessageIngested(ti.team.ID, channelID)
 	}
 
 	return nil
@@ -0,0 +1,75 @@
+// Package logs sets up logging. Every subsystem has a logger of its own,
+// with its own level, and they all write text or JSON lines to stderr.
+package logs
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ashb/slackarchive/config"
+)
+
+var (
+	mu      sync.Mutex
+	loggers = map[string]*logrus.Logger{}
+	conf    config.LoggingConfig
+	debug   bool
+)
+
+// New returns the logger of a subsystem, its lines tagged with its name.
+func New(subsystem string) *logrus.Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	l, ok := loggers[subsystem]
+	if !ok {
+		l = logrus.New()
+		loggers[subsystem] = l
+		configure(subsystem, l)
+	}
+	return l.WithField("subsystem", subsystem)
+}
+
+// Configure applies the logging config to every subsystem. debug logs
+// everything at debug level, whatever the config says.
+func Configure(c config.LoggingConfig, debugAll bool) error {
+	for subsystem, level := range c.Levels {
+		if _, err := logrus.ParseLevel(level); err != nil {
+			return fmt.Errorf("logging level of %s: %s", subsystem, err)
+		}
+	}
+	if c.Level != "" {
+		if _, err := logrus.ParseLevel(c.Level); err != nil {
+			return fmt.Errorf("logging level: %s", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	conf, debug = c, debugAll
+	for subsystem, l := range loggers {
+		configure(subsystem, l)
+	}
+	return nil
+}
+
+func configure(subsystem string, l *logrus.Logger) {
+	if conf.Format == config.LogJSON {
+		l.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		l.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	}
+
+	level := logrus.InfoLevel
+	if s, ok := conf.Levels[subsystem]; ok {
+		level, _ = logrus.ParseLevel(s)
+	} else if conf.Level != "" {
+		level, _ = logrus.ParseLevel(conf.Level)
+	}
+	if debug {
+		level = logrus.DebugLevel
+	}
+	l.SetLevel(level)
+}
@@ -1,28 +1,37 @@
 package main
 
 import (
+	"archive/zip"
+	"context"
 	"fmt"
-	"github.com/go-pg/pg/orm"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	_ "os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
 	"time"
 
 	cli "gopkg.in/urfave/cli.v1"
 
 	"github.com/ashb/slackarchive/api"
 	"github.com/ashb/slackarchive/bot"
+	"github.com/ashb/slackarchive/blobs"
 	"github.com/ashb/slackarchive/config"
+	"github.com/ashb/slackarchive/ediscovery"
 	"github.com/ashb/slackarchive/importer"
-	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/logs"
+	dbmigrations "github.com/ashb/slackarchive/migrations"
+	"github.com/ashb/slackarchive/redact"
+	"github.com/ashb/slackarchive/storage"
+	"github.com/ashb/slackarchive/storage/postgres"
+	"github.com/ashb/slackarchive/tracing"
+	_ "github.com/ashb/slackarchive/storage/sqlite"
 	"github.com/go-pg/migrations"
-	"github.com/go-pg/pg"
-	"github.com/op/go-logging"
-
-	_ "github.com/ashb/slackarchive/migrations"
 )
 
-var log = logging.MustGetLogger("main")
+var log = logs.New("main")
 
 func init() {
 	rand.Seed(time.Now().UTC().UnixNano())
@@ -51,6 +60,7 @@ func main() {
 				cli.BoolFlag{
 					Name: "debug, D",
 				},
+				noMigrateFlag,
 			},
 		},
 		{
@@ -106,12 +116,92 @@ func main() {
 			},
 		},
 		{
-			Name: "init",
-			Action: initArchive,
+			Name:        "reindex",
+			Action:      reindex,
+			Description: "Rebuild the search index of messages whose search language has changed",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name: "debug, D",
+				},
+				cli.BoolFlag{
+					Name:  "all",
+					Usage: "Reindex every message, not just the ones with a stale language",
+				},
+				noMigrateFlag,
+			},
+		},
+		{
+			Name:        "purge",
+			Action:      purge,
+			Description: "Delete the messages past their retention policy now, rather than waiting for the bot",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name: "debug, D",
+				},
+				noMigrateFlag,
+			},
+		},
+		{
+			Name:        "redact",
+			Action:      redactArchive,
+			Description: "Redact the messages archived before redaction was turned on or its detectors changed",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run, n",
+					Usage: "Only count the messages that would be redacted",
+				},
+				cli.BoolFlag{
+					Name: "debug, D",
+				},
+				noMigrateFlag,
+			},
+		},
+		{
+			Name:        "ediscovery",
+			Action:      eDiscovery,
+			Description: "Export the messages of some users, channels, keywords and dates for legal review",
+			ArgsUsage:   "OUTPUT_DIR|OUTPUT.zip",
 			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "user, u",
+					Usage: "Messages posted by this user ID (repeat for more)",
+				},
+				cli.StringSliceFlag{
+					Name:  "channel, c",
+					Usage: "Messages posted in this channel ID (repeat for more)",
+				},
+				cli.StringFlag{
+					Name:  "keywords, k",
+					Usage: "Messages matching this search query",
+				},
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "Messages posted from this date (2006-01-02) or time (RFC 3339)",
+				},
+				cli.StringFlag{
+					Name:  "to",
+					Usage: "Messages posted up to this date (inclusive) or time",
+				},
+				cli.StringFlag{
+					Name:  "format, f",
+					Value: "eml",
+					Usage: "eml, or html to print to PDF",
+				},
 				cli.BoolFlag{
 					Name: "debug, D",
 				},
+				noMigrateFlag,
+			},
+		},
+		{
+			Name:        "init",
+			Action:      initArchive,
+			Description: "Create the database schema and retrieve all history",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name: "debug, D",
+				},
+				noMigrateFlag,
 			},
 		},
 	}
@@ -119,19 +209,94 @@ func main() {
 	app.Run(os.Args)
 }
 
-func run(c *cli.Context) error {
+var noMigrateFlag = cli.BoolFlag{
+	Name:   "no-migrate",
+	Usage:  "Don't apply pending database migrations on startup",
+	EnvVar: "SLACKARCHIVE_NO_MIGRATE",
+}
+
+// prepareDb applies pending migrations, unless asked not to, and makes sure
+// we aren't about to run against a schema from a newer release. It then
+// stores the search languages from the config.
+func prepareDb(c *cli.Context, conf *config.Config, db storage.Store) error {
+	if c.Bool("no-migrate") {
+		version, latest, err := db.SchemaVersion()
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+		if version > latest {
+			return cli.NewExitError(fmt.Errorf("database schema version %d is newer than this release supports (%d), please upgrade slackarchive", version, latest), 1)
+		} else if version < latest {
+			log.Warningf("Database schema is at version %d, %d is available. Run `migrate up` to upgrade", version, latest)
+			return nil
+		}
+		return configureSearch(conf, db)
+	}
+
+	oldVersion, newVersion, err := db.Migrate()
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	if newVersion != oldVersion {
+		log.Infof("Migrated database from version %d to %d", oldVersion, newVersion)
+	}
+	return configureSearch(conf, db)
+}
+
+// loadConfig loads the config and sets up logging with it. --debug logs
+// everything, database queries included.
+func loadConfig(c *cli.Context) (*config.Config, error) {
 	conf, err := config.Load(c.GlobalString("config"))
 	if err != nil {
+		return nil, err
+	}
+	debug := c.Bool("debug") || (c.Parent() != nil && c.Parent().Bool("debug"))
+	if err := logs.Configure(conf.Logging, debug); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func configureSearch(conf *config.Config, db storage.Store) error {
+	if err := db.ConfigureSearch(conf.Search); err != nil {
 		return cli.NewExitError(err, 1)
 	}
+	return nil
+}
 
-	db, err := models.Connect(conf.Database.DSN, c.Bool("debug"))
+func run(c *cli.Context) error {
+	conf, err := loadConfig(c)
 	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	db, err := storage.Open(conf.Database.DSN, c.Bool("debug"))
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	if err := prepareDb(c, conf, db); err != nil {
 		return err
 	}
 
-	api := api.New(conf, db)
-	bot := bot.New(conf, db)
+	blobStore, err := blobs.NewFileStore(conf.Blobs.Path)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	redactor, err := redact.New(conf.Redaction)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	stopTracing, err := tracing.Setup(context.Background(), conf.Tracing)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	defer stopTracing(context.Background())
+
+	bot := bot.New(conf, db, blobStore, redactor)
+	api := api.New(conf, db, blobStore, redactor, bot)
 	bot.Start()
 	api.Serve()
 	return nil
@@ -142,7 +307,7 @@ func doImport(c *cli.Context) error {
 		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
 
 	}
-	conf, err := config.Load(c.GlobalString("config"))
+	conf, err := loadConfig(c)
 	if err != nil {
 		return cli.NewExitError(err, 1)
 	}
@@ -153,19 +318,22 @@ func doImport(c *cli.Context) error {
 }
 
 func migrate(c *cli.Context) error {
-	config, err := config.Load(c.GlobalString("config"))
+	config, err := loadConfig(c)
 	if err != nil {
 		return cli.NewExitError(err, 1)
 	}
 
-	opts, err := pg.ParseURL(config.Database.DSN)
+	store, err := storage.Open(config.Database.DSN, c.Bool("debug") || c.Parent().Bool("debug"))
 	if err != nil {
 		return cli.NewExitError(err, 1)
 	}
-	db := pg.Connect(opts)
-	if c.Bool("debug") || c.Parent().Bool("debug") {
-		db.AddQueryHook(models.DBLogger{Logger: log})
+	defer store.Close()
+
+	pgStore, ok := store.(*postgres.Store)
+	if !ok {
+		return migrateStore(c, store)
 	}
+	db := pgStore.DB()
 
 	if c.Command.Name == "create" {
 		if err := os.Chdir("migrations"); err != nil {
@@ -177,10 +345,12 @@ func migrate(c *cli.Context) error {
 	args = append(args, c.Args()...)
 
 	if oldVersion, newVersion, err := migrations.Run(db, args...); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return cli.NewExitError(err, 1)
 	} else if newVersion != oldVersion {
 		fmt.Printf("migrated from version %d to %d\n", oldVersion, newVersion)
+		if dbmigrations.NeedsReindex(oldVersion, newVersion) {
+			fmt.Println("run `slackarchive reindex --all` to index blocks, attachments and files of messages archived so far")
+		}
 	} else {
 		fmt.Printf("version is %d\n", oldVersion)
 	}
@@ -188,60 +358,261 @@ func migrate(c *cli.Context) error {
 	return nil
 }
 
-func initArchive(c *cli.Context) error {
-	if err := initDb(c); err != nil {
-		return err
+// migrateStore handles the migrate commands for backends that manage their
+// own schema, which only know how to go up.
+func migrateStore(c *cli.Context, store storage.Store) error {
+	var oldVersion, newVersion int64
+	var err error
+
+	switch {
+	case c.Command.Name == "up" && c.NArg() == 0:
+		oldVersion, newVersion, err = store.Migrate()
+	case c.Command.Name == "version":
+		oldVersion, _, err = store.SchemaVersion()
+		newVersion = oldVersion
+	default:
+		err = fmt.Errorf("migrate %s is only supported for Postgres", c.Command.Name)
 	}
 
-	return firstRetrieve(c)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	} else if newVersion != oldVersion {
+		fmt.Printf("migrated from version %d to %d\n", oldVersion, newVersion)
+	} else {
+		fmt.Printf("version is %d\n", oldVersion)
+	}
+
+	return nil
 }
 
-func initDb(c *cli.Context) error {
-	conf, err := config.Load(c.GlobalString("config"))
+func reindex(c *cli.Context) error {
+	conf, err := loadConfig(c)
 	if err != nil {
 		return cli.NewExitError(err, 1)
 	}
 
-	db, err := models.Connect(conf.Database.DSN, c.Bool("debug"))
+	db, err := storage.Open(conf.Database.DSN, c.Bool("debug"))
 	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	defer db.Close()
+
+	if err := prepareDb(c, conf, db); err != nil {
 		return err
 	}
 
-	err = db.Model(&models.Team{}).CreateTable(&orm.CreateTableOptions{IfNotExists: true})
+	n, err := db.Messages().Reindex(c.Bool("all"))
 	if err != nil {
-		return err
+		return cli.NewExitError(err, 1)
 	}
+	fmt.Printf("reindexed %d messages\n", n)
+	return nil
+}
 
-	err = db.Model(&models.User{}).CreateTable(&orm.CreateTableOptions{IfNotExists: true})
+func purge(c *cli.Context) error {
+	conf, err := loadConfig(c)
 	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	if !conf.Retention.Enabled() {
+		return cli.NewExitError("no retention policy deletes messages, see retention in the config", 1)
+	}
+
+	db, err := storage.Open(conf.Database.DSN, c.Bool("debug"))
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	defer db.Close()
+
+	if err := prepareDb(c, conf, db); err != nil {
 		return err
 	}
 
-	err = db.Model(&models.Channel{}).CreateTable(&orm.CreateTableOptions{IfNotExists: true})
+	purged, err := bot.New(conf, db, nil, nil).Purge(context.Background())
+	for _, p := range purged {
+		fmt.Printf("%s: purged %d messages, %d pins and %d bookmarks from before %s (%s)\n",
+			p.ChannelID, p.Messages, p.Pins, p.Bookmarks, p.Before.Format(time.RFC3339), p.Policy)
+	}
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	return nil
+}
+
+func redactArchive(c *cli.Context) error {
+	conf, err := loadConfig(c)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	if !conf.Redaction.Store() {
+		return cli.NewExitError("messages are only redacted in the archive with redaction mode store, see redaction in the config", 1)
+	}
+
+	redactor, err := redact.New(conf.Redaction)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	db, err := storage.Open(conf.Database.DSN, c.Bool("debug"))
 	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	defer db.Close()
+
+	if err := prepareDb(c, conf, db); err != nil {
 		return err
 	}
 
-	err = db.Model(&models.Message{}).CreateTable(&orm.CreateTableOptions{IfNotExists: true})
+	dryRun := c.Bool("dry-run")
+	result, err := bot.New(conf, db, nil, redactor).Redact(context.Background(), dryRun)
+	verb := "redacted"
+	if dryRun {
+		verb = "would redact"
+	}
+	fmt.Printf("%s %d of %d messages, %d pins and %d bookmarks, %d held messages left as they are\n",
+		verb, result.Redacted, result.Messages, result.Pins, result.Bookmarks, result.Held)
 	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	return nil
+}
+
+func eDiscovery(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+	out := c.Args().Get(0)
+
+	req := ediscovery.Request{Format: c.String("format")}
+	req.UserIDs = c.StringSlice("user")
+	req.ChannelIDs = c.StringSlice("channel")
+	req.Keywords = c.String("keywords")
+	if len(req.UserIDs) == 0 && len(req.ChannelIDs) == 0 && req.Keywords == "" {
+		return cli.NewExitError("select messages with --user, --channel or --keywords", 1)
+	}
+
+	var err error
+	if req.From, err = ediscovery.ParseDate(c.String("from"), false); err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	if req.To, err = ediscovery.ParseDate(c.String("to"), true); err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	if err := req.Validate(); err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	// Never mix two exports, or overwrite one
+	if _, err := os.Stat(out); err == nil {
+		return cli.NewExitError(fmt.Sprintf("%s already exists", out), 1)
+	}
+
+	conf, err := loadConfig(c)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	db, err := storage.Open(conf.Database.DSN, c.Bool("debug"))
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	defer db.Close()
+
+	if err := prepareDb(c, conf, db); err != nil {
 		return err
 	}
 
+	by := "cli"
+	if u, err := user.Current(); err == nil {
+		by = u.Username
+	}
+
+	// Write next to out and move it in place when done, so a failed export
+	// doesn't leave half of one behind
+	var manifest *ediscovery.Manifest
+	if strings.HasSuffix(out, ".zip") {
+		f, err := ioutil.TempFile(filepath.Dir(out), "."+filepath.Base(out)+"-")
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+		defer os.Remove(f.Name())
+
+		zw := zip.NewWriter(f)
+		manifest, err = ediscovery.Export(db, req, by, ediscovery.Zip{Writer: zw})
+		if err == nil {
+			err = zw.Close()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(f.Name(), 0644)
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), out)
+		}
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+	} else {
+		dir, err := ioutil.TempDir(filepath.Dir(out), "."+filepath.Base(out)+"-")
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+		defer os.RemoveAll(dir)
+
+		manifest, err = ediscovery.Export(db, req, by, ediscovery.Dir(dir))
+		if err == nil {
+			err = os.Chmod(dir, 0755)
+		}
+		if err == nil {
+			err = os.Rename(dir, out)
+		}
+		if err != nil {
+			return cli.NewExitError(err, 1)
+		}
+	}
+
+	fmt.Printf("exported %d messages to %s, load file sha256 %s\n", manifest.Messages, out, manifest.LoadFile.SHA256)
 	return nil
 }
 
+func initArchive(c *cli.Context) error {
+	return firstRetrieve(c)
+}
+
 func firstRetrieve(c *cli.Context) error {
-	conf, err := config.Load(c.GlobalString("config"))
+	conf, err := loadConfig(c)
 	if err != nil {
 		return cli.NewExitError(err, 1)
 	}
 
-	db, err := models.Connect(conf.Database.DSN, c.Bool("debug"))
+	db, err := storage.Open(conf.Database.DSN, c.Bool("debug"))
 	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	if err := prepareDb(c, conf, db); err != nil {
 		return err
 	}
 
-	bot := bot.New(conf, db)
+	blobStore, err := blobs.NewFileStore(conf.Blobs.Path)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	redactor, err := redact.New(conf.Redaction)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+
+	stopTracing, err := tracing.Setup(context.Background(), conf.Tracing)
+	if err != nil {
+		return cli.NewExitError(err, 1)
+	}
+	defer stopTracing(context.Background())
+
+	bot := bot.New(conf, db, blobStore, redactor)
 	bot.RetrieveAll()
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,177 @@
+// Package metrics exposes what the archive is doing to Prometheus: messages
+// ingested, syncs, Slack API calls and rate limiting, database queries and
+// HTTP requests.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "slackarchive"
+
+var (
+	messagesIngested = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_ingested_total",
+		Help:      "Messages archived, edits included, by team and channel.",
+	}, []string{"team", "channel"})
+
+	syncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_duration_seconds",
+		Help:      "How long syncs with Slack took, by team and whether they succeeded.",
+		Buckets:   []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, []string{"team", "result"})
+
+	slackCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_api_calls_total",
+		Help:      "Slack Web API calls, by method and HTTP status.",
+	}, []string{"method", "code"})
+
+	slackRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_rate_limited_total",
+		Help:      "Slack Web API calls that were rate limited, by method.",
+	}, []string{"method"})
+
+	slackRateLimitWait = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_rate_limit_wait_seconds_total",
+		Help:      "Time Slack told us to wait before calling again, by method.",
+	}, []string{"method"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency, by statement.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 9),
+	}, []string{"operation"})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route, method and status.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	lag = &syncLag{
+		desc: prometheus.NewDesc(namespace+"_sync_lag_seconds",
+			"Time since the last successful sync, by team.", []string{"team"}, nil),
+		last: map[string]time.Time{},
+	}
+)
+
+func init() {
+	prometheus.MustRegister(messagesIngested, syncDuration, slackCalls, slackRateLimited, slackRateLimitWait,
+		dbQueryDuration, httpRequests, httpDuration, lag)
+}
+
+// Handler serves the metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// MessageIngested counts a message archived in a channel.
+func MessageIngested(teamID, channelID string) {
+	messagesIngested.WithLabelValues(teamID, channelID).Inc()
+}
+
+// SyncFinished records a sync of a team that started at started, and
+// restarts its lag if it succeeded.
+func SyncFinished(teamID string, started time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	} else {
+		lag.synced(teamID, time.Now())
+	}
+	syncDuration.WithLabelValues(teamID, result).Observe(time.Since(started).Seconds())
+}
+
+// SlackCall counts a call of a Slack API method and its response's status,
+// 0 when it failed without one, and how long Slack asked us to wait when it
+// rate limited us.
+func SlackCall(method string, code int, retryAfter time.Duration) {
+	slackCalls.WithLabelValues(method, statusCode(code)).Inc()
+	if code == http.StatusTooManyRequests {
+		slackRateLimited.WithLabelValues(method).Inc()
+		slackRateLimitWait.WithLabelValues(method).Add(retryAfter.Seconds())
+	}
+}
+
+// DBQuery records the latency of a query.
+func DBQuery(query string, d time.Duration) {
+	dbQueryDuration.WithLabelValues(operation(query)).Observe(d.Seconds())
+}
+
+// HTTPRequest records a request served. route is the path template of the
+// API endpoint, empty for everything else.
+func HTTPRequest(route, method string, code int, d time.Duration) {
+	if route == "" {
+		route = "other"
+	}
+	httpRequests.WithLabelValues(route, method, statusCode(code)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(d.Seconds())
+}
+
+// operation is the kind of statement of a query, its first keyword.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "other"
+	}
+	switch op := strings.ToLower(fields[0]); op {
+	case "select", "insert", "update", "delete", "with":
+		return op
+	}
+	return "other"
+}
+
+func statusCode(code int) string {
+	if code <= 0 {
+		// The request failed before there was a response
+		return "error"
+	}
+	return strconv.Itoa(code)
+}
+
+// syncLag reports how long ago each team last synced when scraped, so the
+// lag keeps growing while syncs fail or hang.
+type syncLag struct {
+	desc *prometheus.Desc
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func (l *syncLag) synced(teamID string, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.last[teamID] = at
+}
+
+func (l *syncLag) Describe(ch chan<- *prometheus.Desc) {
+	ch <- l.desc
+}
+
+func (l *syncLag) Collect(ch chan<- prometheus.Metric) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for teamID, at := range l.last {
+		ch <- prometheus.MustNewConstMetric(l.desc, prometheus.GaugeValue, time.Since(at).Seconds(), teamID)
+	}
+}
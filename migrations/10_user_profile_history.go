@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Who users were over time. Syncs overwrite the users row, this
+			-- keeps the names and pictures they had before, and when they
+			-- were deactivated and reactivated.
+			CREATE TABLE public.user_profile_history (
+					id bigserial NOT NULL,
+					user_id text NOT NULL,
+					change text NOT NULL,
+					name text NOT NULL,
+					deleted boolean NOT NULL DEFAULT false,
+					profile jsonb,
+					changed_at timestamp with time zone NOT NULL DEFAULT now(),
+					CONSTRAINT user_profile_history_pkey PRIMARY KEY (id),
+					CONSTRAINT user_profile_history_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX user_profile_history_idx_user ON public.user_profile_history (user_id, changed_at);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE user_profile_history;
+		`)
+		return err
+	})
+}
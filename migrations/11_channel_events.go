@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE public.channels ADD COLUMN is_deleted boolean NOT NULL DEFAULT false;
+
+			-- Each channel's timeline: created, archived, renamed, new
+			-- topics and so on. user_id isn't a foreign key, changes can
+			-- be made by users we haven't archived.
+			CREATE TABLE public.channel_events (
+					id bigserial NOT NULL,
+					channel_id text NOT NULL,
+					type text NOT NULL,
+					user_id text,
+					name text,
+					old_name text,
+					value text,
+					"timestamp" timestamp with time zone NOT NULL,
+					CONSTRAINT channel_events_pkey PRIMARY KEY (id),
+					CONSTRAINT channel_events_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX channel_events_idx_channel ON public.channel_events (channel_id, "timestamp");
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE channel_events;
+			ALTER TABLE channels DROP COLUMN is_deleted;
+		`)
+		return err
+	})
+}
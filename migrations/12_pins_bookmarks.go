@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- What's pinned to channels, with a copy of the pinned message
+			-- or file. Unpinned items are kept with removed_at set.
+			CREATE TABLE public.pins (
+					channel_id text NOT NULL,
+					item_id text NOT NULL,
+					type text NOT NULL,
+					item jsonb,
+					pinned_by text,
+					pinned_at timestamp with time zone NOT NULL,
+					removed_at timestamp with time zone,
+					CONSTRAINT pins_pkey PRIMARY KEY (channel_id, item_id),
+					CONSTRAINT pins_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE
+			);
+
+			-- Links bookmarked in channel headers, kept once removed too
+			CREATE TABLE public.bookmarks (
+					id text NOT NULL,
+					channel_id text NOT NULL,
+					title text NOT NULL,
+					link text NOT NULL,
+					emoji text,
+					icon_url text,
+					type text NOT NULL,
+					rank text,
+					updated_by text,
+					created_at timestamp with time zone NOT NULL,
+					updated_at timestamp with time zone NOT NULL,
+					removed_at timestamp with time zone,
+					CONSTRAINT bookmarks_pkey PRIMARY KEY (id),
+					CONSTRAINT bookmarks_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX bookmarks_idx_channel ON public.bookmarks (channel_id);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE bookmarks;
+			DROP TABLE pins;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Audit log of the messages the retention purge deleted. It outlives
+			-- the channels, so channel_id isn't a foreign key.
+			CREATE TABLE public.purges (
+					id bigserial NOT NULL,
+					team_id text NOT NULL,
+					channel_id text NOT NULL,
+					policy text NOT NULL,
+					before timestamp with time zone NOT NULL,
+					messages integer NOT NULL,
+					pins integer NOT NULL,
+					oldest timestamp with time zone,
+					newest timestamp with time zone,
+					started_at timestamp with time zone NOT NULL,
+					finished_at timestamp with time zone NOT NULL,
+					CONSTRAINT purges_pkey PRIMARY KEY (id)
+			);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE purges;
+		`)
+		return err
+	})
+}
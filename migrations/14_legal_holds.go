@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Holds freezing the messages of some users or channels, posted
+			-- between posted_from and posted_to, for litigation
+			CREATE TABLE public.legal_holds (
+					id bigserial NOT NULL,
+					name text NOT NULL,
+					description text,
+					user_ids text[],
+					channel_ids text[],
+					posted_from timestamp with time zone,
+					posted_to timestamp with time zone,
+					created_by text NOT NULL,
+					created_at timestamp with time zone NOT NULL DEFAULT now(),
+					released_by text,
+					released_at timestamp with time zone,
+					CONSTRAINT legal_holds_pkey PRIMARY KEY (id)
+			);
+
+			-- Messages deleted in Slack while under a hold are kept, marked
+			-- deleted
+			ALTER TABLE public.messages ADD COLUMN deleted_at timestamp with time zone;
+
+			-- Earlier versions of messages edited while under a hold
+			CREATE TABLE public.message_revisions (
+					id bigserial NOT NULL,
+					channel_id text NOT NULL,
+					user_id text NOT NULL,
+					"timestamp" timestamp with time zone NOT NULL,
+					msg jsonb,
+					replaced_at timestamp with time zone NOT NULL,
+					CONSTRAINT message_revisions_pkey PRIMARY KEY (id),
+					CONSTRAINT message_revisions_message_fkey FOREIGN KEY (channel_id, user_id, "timestamp")
+						REFERENCES messages(channel_id, user_id, "timestamp") ON DELETE CASCADE
+			);
+
+			CREATE INDEX message_revisions_idx_message ON public.message_revisions (channel_id, "timestamp");
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE message_revisions;
+			ALTER TABLE messages DROP COLUMN deleted_at;
+			DROP TABLE legal_holds;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Who called the API for what. Entries outlive the users and
+			-- channels, so neither is a foreign key.
+			CREATE TABLE public.audit_log (
+					id bigserial NOT NULL,
+					user_id text NOT NULL,
+					method text NOT NULL,
+					route text NOT NULL,
+					path text NOT NULL,
+					params jsonb,
+					channel_id text,
+					status integer NOT NULL,
+					remote_addr text,
+					user_agent text,
+					created_at timestamp with time zone NOT NULL,
+					CONSTRAINT audit_log_pkey PRIMARY KEY (id)
+			);
+
+			CREATE INDEX audit_log_idx_created_at ON public.audit_log (created_at);
+			CREATE INDEX audit_log_idx_user ON public.audit_log (user_id, created_at);
+			CREATE INDEX audit_log_idx_channel ON public.audit_log (channel_id, created_at);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE audit_log;
+		`)
+		return err
+	})
+}
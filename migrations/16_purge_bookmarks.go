@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- The purge deletes bookmarks past retention too
+			ALTER TABLE public.purges ADD COLUMN bookmarks integer NOT NULL DEFAULT 0;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE purges DROP COLUMN bookmarks;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- When a message was archived, which for messages a sync or an
+			-- import caught up on is long after it was posted. Saved search
+			-- digests go by it. Messages archived before now don't have one,
+			-- so they aren't all new at once.
+			ALTER TABLE public.messages ADD COLUMN archived_at timestamp with time zone;
+			ALTER TABLE public.messages ALTER COLUMN archived_at SET DEFAULT now();
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE messages DROP COLUMN archived_at;
+		`)
+		return err
+	})
+}
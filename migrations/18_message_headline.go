@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- ts_headline with the simple parser sees a CJK run as one word,
+			-- so it never highlights the single characters the query matched.
+			-- Split the run like split_cjk does, but with \x01 (a separator
+			-- to the parser) rather than spaces so it can be taken out again.
+			CREATE FUNCTION message_headline(config text, body text, query tsquery, options text) RETURNS text
+			LANGUAGE sql STABLE
+			AS $function$
+				SELECT CASE WHEN config = 'cjk'
+					THEN replace(ts_headline('simple', regexp_replace(body, '([぀-ヿ㐀-䶿一-鿿가-힯])', chr(1) || '\1' || chr(1), 'g'), query, options), chr(1), '')
+					ELSE ts_headline(config::regconfig, body, query, options)
+				END
+			$function$;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP FUNCTION message_headline(text, text, tsquery, text);
+		`)
+		return err
+	})
+}
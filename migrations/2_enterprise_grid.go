@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE public.teams
+				ADD COLUMN enterprise_id text,
+				ADD COLUMN enterprise_name text;
+
+			ALTER TABLE public.users
+				ADD COLUMN enterprise_id text;
+
+			ALTER TABLE public.channels
+				ADD COLUMN is_shared boolean NOT NULL DEFAULT false,
+				ADD COLUMN is_org_shared boolean NOT NULL DEFAULT false;
+
+			-- Enterprise Grid channels can be shared between several workspaces of
+			-- the same org. channels.team_id remains the workspace we archive the
+			-- channel through, channel_teams lists every workspace it shows up in.
+			CREATE TABLE public.channel_teams (
+					channel_id text NOT NULL,
+					team_id text NOT NULL,
+					CONSTRAINT channel_teams_pkey PRIMARY KEY (channel_id, team_id),
+					CONSTRAINT channel_teams_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES public.channels(id),
+					CONSTRAINT channel_teams_team_id_fkey FOREIGN KEY (team_id) REFERENCES public.teams(id)
+			);
+
+			CREATE INDEX channel_teams_idx_team ON public.channel_teams USING btree (team_id);
+			CREATE INDEX teams_idx_enterprise ON public.teams USING btree (enterprise_id);
+
+			INSERT INTO public.channel_teams (channel_id, team_id)
+				SELECT id, team_id FROM public.channels;
+	`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE channel_teams;
+			ALTER TABLE channels DROP COLUMN is_shared, DROP COLUMN is_org_shared;
+			ALTER TABLE users DROP COLUMN enterprise_id;
+			ALTER TABLE teams DROP COLUMN enterprise_id, DROP COLUMN enterprise_name;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Slack Connect users belong to another organisation. They are stored
+			-- under the team whose channel we saw them in, with home_team_id
+			-- pointing at their own (unarchived) team.
+			ALTER TABLE public.users
+				ADD COLUMN is_external boolean NOT NULL DEFAULT false,
+				ADD COLUMN home_team_id text,
+				ADD COLUMN home_team_name text;
+
+			ALTER TABLE public.channels
+				ADD COLUMN is_ext_shared boolean NOT NULL DEFAULT false;
+	`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE channels DROP COLUMN is_ext_shared;
+			ALTER TABLE users DROP COLUMN is_external, DROP COLUMN home_team_id, DROP COLUMN home_team_name;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,133 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- The text search configuration of every team and channel, copied
+			-- from the search section of the config file on startup. scope is
+			-- 'default', 'team' or 'channel'; id is empty for the default.
+			CREATE TABLE public.search_configs (
+					scope text NOT NULL,
+					id text NOT NULL DEFAULT '',
+					config text NOT NULL,
+					CONSTRAINT search_configs_pkey PRIMARY KEY (scope, id)
+			);
+
+			-- The configuration each message was indexed with, so we know what
+			-- to rebuild when it changes
+			ALTER TABLE public.messages ADD COLUMN tsv_config text;
+
+			ALTER TABLE public.messages DISABLE TRIGGER tsvectorupdate;
+			UPDATE public.messages SET tsv_config = current_setting('default_text_search_config');
+			ALTER TABLE public.messages ENABLE TRIGGER tsvectorupdate;
+
+			CREATE FUNCTION message_search_config(channel text) RETURNS text
+			LANGUAGE sql STABLE
+			AS $function$
+				SELECT coalesce(
+					(SELECT config FROM search_configs WHERE scope = 'channel' AND id = channel),
+					(SELECT sc.config FROM search_configs AS sc JOIN channels AS c ON sc.scope = 'team' AND sc.id = c.team_id WHERE c.id = channel),
+					(SELECT config FROM search_configs WHERE scope = 'default'),
+					current_setting('default_text_search_config')
+				)
+			$function$;
+
+			-- 'cjk' isn't a real configuration: it indexes Chinese, Japanese
+			-- and Korean text one character at a time with the simple parser,
+			-- for servers without a CJK parser like zhparser.
+			CREATE FUNCTION search_regconfig(config text) RETURNS regconfig
+			LANGUAGE sql STABLE
+			AS $function$
+				SELECT CASE WHEN config = 'cjk' THEN 'simple'::regconfig ELSE config::regconfig END
+			$function$;
+
+			-- Kana, CJK ideographs and Hangul
+			CREATE FUNCTION split_cjk(body text) RETURNS text
+			LANGUAGE sql IMMUTABLE
+			AS $function$
+				SELECT regexp_replace(body, '([\u3040-\u30ff\u3400-\u4dbf\u4e00-\u9fff\uac00-\ud7af])', ' \1 ', 'g')
+			$function$;
+
+			CREATE FUNCTION message_tsvector(config text, body text) RETURNS tsvector
+			LANGUAGE sql STABLE
+			AS $function$
+				SELECT CASE WHEN config = 'cjk'
+					THEN to_tsvector('simple', split_cjk(coalesce(body, '')))
+					ELSE to_tsvector(config::regconfig, coalesce(body, ''))
+				END
+			$function$;
+
+			-- Search terms are parsed with every configuration in use, so a
+			-- query matches messages indexed with any of them. CJK runs become
+			-- phrases of single characters.
+			CREATE AGGREGATE tsquery_or_agg(tsquery) (
+			 STYPE = pg_catalog.tsquery,
+			 SFUNC = pg_catalog.tsquery_or
+			);
+
+			CREATE FUNCTION message_search_query(query text) RETURNS tsquery
+			LANGUAGE sql STABLE
+			AS $function$
+				SELECT tsquery_or_agg(CASE WHEN config = 'cjk'
+					THEN websearch_to_tsquery('simple', split_cjk(regexp_replace(query, '([\u3040-\u30ff\u3400-\u4dbf\u4e00-\u9fff\uac00-\ud7af]+)', '"\1"', 'g')))
+					ELSE websearch_to_tsquery(config::regconfig, query)
+				END)
+				FROM (
+					SELECT config FROM search_configs
+					UNION
+					SELECT current_setting('default_text_search_config')
+				) AS configs
+			$function$;
+
+			CREATE OR REPLACE FUNCTION messages_upsert_trigger() RETURNS trigger AS $$
+			begin
+				new.tsv_config := message_search_config(new.channel_id);
+				new.tsv :=
+					setweight(message_tsvector(new.tsv_config, new.msg->>'text'), 'A') ||
+					setweight(
+							tsvector_concat(
+									array(
+											SELECT message_tsvector(new.tsv_config, a->>'title') ||
+																		message_tsvector(new.tsv_config, a->>'title_link')
+											FROM jsonb_array_elements(new.msg->'attachments') AS a
+									)
+							), 'B');
+				return new;
+			end
+			$$ LANGUAGE plpgsql;
+	`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			CREATE OR REPLACE FUNCTION messages_upsert_trigger() RETURNS trigger AS $$
+			begin
+				new.tsv :=
+					setweight(to_tsvector(coalesce(new.msg->>'text','')), 'A') ||
+					setweight(
+							tsvector_concat(
+									array(
+											SELECT to_tsvector(coalesce(a->>'title','')) ||
+																		to_tsvector(coalesce(a->>'title_link',''))
+											FROM jsonb_array_elements(new.msg->'attachments') AS a
+									)
+							), 'B');
+				return new;
+			end
+			$$ LANGUAGE plpgsql;
+
+			DROP FUNCTION message_search_query(text);
+			DROP AGGREGATE tsquery_or_agg(tsquery);
+			DROP FUNCTION message_tsvector(text, text);
+			DROP FUNCTION split_cjk(text);
+			DROP FUNCTION search_regconfig(text);
+			DROP FUNCTION message_search_config(text);
+			ALTER TABLE messages DROP COLUMN tsv_config;
+			DROP TABLE search_configs;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,109 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Every string value of a field called name, at any depth of doc.
+			-- Block kit nests text objects in sections, fields, contexts and
+			-- rich text elements, so there's no fixed path to them.
+			CREATE FUNCTION jsonb_field_strings(doc jsonb, name text) RETURNS SETOF text
+			LANGUAGE sql IMMUTABLE
+			AS $function$
+				WITH RECURSIVE nodes(key, value) AS (
+					SELECT NULL::text, doc
+					UNION ALL
+					SELECT children.key, children.value
+					FROM nodes, LATERAL (
+						SELECT o.key, o.value
+						FROM jsonb_each(CASE WHEN jsonb_typeof(nodes.value) = 'object' THEN nodes.value ELSE '{}'::jsonb END) AS o
+						UNION ALL
+						SELECT nodes.key, a.value
+						FROM jsonb_array_elements(CASE WHEN jsonb_typeof(nodes.value) = 'array' THEN nodes.value ELSE '[]'::jsonb END) AS a
+					) AS children
+				)
+				SELECT value #>> '{}' FROM nodes WHERE key = name AND jsonb_typeof(value) = 'string'
+			$function$;
+
+			-- The searchable content of a message besides its text, titles
+			-- and file names: block kit, attachment bodies and fields, and
+			-- file previews
+			CREATE FUNCTION message_body(msg jsonb) RETURNS text
+			LANGUAGE sql IMMUTABLE
+			AS $function$
+				SELECT string_agg(body, ' ') FROM (
+					SELECT jsonb_field_strings(msg->'blocks', 'text') AS body
+					UNION ALL
+					SELECT jsonb_field_strings(msg->'attachments', 'text')
+					UNION ALL
+					SELECT concat_ws(' ', a->>'pretext', a->>'fallback', a->>'footer')
+					FROM jsonb_array_elements(msg->'attachments') AS a
+					UNION ALL
+					SELECT concat_ws(' ', f->>'title', f->>'value')
+					FROM jsonb_array_elements(msg->'attachments') AS a, jsonb_array_elements(a->'fields') AS f
+					UNION ALL
+					SELECT f->>'preview'
+					FROM jsonb_array_elements(msg->'files') AS f
+				) AS bodies
+			$function$;
+
+			-- Text is weighted A, attachment titles, links and file names B,
+			-- and everything else C
+			CREATE OR REPLACE FUNCTION messages_upsert_trigger() RETURNS trigger AS $$
+			begin
+				new.tsv_config := message_search_config(new.channel_id);
+				new.tsv :=
+					setweight(message_tsvector(new.tsv_config, new.msg->>'text'), 'A') ||
+					setweight(
+							tsvector_concat(
+									array(
+											SELECT message_tsvector(new.tsv_config, a->>'title') ||
+																		message_tsvector(new.tsv_config, a->>'title_link')
+											FROM jsonb_array_elements(new.msg->'attachments') AS a
+									)
+							) ||
+							tsvector_concat(
+									array(
+											SELECT message_tsvector(new.tsv_config, f->>'name') ||
+																		message_tsvector(new.tsv_config, f->>'title')
+											FROM jsonb_array_elements(new.msg->'files') AS f
+									)
+							), 'B') ||
+					setweight(message_tsvector(new.tsv_config, message_body(new.msg)), 'C');
+				return new;
+			end
+			$$ LANGUAGE plpgsql;
+	`)
+		// Messages archived earlier are indexed the new way when they're
+		// next updated, or by reindex --all, which goes in batches rather
+		// than locking the whole table while the archive starts up
+		// (see NeedsReindex)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			CREATE OR REPLACE FUNCTION messages_upsert_trigger() RETURNS trigger AS $$
+			begin
+				new.tsv_config := message_search_config(new.channel_id);
+				new.tsv :=
+					setweight(message_tsvector(new.tsv_config, new.msg->>'text'), 'A') ||
+					setweight(
+							tsvector_concat(
+									array(
+											SELECT message_tsvector(new.tsv_config, a->>'title') ||
+																		message_tsvector(new.tsv_config, a->>'title_link')
+											FROM jsonb_array_elements(new.msg->'attachments') AS a
+									)
+							), 'B');
+				return new;
+			end
+			$$ LANGUAGE plpgsql;
+
+			DROP FUNCTION message_body(jsonb);
+			DROP FUNCTION jsonb_field_strings(jsonb, text);
+		`)
+		return err
+	})
+}
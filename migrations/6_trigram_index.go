@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Exact and fuzzy search match substrings of the message text,
+			-- which a trigram index can speed up. pg_trgm is a contrib module
+			-- that not every server has, or lets us create, and search still
+			-- works without it, so carry on if it's missing.
+			DO $$
+			BEGIN
+				CREATE EXTENSION IF NOT EXISTS pg_trgm;
+				CREATE INDEX messages_idx_text_trgm ON public.messages USING gin ((msg->>'text') gin_trgm_ops);
+			EXCEPTION WHEN OTHERS THEN
+				RAISE WARNING 'pg_trgm is unavailable, substring search will be slow and fuzzy search exact: %', SQLERRM;
+			END
+			$$;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP INDEX IF EXISTS messages_idx_text_trgm;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Searches users come back to. user_id isn't a foreign key, people
+			-- can sign in before the bot has archived their profile.
+			CREATE TABLE public.saved_searches (
+					id bigserial NOT NULL,
+					user_id text NOT NULL,
+					team_id text NOT NULL,
+					name text NOT NULL,
+					query text NOT NULL,
+					mode text NOT NULL DEFAULT '',
+					channel_id text,
+					notify boolean NOT NULL DEFAULT false,
+					created_at timestamp with time zone NOT NULL DEFAULT now(),
+					last_seen_at timestamp with time zone NOT NULL DEFAULT now(),
+					last_notified_at timestamp with time zone,
+					CONSTRAINT saved_searches_pkey PRIMARY KEY (id),
+					CONSTRAINT saved_searches_team_id_fkey FOREIGN KEY (team_id) REFERENCES teams(id),
+					CONSTRAINT saved_searches_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX saved_searches_idx_user ON public.saved_searches (user_id);
+			CREATE INDEX saved_searches_idx_team ON public.saved_searches (team_id) WHERE notify;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE saved_searches;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+// Cursor pagination goes through messages by ("timestamp", channel_id),
+// within a channel or across all of them. messages is the biggest table and
+// the migrations run as the archive starts, so the indexes are built
+// concurrently rather than stopping writes until they're done, which can't
+// be in a transaction. Each is dropped first in case an interrupted run left
+// an invalid one behind. messages_idx_channel is the start of
+// messages_idx_channel_timestamp, so it goes, as SQLite's index on
+// "timestamp" alone does.
+func init() {
+	migrations.MustRegister(func(db migrations.DB) error {
+		return execEach(db,
+			`DROP INDEX CONCURRENTLY IF EXISTS public.messages_idx_channel_timestamp`,
+			`CREATE INDEX CONCURRENTLY messages_idx_channel_timestamp ON public.messages USING btree (channel_id, "timestamp")`,
+			`DROP INDEX CONCURRENTLY IF EXISTS public.messages_idx_timestamp_channel`,
+			`CREATE INDEX CONCURRENTLY messages_idx_timestamp_channel ON public.messages USING btree ("timestamp", channel_id)`,
+			`DROP INDEX CONCURRENTLY IF EXISTS public.messages_idx_channel`,
+		)
+	}, func(db migrations.DB) error {
+		return execEach(db,
+			`CREATE INDEX CONCURRENTLY IF NOT EXISTS messages_idx_channel ON public.messages USING btree (channel_id)`,
+			`DROP INDEX CONCURRENTLY IF EXISTS public.messages_idx_channel_timestamp`,
+			`DROP INDEX CONCURRENTLY IF EXISTS public.messages_idx_timestamp_channel`,
+		)
+	})
+}
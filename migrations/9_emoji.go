@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"github.com/go-pg/migrations"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Custom emoji, kept once deleted from Slack so old messages
+			-- still show them. The images are in the blob store.
+			CREATE TABLE public.emoji (
+					team_id text NOT NULL,
+					name text NOT NULL,
+					alias_for text,
+					url text,
+					blob_key text,
+					content_type text,
+					created_at timestamp with time zone NOT NULL DEFAULT now(),
+					updated_at timestamp with time zone NOT NULL DEFAULT now(),
+					deleted_at timestamp with time zone,
+					CONSTRAINT emoji_pkey PRIMARY KEY (team_id, name),
+					CONSTRAINT emoji_team_id_fkey FOREIGN KEY (team_id) REFERENCES teams(id)
+			);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE emoji;
+		`)
+		return err
+	})
+}
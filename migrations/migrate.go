@@ -0,0 +1,104 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations"
+)
+
+const tableName = "gopg_migrations"
+
+// LatestVersion is the newest schema version this binary knows about.
+func LatestVersion() int64 {
+	var latest int64
+	for _, m := range migrations.RegisteredMigrations() {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+	return latest
+}
+
+// searchContentVersion started indexing block kit, attachment bodies and
+// file previews.
+const searchContentVersion = 5
+
+// NeedsReindex tells whether messages archived before migrating from
+// oldVersion to newVersion have to be reindexed, with reindex --all, to be
+// found like new ones.
+func NeedsReindex(oldVersion, newVersion int64) bool {
+	return oldVersion > 0 && oldVersion < searchContentVersion && newVersion >= searchContentVersion
+}
+
+// execEach runs statements one at a time. Postgres runs the statements of
+// a single Exec in a transaction, which CREATE INDEX CONCURRENTLY refuses.
+func execEach(db migrations.DB, statements ...string) error {
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tableExists(db migrations.DB, table string) (bool, error) {
+	n, err := db.Model().
+		Table("pg_tables").
+		Where("schemaname = 'public'").
+		Where("tablename = ?", table).
+		Count()
+	return n == 1, err
+}
+
+// Version returns the current schema version of db, 0 for an empty database.
+func Version(db migrations.DB) (int64, error) {
+	if exists, err := tableExists(db, tableName); err != nil || !exists {
+		return 0, err
+	}
+	return migrations.Version(db)
+}
+
+// Check refuses databases whose schema is newer than this binary, i.e. ones
+// that have been migrated by a later release.
+func Check(db migrations.DB) (int64, error) {
+	version, err := Version(db)
+	if err != nil {
+		return 0, err
+	}
+
+	if latest := LatestVersion(); version > latest {
+		return version, fmt.Errorf("database schema version %d is newer than this release supports (%d), please upgrade slackarchive", version, latest)
+	}
+	return version, nil
+}
+
+// Migrate brings db up to the latest schema version, creating the migrations
+// table on a fresh database.
+func Migrate(db migrations.DB) (oldVersion, newVersion int64, err error) {
+	if _, err = Check(db); err != nil {
+		return
+	}
+
+	exists, err := tableExists(db, tableName)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		// Archives created before we tracked migrations had their tables
+		// created straight from the Go structs, without the search index.
+		var legacy bool
+		if legacy, err = tableExists(db, "teams"); err != nil {
+			return
+		} else if legacy {
+			err = fmt.Errorf("database has tables but no migration history; check it against migrations/ and record the matching version with `migrate init` and `migrate set_version`")
+			return
+		}
+
+		if _, _, err = migrations.Run(db, "init"); err != nil {
+			return
+		}
+	}
+
+	return migrations.Run(db, "up")
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// AuditEntry records an API call by a signed in user: who searched for
+// what, which channels they looked at, what they exported.
+type AuditEntry struct {
+	tableName struct{} `sql:"audit_log"`
+
+	ID     int64  `json:"id"`
+	UserID string `sql:",notnull" json:"user_id"`
+	Method string `sql:",notnull" json:"method"`
+	// Route is the endpoint called, like /v1/channels/{id}/pins, and Path
+	// the URL path with the IDs filled in
+	Route string `sql:",notnull" json:"route"`
+	Path  string `sql:",notnull" json:"path"`
+	// Params are the query string, such as the search query and filters
+	Params map[string][]string `json:"params,omitempty"`
+	// ChannelID is the channel looked at or searched in, if any
+	ChannelID  string    `json:"channel_id,omitempty"`
+	Status     int       `sql:",notnull" json:"status"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `sql:",notnull" json:"created_at"`
+}
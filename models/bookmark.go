@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Bookmark is a link bookmarked in a channel's header. Removed ones are
+// kept with RemovedAt set.
+type Bookmark struct {
+	tableName struct{} `sql:"bookmarks"`
+
+	ID        string     `json:"id"`
+	ChannelID string     `sql:",notnull" json:"channel_id"`
+	Title     string     `sql:",notnull" json:"title"`
+	Link      string     `sql:",notnull" json:"link"`
+	Emoji     string     `json:"emoji,omitempty"`
+	IconURL   string     `json:"icon_url,omitempty"`
+	Type      string     `sql:",notnull" json:"type"`
+	Rank      string     `json:"rank,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	CreatedAt time.Time  `sql:",notnull" json:"created_at"`
+	UpdatedAt time.Time  `sql:",notnull" json:"updated_at"`
+	RemovedAt *time.Time `json:"removed_at,omitempty"`
+}
+
+func NewBookmark(b *slack.Bookmark) *Bookmark {
+	return &Bookmark{
+		ID:        b.ID,
+		ChannelID: b.ChannelID,
+		Title:     b.Title,
+		Link:      b.Link,
+		Emoji:     b.Emoji,
+		IconURL:   b.IconURL,
+		Type:      b.Type,
+		Rank:      b.Rank,
+		UpdatedBy: b.LastUpdatedByUserID,
+		CreatedAt: b.Created.Time(),
+		UpdatedAt: b.Updated.Time(),
+	}
+}
@@ -1,12 +1,7 @@
 package models
 
 import (
-	"net/url"
-
 	"github.com/slack-go/slack"
-
-	"github.com/go-pg/pg/orm"
-	"github.com/go-pg/pg/urlvalues"
 )
 
 type Channel struct {
@@ -19,6 +14,7 @@ type Channel struct {
 	CreatorID  string `pg:"fk:User"`
 	Creator    *User
 	IsArchived bool     `sql:",notnull"`
+	IsDeleted  bool     `sql:",notnull"`
 	IsGeneral  bool     `sql:",notnull"`
 	IsGroup    bool     `sql:",notnull"`
 	Members    []string `sql:",array"`
@@ -30,6 +26,12 @@ type Channel struct {
 	UnreadCount        int
 	NumMembers         int `sql:",notnull"`
 	UnreadCountDisplay int
+
+	// Enterprise Grid channels shared with other workspaces of the org
+	IsShared    bool `sql:",notnull"`
+	IsOrgShared bool `sql:",notnull"`
+	// Slack Connect channel shared with another organisation
+	IsExtShared bool `sql:",notnull"`
 }
 
 // Purpose contains information about the topic
@@ -46,33 +48,10 @@ type Topic struct {
 	LastSet slack.JSONTime
 }
 
-type ChannelFilter struct {
-	TeamID string
-	urlvalues.Pager
-}
-
-// NewPager creates a go-pg Pager from net/url.Values using our custom field names
-func NewPager(form url.Values) urlvalues.Pager {
-	var pager urlvalues.Pager
-
-	var values urlvalues.Values = (urlvalues.Values)(form)
-
-	if val, err := values.Int("offset"); err == nil {
-		pager.Offset = val
-	}
-	if val, err := values.Int("size"); err == nil {
-		pager.Limit = val
-	}
-
-	return pager
-}
-
-func (f *ChannelFilter) Filter(q *orm.Query) (*orm.Query, error) {
-	if f.TeamID != "" {
-		q = q.Where("?TableAlias.team_id = ?", f.TeamID)
-	}
-
-	q = q.Apply(f.Pager.Pagination)
-
-	return q, nil
+// ChannelTeam records that a channel is visible in a workspace. A channel
+// shared across an Enterprise Grid org is stored once, with one ChannelTeam
+// per workspace.
+type ChannelTeam struct {
+	ChannelID string `sql:",pk"`
+	TeamID    string `sql:",pk"`
 }
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Types of ChannelEvent
+const (
+	ChannelCreated    = "created"
+	ChannelArchived   = "archived"
+	ChannelUnarchived = "unarchived"
+	ChannelDeleted    = "deleted"
+	ChannelRenamed    = "renamed"
+	ChannelTopic      = "topic"
+	ChannelPurpose    = "purpose"
+)
+
+// ChannelEvent is a change in a channel's life, for its timeline.
+type ChannelEvent struct {
+	tableName struct{} `sql:"channel_events"`
+
+	ID        int64  `json:"id"`
+	ChannelID string `sql:",notnull" json:"channel_id"`
+	Type      string `sql:",notnull" json:"type"`
+	// UserID is who made the change, when Slack tells us
+	UserID string `json:"user_id,omitempty"`
+	// Name is the channel's name after a rename, OldName the one before
+	Name    string `json:"name,omitempty"`
+	OldName string `json:"old_name,omitempty"`
+	// Value is the new topic or purpose
+	Value     string    `json:"value,omitempty"`
+	Timestamp time.Time `sql:",notnull" json:"timestamp"`
+}
+
+// Apply makes c reflect e, filling in e.OldName for renames. It returns
+// false when c already did, say when both the channel_rename event and the
+// channel_name message of a rename come in.
+func (c *Channel) Apply(e *ChannelEvent) bool {
+	switch e.Type {
+	case ChannelArchived, ChannelUnarchived:
+		archived := e.Type == ChannelArchived
+		if c.IsArchived == archived {
+			return false
+		}
+		c.IsArchived = archived
+	case ChannelDeleted:
+		if c.IsDeleted {
+			return false
+		}
+		c.IsDeleted = true
+	case ChannelRenamed:
+		if c.Name == e.Name {
+			return false
+		}
+		if e.OldName == "" {
+			e.OldName = c.Name
+		}
+		c.Name = e.Name
+	case ChannelTopic:
+		if c.Topic.Value == e.Value {
+			return false
+		}
+		c.Topic = Topic{Value: e.Value, Creator: e.UserID, LastSet: slack.JSONTime(e.Timestamp.Unix())}
+	case ChannelPurpose:
+		if c.Purpose.Value == e.Value {
+			return false
+		}
+		c.Purpose = Purpose{Value: e.Value, Creator: e.UserID, LastSet: slack.JSONTime(e.Timestamp.Unix())}
+	}
+	return true
+}
+
+// ChannelChanges lists the events that turned old in to c, for changes we
+// only notice when syncing. They happened at some point before at, topic
+// and purpose changes say when.
+func ChannelChanges(old *Channel, c *Channel, at time.Time) []ChannelEvent {
+	var events []ChannelEvent
+	if c.Name != old.Name {
+		events = append(events, ChannelEvent{Type: ChannelRenamed, Name: c.Name, OldName: old.Name, Timestamp: at})
+	}
+	if c.IsArchived != old.IsArchived {
+		typ := ChannelUnarchived
+		if c.IsArchived {
+			typ = ChannelArchived
+		}
+		events = append(events, ChannelEvent{Type: typ, Timestamp: at})
+	}
+	if c.Topic.Value != old.Topic.Value {
+		events = append(events, ChannelEvent{Type: ChannelTopic, UserID: c.Topic.Creator, Value: c.Topic.Value, Timestamp: lastSet(c.Topic.LastSet, at)})
+	}
+	if c.Purpose.Value != old.Purpose.Value {
+		events = append(events, ChannelEvent{Type: ChannelPurpose, UserID: c.Purpose.Creator, Value: c.Purpose.Value, Timestamp: lastSet(c.Purpose.LastSet, at)})
+	}
+	for i := range events {
+		events[i].ChannelID = c.ID
+	}
+	return events
+}
+
+func lastSet(t slack.JSONTime, or time.Time) time.Time {
+	if t == 0 {
+		return or
+	}
+	return t.Time()
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Emoji is a team's custom emoji. Ones removed from Slack are kept with
+// DeletedAt set, so old messages and reactions using them still show.
+type Emoji struct {
+	tableName struct{} `sql:"emoji"`
+
+	TeamID string `sql:",pk" json:"team_id"`
+	Name   string `sql:",pk" json:"name"`
+	// AliasFor is the name of the emoji this one is another name for,
+	// custom or standard. Aliases have no image of their own.
+	AliasFor string `json:"alias_for,omitempty"`
+	// URL is where Slack serves the image
+	URL string `json:"-"`
+	// BlobKey is the key of the downloaded image in the blob store
+	BlobKey     string `json:"-"`
+	ContentType string `json:"-"`
+
+	CreatedAt time.Time  `sql:",notnull" json:"created_at"`
+	UpdatedAt time.Time  `sql:",notnull" json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ParseEmojiValue splits a value from emoji.list, an image URL or
+// alias:name.
+func ParseEmojiValue(value string) (url string, aliasFor string) {
+	if strings.HasPrefix(value, "alias:") {
+		return "", strings.TrimPrefix(value, "alias:")
+	}
+	return value, ""
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// LegalHold freezes the messages of some users or channels, posted within a
+// date range, for litigation. While a hold is active the messages it covers
+// aren't purged, deleting them in Slack only marks them deleted and edits
+// keep their earlier versions.
+type LegalHold struct {
+	tableName struct{} `sql:"legal_holds"`
+
+	ID          int64  `json:"id"`
+	Name        string `sql:",notnull" json:"name"`
+	Description string `json:"description,omitempty"`
+	// UserIDs and ChannelIDs narrow the hold to messages posted by these
+	// users in these channels. Either may be empty, not both.
+	UserIDs    []string   `sql:",array" json:"user_ids"`
+	ChannelIDs []string   `sql:",array" json:"channel_ids"`
+	PostedFrom *time.Time `json:"from,omitempty"`
+	PostedTo   *time.Time `json:"to,omitempty"`
+	CreatedBy  string     `sql:",notnull" json:"created_by"`
+	CreatedAt  time.Time  `sql:",notnull" json:"created_at"`
+	ReleasedBy string     `json:"released_by,omitempty"`
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+}
+
+func (h *LegalHold) Active() bool {
+	return h.ReleasedAt == nil
+}
+
+// InChannel tells whether the hold covers any message of a channel.
+func (h *LegalHold) InChannel(channelID string) bool {
+	return h.Active() && (len(h.ChannelIDs) == 0 || contains(h.ChannelIDs, channelID))
+}
+
+// Covers tells whether the hold covers a message.
+func (h *LegalHold) Covers(channelID, userID string, ts time.Time) bool {
+	return h.InChannel(channelID) &&
+		(len(h.UserIDs) == 0 || contains(h.UserIDs, userID)) &&
+		(h.PostedFrom == nil || !ts.Before(*h.PostedFrom)) &&
+		(h.PostedTo == nil || !ts.After(*h.PostedTo))
+}
+
+// Held tells whether any of holds covers a message.
+func Held(holds []LegalHold, channelID, userID string, ts time.Time) bool {
+	for i := range holds {
+		if holds[i].Covers(channelID, userID, ts) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeld(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	dayPtr := func(d int) *time.Time { t := day(d); return &t }
+
+	users := LegalHold{UserIDs: []string{"U1", "U2"}}
+	channels := LegalHold{ChannelIDs: []string{"C1"}}
+	both := LegalHold{UserIDs: []string{"U3"}, ChannelIDs: []string{"C2"}, PostedFrom: dayPtr(10), PostedTo: dayPtr(20)}
+	released := LegalHold{ChannelIDs: []string{"C9"}, ReleasedAt: dayPtr(1)}
+
+	tests := []struct {
+		name    string
+		holds   []LegalHold
+		channel string
+		user    string
+		ts      time.Time
+		want    bool
+	}{
+		{"no holds", nil, "C1", "U1", day(1), false},
+		{"user anywhere", []LegalHold{users}, "C5", "U2", day(1), true},
+		{"other user", []LegalHold{users}, "C5", "U5", day(1), false},
+		{"channel", []LegalHold{channels}, "C1", "U5", day(1), true},
+		{"other channel", []LegalHold{channels}, "C2", "U5", day(1), false},
+		{"user in channel", []LegalHold{both}, "C2", "U3", day(15), true},
+		{"user in other channel", []LegalHold{both}, "C1", "U3", day(15), false},
+		{"other user in channel", []LegalHold{both}, "C2", "U1", day(15), false},
+		{"from is inclusive", []LegalHold{both}, "C2", "U3", day(10), true},
+		{"to is inclusive", []LegalHold{both}, "C2", "U3", day(20), true},
+		{"before from", []LegalHold{both}, "C2", "U3", day(10).Add(-time.Second), false},
+		{"after to", []LegalHold{both}, "C2", "U3", day(20).Add(time.Second), false},
+		{"released", []LegalHold{released}, "C9", "U1", day(1), false},
+		{"any of holds", []LegalHold{released, both, channels}, "C1", "U9", day(1), true},
+	}
+	for _, tt := range tests {
+		if got := Held(tt.holds, tt.channel, tt.user, tt.ts); got != tt.want {
+			t.Errorf("%s: Held() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		hold    LegalHold
+		channel string
+		want    bool
+	}{
+		{"users only", LegalHold{UserIDs: []string{"U1"}}, "C1", true},
+		{"listed", LegalHold{ChannelIDs: []string{"C1", "C2"}}, "C2", true},
+		{"not listed", LegalHold{ChannelIDs: []string{"C1"}}, "C2", false},
+		{"released", LegalHold{ChannelIDs: []string{"C1"}, ReleasedAt: &time.Time{}}, "C1", false},
+	}
+	for _, tt := range tests {
+		if got := tt.hold.InChannel(tt.channel); got != tt.want {
+			t.Errorf("%s: InChannel(%s) = %v, want %v", tt.name, tt.channel, got, tt.want)
+		}
+	}
+}
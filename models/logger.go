@@ -1,14 +1,44 @@
 package models
 
 import (
+	"time"
+
 	"github.com/go-pg/pg"
-	logging "github.com/op/go-logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ashb/slackarchive/metrics"
+	"github.com/ashb/slackarchive/tracing"
 )
 
-type DBLogger struct{ *logging.Logger }
+// DBLogger times every query for the metrics, traces those run in a traced
+// context and logs them too when it has a Logger.
+type DBLogger struct{ Logger *logrus.Entry }
 
-func (d DBLogger) BeforeQuery(q *pg.QueryEvent) {}
+type queryStartedKey struct{}
+type querySpanKey struct{}
+
+func (d DBLogger) BeforeQuery(q *pg.QueryEvent) {
+	q.Data[queryStartedKey{}] = time.Now()
+	if tracing.Traced(q.Ctx) {
+		query, _ := q.UnformattedQuery()
+		_, q.Data[querySpanKey{}] = tracing.StartQuery(q.Ctx, "postgresql", query)
+	}
+}
 
 func (d DBLogger) AfterQuery(q *pg.QueryEvent) {
-	d.Debug(q.FormattedQuery())
+	if started, ok := q.Data[queryStartedKey{}].(time.Time); ok {
+		query, _ := q.UnformattedQuery()
+		metrics.DBQuery(query, time.Since(started))
+	}
+	if span, ok := q.Data[querySpanKey{}].(trace.Span); ok {
+		err := q.Error
+		if err == pg.ErrNoRows {
+			err = nil
+		}
+		tracing.EndQuery(span, err)
+	}
+	if d.Logger != nil {
+		d.Logger.Debug(q.FormattedQuery())
+	}
 }
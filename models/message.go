@@ -46,6 +46,17 @@ type Message struct {
 	ThreadTimestamp *time.Time `json:"thread_ts,omitempty" `
 
 	Msg *slack.Msg
+
+	// DeletedAt is when a message under a legal hold was deleted in Slack.
+	// Others are removed from the archive.
+	DeletedAt *time.Time `json:",omitempty"`
+	// ArchivedAt is when the message was first archived, long after it was
+	// posted for those a sync or import caught up on. Messages archived
+	// before it was recorded don't have it.
+	ArchivedAt *time.Time `sql:"default:now()" json:",omitempty"`
+
+	// Score is how well the message matched a search
+	Score float64 `sql:"-" json:",omitempty"`
 }
 
 func (m *Message) Merge(message *slack.Msg) error {
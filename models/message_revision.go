@@ -0,0 +1,41 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// MessageRevision is an earlier version of a message, kept when it was
+// edited while under a legal hold.
+type MessageRevision struct {
+	tableName struct{} `sql:"message_revisions"`
+
+	ID        int64      `json:"id"`
+	ChannelID string     `sql:",notnull" json:"channel_id"`
+	UserID    string     `sql:",notnull" json:"user_id"`
+	Timestamp time.Time  `sql:",notnull" json:"timestamp"`
+	Msg       *slack.Msg `json:"msg"`
+	// ReplacedAt is when the edit replacing this version came in
+	ReplacedAt time.Time `sql:",notnull" json:"replaced_at"`
+}
+
+// ContentChanged tells whether new says something different from old, as
+// opposed to Slack only updating reply counts or reactions.
+func ContentChanged(old, new *slack.Msg) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return old.Text != new.Text ||
+		!sameJSON(old.Attachments, new.Attachments) ||
+		!sameJSON(old.Blocks, new.Blocks) ||
+		!sameJSON(old.Files, new.Files)
+}
+
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Archive permalinks use the path of Slack's, /archives/<channel>/p<ts
+// without the dot>, so swapping the host turns one in to the other.
+
+// PermalinkPath is the path of the message posted at ts in a channel.
+func PermalinkPath(channelID string, ts string) string {
+	return "/archives/" + channelID + "/p" + strings.Replace(ts, ".", "", 1)
+}
+
+// SlackPermalink is the link to a message in the Slack workspace at domain.
+// Thread replies open in their thread.
+func SlackPermalink(domain string, channelID string, ts string, threadTs string) string {
+	link := fmt.Sprintf("https://%s.slack.com%s", domain, PermalinkPath(channelID, ts))
+	if threadTs != "" && threadTs != ts {
+		link += "?" + url.Values{"thread_ts": {threadTs}, "cid": {channelID}}.Encode()
+	}
+	return link
+}
+
+var permalinkTimestamp = regexp.MustCompile(`^p?(\d+)(\d{6})$`)
+
+// ParsePermalinkTimestamp reads a message timestamp given as in permalinks,
+// p1600000000000100, or as Slack sends them, 1600000000.000100.
+func ParsePermalinkTimestamp(s string) (string, error) {
+	if m := permalinkTimestamp.FindStringSubmatch(s); m != nil {
+		return m[1] + "." + m[2], nil
+	}
+	if !strings.Contains(s, ".") {
+		return "", fmt.Errorf("invalid message timestamp %q", s)
+	}
+	if _, err := TimestampToTime(s); err != nil {
+		return "", fmt.Errorf("invalid message timestamp %q", s)
+	}
+	return s, nil
+}
+
+// ParsePermalink reads the channel and message timestamp, and the thread
+// for replies, from a Slack or archive permalink.
+func ParsePermalink(link string) (channelID string, ts string, threadTs string, err error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-3] != "archives" {
+		return "", "", "", fmt.Errorf("not a message permalink: %s", link)
+	}
+
+	channelID = parts[len(parts)-2]
+	if ts, err = ParsePermalinkTimestamp(parts[len(parts)-1]); err != nil {
+		return "", "", "", err
+	}
+	return channelID, ts, u.Query().Get("thread_ts"), nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Pin is a message or file pinned to a channel. Unpinned ones are kept with
+// RemovedAt set.
+type Pin struct {
+	tableName struct{} `sql:"pins"`
+
+	ChannelID string `sql:",pk" json:"channel_id"`
+	// ItemID is the timestamp of pinned messages, the ID of pinned files
+	ItemID string `sql:",pk" json:"item_id"`
+	Type   string `sql:",notnull" json:"type"`
+	// Item is the pinned message or file as Slack last showed it to us
+	Item      *slack.Item `json:"item"`
+	PinnedBy  string      `json:"pinned_by,omitempty"`
+	PinnedAt  time.Time   `sql:",notnull" json:"pinned_at"`
+	RemovedAt *time.Time  `json:"removed_at,omitempty"`
+}
+
+// PinItemID identifies a pinned item in its channel: the timestamp of
+// messages, the ID of files. It's empty for items that can't be pinned any
+// more, like file comments.
+func PinItemID(item *slack.Item) string {
+	switch {
+	case item.Type == slack.TYPE_MESSAGE && item.Message != nil:
+		return item.Message.Timestamp
+	case item.Type == slack.TYPE_FILE && item.File != nil:
+		return item.File.ID
+	}
+	return ""
+}
+
+// Created is when the pinned message was posted or the file uploaded, what
+// retention goes by. Pins that don't tell go by when they were pinned.
+func (p *Pin) Created() time.Time {
+	switch {
+	case p.Type == slack.TYPE_MESSAGE:
+		if t, err := TimestampToTime(p.ItemID); err == nil && t != nil {
+			return *t
+		}
+	case p.Type == slack.TYPE_FILE && p.Item != nil && p.Item.File != nil && p.Item.File.Created != 0:
+		return p.Item.File.Created.Time()
+	}
+	return p.PinnedAt
+}
+
+// NewPin is the pin of item in a channel, nil when it has no PinItemID.
+func NewPin(channelID string, item *slack.Item, pinnedBy string, at time.Time) *Pin {
+	id := PinItemID(item)
+	if id == "" {
+		return nil
+	}
+	return &Pin{ChannelID: channelID, ItemID: id, Type: item.Type, Item: item, PinnedBy: pinnedBy, PinnedAt: at}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Purge records the messages of a channel the retention purge deleted. It
+// outlives the channel, so channel_id isn't a foreign key.
+type Purge struct {
+	tableName struct{} `sql:"purges"`
+
+	ID        int64  `json:"id"`
+	TeamID    string `sql:",notnull" json:"team_id"`
+	ChannelID string `sql:",notnull" json:"channel_id"`
+	// Policy is the retention period as configured, Before the time the
+	// deleted messages were posted before
+	Policy   string    `sql:",notnull" json:"policy"`
+	Before   time.Time `sql:",notnull" json:"before"`
+	Messages int       `sql:",notnull" json:"messages"`
+	Pins     int       `sql:",notnull" json:"pins"`
+	// Bookmarks are the ones created before Before
+	Bookmarks int `sql:",notnull" json:"bookmarks"`
+	// Oldest and Newest are when the first and last deleted messages were
+	// posted
+	Oldest     *time.Time `json:"oldest,omitempty"`
+	Newest     *time.Time `json:"newest,omitempty"`
+	StartedAt  time.Time  `sql:",notnull" json:"started_at"`
+	FinishedAt time.Time  `sql:",notnull" json:"finished_at"`
+}
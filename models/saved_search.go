@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// SavedSearch is a search a user keeps coming back to. Messages archived
+// after LastSeenAt are new matches, and with Notify set the bot sends the
+// user a digest of the ones archived since LastNotifiedAt. It goes by when
+// messages were archived rather than posted so those a sync catches up on
+// late count too.
+type SavedSearch struct {
+	ID     int64  `json:"id"`
+	UserID string `sql:",notnull" json:"user_id"`
+	TeamID string `sql:",notnull" json:"team_id"`
+	Name   string `sql:",notnull" json:"name"`
+	Query  string `sql:",notnull" json:"query"`
+	// Mode is the storage.SearchMode, empty for full text search
+	Mode      string `sql:",notnull" json:"mode"`
+	ChannelID string `json:"channel_id,omitempty"`
+	Notify    bool   `sql:",notnull" json:"notify"`
+
+	CreatedAt      time.Time  `sql:",notnull" json:"created_at"`
+	LastSeenAt     time.Time  `sql:",notnull" json:"last_seen_at"`
+	LastNotifiedAt *time.Time `json:"last_notified_at,omitempty"`
+
+	// NewMatches counts the matches archived after LastSeenAt
+	NewMatches int `sql:"-" json:"new_matches"`
+}
@@ -6,6 +6,10 @@ type Team struct {
 	Domain string `bson:"domain"`
 	Token  string `bson:"token"`
 
+	// Set for workspaces that are part of an Enterprise Grid org
+	EnterpriseID   string `bson:"enterprise_id"`
+	EnterpriseName string `bson:"enterprise_name"`
+
 	Plan string                 `bson:"plan"`
 	Icon map[string]interface{} `bson:"icon"`
 }
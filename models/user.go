@@ -13,6 +13,7 @@ type UserProfile struct {
 	LastName           string `json:"last_name,omitempty"`
 	RealName           string `json:"real_name,omitempty"`
 	RealNameNormalized string `json:"real_name_normalized,omitempty"`
+	DisplayName        string `json:"display_name,omitempty"`
 	Email              string `json:"email,omitempty"`
 	Skype              string `json:"skype,omitempty"`
 	Phone              string `json:"phone,omitempty"`
@@ -31,6 +32,7 @@ type User struct {
 	Name              string      `json:"name" sql:",notnull"`
 	Team              *Team       `json:"-"`
 	TeamID            string      `json:"team,omitempty" sql:",notnull"`
+	EnterpriseID      string      `json:"enterprise_id,omitempty"`
 	Deleted           bool        `json:"deleted" sql:",notnull"`
 	Color             string      `json:"color,omitempty"`
 	Profile           UserProfile `json:"profile"`
@@ -42,6 +44,12 @@ type User struct {
 	IsUltraRestricted bool        `json:"is_ultra_restricted,omitempty"`
 	HasFiles          bool        `json:"has_files,omitempty"`
 	Presence          string      `json:"presence,omitempty"`
+
+	// Slack Connect users from another organisation. TeamID is the team we
+	// archived them through, HomeTeamID the team they belong to.
+	IsExternal   bool   `json:"is_external,omitempty" sql:",notnull"`
+	HomeTeamID   string `json:"home_team_id,omitempty"`
+	HomeTeamName string `json:"home_team_name,omitempty"`
 }
 
 func (u *User) AfterSelect(_ context.Context, _ pg.DB) error {
@@ -51,6 +59,24 @@ func (u *User) AfterSelect(_ context.Context, _ pg.DB) error {
 	return nil
 }
 
+// MergeEnterprise copies the Enterprise Grid org the user belongs to, which
+// utils.Merge can't map on its own.
+func (u *User) MergeEnterprise(user *slack.User) {
+	u.EnterpriseID = user.Enterprise.EnterpriseID
+}
+
+// MergeExternal flags u as a user from another organisation when they aren't
+// part of team, or of the Enterprise Grid org team belongs to.
+func (u *User) MergeExternal(user *slack.User, team *Team) {
+	sameTeam := user.TeamID == "" || user.TeamID == team.ID
+	sameOrg := team.EnterpriseID != "" && user.Enterprise.EnterpriseID == team.EnterpriseID
+
+	if user.IsStranger || !(sameTeam || sameOrg) {
+		u.IsExternal = true
+		u.HomeTeamID = user.TeamID
+	}
+}
+
 func (u *User) MergeBot(bot *slack.Bot) {
 	u.Name = bot.Name
 	u.Deleted = bot.Deleted
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+)
+
+// What a UserProfileHistory entry records
+const (
+	// ProfileFirstSeen is the first time we saw the user, or the first
+	// sync since history was kept
+	ProfileFirstSeen   = "first_seen"
+	ProfileChanged     = "changed"
+	ProfileDeactivated = "deactivated"
+	ProfileReactivated = "reactivated"
+)
+
+// UserProfileHistory is who a user was from ChangedAt until the next entry,
+// so messages can be shown with the name and picture they were posted
+// under.
+type UserProfileHistory struct {
+	tableName struct{} `sql:"user_profile_history"`
+
+	ID        int64       `json:"-"`
+	UserID    string      `sql:",notnull" json:"user_id"`
+	Change    string      `sql:",notnull" json:"change"`
+	Name      string      `sql:",notnull" json:"name"`
+	Deleted   bool        `sql:",notnull" json:"deleted"`
+	Profile   UserProfile `json:"profile"`
+	ChangedAt time.Time   `sql:",notnull" json:"changed_at"`
+}
+
+// NextProfileHistory is the entry recording user as of at, or nil when they
+// look the same as in last, their latest entry. last is nil for users
+// without any.
+func NextProfileHistory(user *User, last *UserProfileHistory, at time.Time) *UserProfileHistory {
+	entry := &UserProfileHistory{
+		UserID:    user.ID,
+		Name:      user.Name,
+		Deleted:   user.Deleted,
+		Profile:   user.Profile,
+		ChangedAt: at,
+	}
+
+	switch {
+	case last == nil:
+		entry.Change = ProfileFirstSeen
+	case user.Deleted && !last.Deleted:
+		entry.Change = ProfileDeactivated
+	case !user.Deleted && last.Deleted:
+		entry.Change = ProfileReactivated
+	case user.Name != last.Name || user.Profile != last.Profile:
+		entry.Change = ProfileChanged
+	default:
+		return nil
+	}
+	return entry
+}
+
+// Deactivation is a time a user was deactivated, until they were
+// reactivated, if they were.
+type Deactivation struct {
+	DeactivatedAt time.Time  `json:"deactivated_at"`
+	ReactivatedAt *time.Time `json:"reactivated_at,omitempty"`
+}
+
+// Deactivations lists the deactivations in a user's history, oldest first.
+func Deactivations(history []UserProfileHistory) []Deactivation {
+	deactivations := []Deactivation{}
+	for _, entry := range history {
+		switch {
+		case entry.Change == ProfileDeactivated:
+			deactivations = append(deactivations, Deactivation{DeactivatedAt: entry.ChangedAt})
+		case entry.Change == ProfileReactivated && len(deactivations) > 0:
+			at := entry.ChangedAt
+			deactivations[len(deactivations)-1].ReactivatedAt = &at
+		}
+	}
+	return deactivations
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNextProfileHistory(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	profile := UserProfile{RealName: "Jane Doe", DisplayName: "jane"}
+	last := &UserProfileHistory{UserID: "U1", Change: ProfileFirstSeen, Name: "jane", Profile: profile}
+	deactivated := &UserProfileHistory{UserID: "U1", Change: ProfileDeactivated, Name: "jane", Deleted: true, Profile: profile}
+
+	tests := []struct {
+		name   string
+		user   User
+		last   *UserProfileHistory
+		change string
+	}{
+		{"first seen", User{ID: "U1", Name: "jane", Profile: profile}, nil, ProfileFirstSeen},
+		{"first seen deactivated", User{ID: "U1", Name: "jane", Deleted: true}, nil, ProfileFirstSeen},
+		{"unchanged", User{ID: "U1", Name: "jane", Profile: profile}, last, ""},
+		{"renamed", User{ID: "U1", Name: "janed", Profile: profile}, last, ProfileChanged},
+		{"new display name", User{ID: "U1", Name: "jane", Profile: UserProfile{RealName: "Jane Doe", DisplayName: "JD"}}, last, ProfileChanged},
+		{"deactivated", User{ID: "U1", Name: "jane", Deleted: true, Profile: profile}, last, ProfileDeactivated},
+		{"deactivated and renamed", User{ID: "U1", Name: "janed", Deleted: true}, last, ProfileDeactivated},
+		{"still deactivated", User{ID: "U1", Name: "jane", Deleted: true, Profile: profile}, deactivated, ""},
+		{"reactivated", User{ID: "U1", Name: "jane", Profile: profile}, deactivated, ProfileReactivated},
+	}
+	for _, tt := range tests {
+		entry := NextProfileHistory(&tt.user, tt.last, at)
+		if tt.change == "" {
+			if entry != nil {
+				t.Errorf("%s: got %+v, want nil", tt.name, entry)
+			}
+			continue
+		}
+
+		want := &UserProfileHistory{
+			UserID:    tt.user.ID,
+			Change:    tt.change,
+			Name:      tt.user.Name,
+			Deleted:   tt.user.Deleted,
+			Profile:   tt.user.Profile,
+			ChangedAt: at,
+		}
+		if !reflect.DeepEqual(entry, want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, entry, want)
+		}
+	}
+}
+
+func TestDeactivations(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	dayPtr := func(d int) *time.Time { t := day(d); return &t }
+	history := func(changes ...string) []UserProfileHistory {
+		var list []UserProfileHistory
+		for i, c := range changes {
+			list = append(list, UserProfileHistory{Change: c, ChangedAt: day(i + 1)})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name    string
+		history []UserProfileHistory
+		want    []Deactivation
+	}{
+		{"none", nil, []Deactivation{}},
+		{"never deactivated", history(ProfileFirstSeen, ProfileChanged), []Deactivation{}},
+		{"deactivated", history(ProfileFirstSeen, ProfileDeactivated), []Deactivation{{DeactivatedAt: day(2)}}},
+		{"reactivated", history(ProfileFirstSeen, ProfileDeactivated, ProfileChanged, ProfileReactivated),
+			[]Deactivation{{DeactivatedAt: day(2), ReactivatedAt: dayPtr(4)}}},
+		{"twice", history(ProfileFirstSeen, ProfileDeactivated, ProfileReactivated, ProfileDeactivated),
+			[]Deactivation{{DeactivatedAt: day(2), ReactivatedAt: dayPtr(3)}, {DeactivatedAt: day(4)}}},
+		// First seen deactivated, we don't know since when
+		{"reactivated only", history(ProfileFirstSeen, ProfileReactivated), []Deactivation{}},
+	}
+	for _, tt := range tests {
+		if got := Deactivations(tt.history); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Deactivations() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+// Package redact replaces secrets and personal data in messages, such as
+// pasted API keys, card numbers and email addresses, with placeholders like
+// [redacted email].
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/config"
+	"github.com/ashb/slackarchive/models"
+)
+
+// detector finds one kind of data. When re has a group only the group is
+// replaced, and valid, if set, weeds out false positives.
+type detector struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+var builtins = map[string][]detector{
+	"api_keys": {
+		{name: "api key", re: regexp.MustCompile(`\bxox[abposre]-[0-9A-Za-z-]{10,}`)},
+		{name: "api key", re: regexp.MustCompile(`\bxapp-[0-9A-Za-z-]{10,}`)},
+		{name: "api key", re: regexp.MustCompile(`\bgh[pousr]_[0-9A-Za-z]{36,}`)},
+		{name: "api key", re: regexp.MustCompile(`\bgithub_pat_[0-9A-Za-z_]{22,}`)},
+		{name: "api key", re: regexp.MustCompile(`\b[rs]k_(?:live|test)_[0-9A-Za-z]{16,}`)},
+		{name: "api key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+		{name: "api key", re: regexp.MustCompile(`\bsk-[0-9A-Za-z_-]{32,}`)},
+		{name: "api key", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	},
+	"aws_keys": {
+		{name: "aws key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		// Secret keys look like any other base64, only trust them next to
+		// a hint of what they are
+		{name: "aws key", re: regexp.MustCompile(`(?i)(?:aws|secret)[\w.-]{0,30}["']?\s*[:=]\s*["']?([0-9A-Za-z/+]{40})\b`)},
+	},
+	"credit_cards": {
+		{name: "card number", re: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: luhn},
+	},
+	"emails": {
+		{name: "email", re: regexp.MustCompile(`[0-9A-Za-z._%+-]+@[0-9A-Za-z.-]+\.[A-Za-z]{2,}`)},
+	},
+	"phone_numbers": {
+		{name: "phone number", re: regexp.MustCompile(`(?:\+|\b)\d[\d ().-]{7,}\d\b`), valid: phoneNumber},
+	},
+}
+
+// DefaultDetectors are the built-in detectors used unless the config picks
+// others. Phone numbers are too easily confused with other numbers to be on
+// by default.
+var DefaultDetectors = []string{"api_keys", "aws_keys", "credit_cards", "emails"}
+
+// Redactor redacts messages with a set of detectors.
+type Redactor struct {
+	detectors []detector
+}
+
+// New creates the Redactor for conf, with its built-in detectors and
+// custom patterns. It returns nil when redaction is off, which redacts
+// nothing.
+func New(conf config.RedactionConfig) (*Redactor, error) {
+	if conf.Mode == "" {
+		return nil, nil
+	}
+
+	names := conf.Detectors
+	if len(names) == 0 {
+		names = DefaultDetectors
+	}
+
+	r := &Redactor{}
+	for _, name := range names {
+		d, ok := builtins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction detector %q (supported: %s)", name, strings.Join(Detectors(), ", "))
+		}
+		r.detectors = append(r.detectors, d...)
+	}
+	for _, p := range conf.Patterns {
+		re, err := regexp.Compile(p.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %s: %s", p.Name, err)
+		}
+		r.detectors = append(r.detectors, detector{name: p.Name, re: re})
+	}
+	return r, nil
+}
+
+// Detectors lists the names of the built-in detectors.
+func Detectors() []string {
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Search results come with what matched wrapped in these. A match can be
+// part of a secret, so they're set aside while looking for secrets and put
+// back around the placeholders.
+var highlightMarker = regexp.MustCompile(`\[/?hl\]`)
+
+const (
+	highlightStart = "[hl]"
+	highlightEnd   = "[/hl]"
+)
+
+// mark is a highlight marker taken out of a string, at pos in what's left.
+type mark struct {
+	pos int
+	tag string
+}
+
+// redaction is a match to replace with placeholder.
+type redaction struct {
+	start, end  int
+	placeholder string
+}
+
+// String returns s with everything the detectors find replaced, and how
+// many matches there were.
+func (r *Redactor) String(s string) (string, int) {
+	if r == nil {
+		return s, 0
+	}
+
+	text, marks := unhighlight(s)
+	var found []redaction
+	for _, d := range r.detectors {
+		for _, m := range d.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if start == end || d.valid != nil && !d.valid(text[start:end]) {
+				continue
+			}
+			// Only replace the group, keeping what told us it's a secret
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if !overlaps(found, start, end) {
+				found = append(found, redaction{start, end, "[redacted " + d.name + "]"})
+			}
+		}
+	}
+	if len(found) == 0 {
+		return s, 0
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].start < found[j].start })
+
+	var b strings.Builder
+	pos, next, open := 0, 0, false
+	// flush writes the text and markers up to end
+	flush := func(end int) {
+		for ; next < len(marks) && marks[next].pos <= end; next++ {
+			b.WriteString(text[pos:marks[next].pos])
+			b.WriteString(marks[next].tag)
+			pos, open = marks[next].pos, marks[next].tag == highlightStart
+		}
+		b.WriteString(text[pos:end])
+		pos = end
+	}
+	for _, f := range found {
+		flush(f.start)
+		// A placeholder with any of the secret highlighted is highlighted
+		// as a whole
+		openBefore, highlighted := open, false
+		for ; next < len(marks) && marks[next].pos < f.end; next++ {
+			open, highlighted = marks[next].tag == highlightStart, true
+		}
+		if highlighted && !openBefore {
+			b.WriteString(highlightStart)
+		}
+		b.WriteString(f.placeholder)
+		if highlighted && !open {
+			b.WriteString(highlightEnd)
+		}
+		pos = f.end
+	}
+	flush(len(text))
+	return b.String(), len(found)
+}
+
+// unhighlight takes the highlight markers out of s.
+func unhighlight(s string) (string, []mark) {
+	locs := highlightMarker.FindAllStringIndex(s, -1)
+	if len(locs) == 0 {
+		return s, nil
+	}
+
+	var b strings.Builder
+	marks := make([]mark, 0, len(locs))
+	last := 0
+	for _, loc := range locs {
+		b.WriteString(s[last:loc[0]])
+		marks = append(marks, mark{pos: b.Len(), tag: s[loc[0]:loc[1]]})
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), marks
+}
+
+// overlaps tells whether start to end overlaps something already found.
+// The detectors are in order of preference, so the first to find
+// something wins.
+func overlaps(found []redaction, start, end int) bool {
+	for _, f := range found {
+		if start < f.end && f.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// skipKeys are fields of messages that hold IDs, timestamps and the like,
+// never anything people wrote.
+var skipKeys = map[string]bool{
+	"type": true, "subtype": true, "ts": true, "thread_ts": true, "event_ts": true, "latest_reply": true,
+	"user": true, "users": true, "reply_users": true, "bot_id": true, "app_id": true, "team": true,
+	"user_team": true, "source_team": true, "channel": true, "id": true, "block_id": true,
+	"action_id": true, "client_msg_id": true, "parent_user_id": true, "style": true, "mimetype": true,
+	"filetype": true, "pretty_type": true, "mode": true,
+}
+
+// Message redacts everything people wrote in msg: its text, attachments,
+// Block Kit blocks, files and so on. It returns how many matches were
+// replaced.
+func (r *Redactor) Message(msg *slack.Msg) (int, error) {
+	if msg == nil {
+		return 0, nil
+	}
+	return r.Value(msg)
+}
+
+// Item redacts a pinned message or file.
+func (r *Redactor) Item(item *slack.Item) (int, error) {
+	if item == nil {
+		return 0, nil
+	}
+	return r.Value(item)
+}
+
+// Bookmark redacts a bookmark's title and link.
+func (r *Redactor) Bookmark(b *models.Bookmark) int {
+	if b == nil {
+		return 0
+	}
+	var title, link int
+	b.Title, title = r.String(b.Title)
+	b.Link, link = r.String(b.Link)
+	return title + link
+}
+
+// Value redacts the strings of what v, a pointer to something that
+// marshals to JSON, points to.
+func (r *Redactor) Value(v interface{}) (int, error) {
+	if r == nil {
+		return 0, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+
+	// Numbers must survive the round trip as they were
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var fields interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return 0, err
+	}
+
+	fields, n := r.walk(fields)
+	if n == 0 {
+		return 0, nil
+	}
+
+	if b, err = json.Marshal(fields); err != nil {
+		return 0, err
+	}
+	redacted := reflect.New(reflect.TypeOf(v).Elem())
+	if err := json.Unmarshal(b, redacted.Interface()); err != nil {
+		return 0, err
+	}
+	reflect.ValueOf(v).Elem().Set(redacted.Elem())
+	return n, nil
+}
+
+func (r *Redactor) walk(v interface{}) (interface{}, int) {
+	switch v := v.(type) {
+	case string:
+		return r.String(v)
+	case map[string]interface{}:
+		total := 0
+		for k, val := range v {
+			if skipKeys[k] {
+				continue
+			}
+			var n int
+			v[k], n = r.walk(val)
+			total += n
+		}
+		return v, total
+	case []interface{}:
+		total := 0
+		for i, val := range v {
+			var n int
+			v[i], n = r.walk(val)
+			total += n
+		}
+		return v, total
+	}
+	return v, 0
+}
+
+// luhn tells whether the digits of s pass the Luhn check card numbers do.
+func luhn(s string) bool {
+	sum, digits, double := 0, 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
+
+// phoneNumber tells whether s has as many digits as phone numbers do.
+func phoneNumber(s string) bool {
+	digits := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	return digits >= 9 && digits <= 15
+}
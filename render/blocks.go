@@ -0,0 +1,322 @@
+package render
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// blocks renders a Block Kit layout. Inputs and the like only make sense
+// in Slack, so they're left out.
+func (r *Renderer) blocks(blocks slack.Blocks) []node {
+	var nodes []node
+	for _, b := range blocks.BlockSet {
+		switch b := b.(type) {
+		case *slack.SectionBlock:
+			var children []node
+			if b.Text != nil {
+				children = append(children, r.textObject(b.Text)...)
+			}
+			if len(b.Fields) > 0 {
+				var fields []node
+				for _, f := range b.Fields {
+					fields = append(fields, node{kind: nodeDiv, class: "msg-block-field", children: r.textObject(f)})
+				}
+				children = append(children, node{kind: nodeDiv, class: "msg-block-fields", children: fields})
+			}
+			if a := b.Accessory; a != nil && a.ImageElement != nil {
+				children = append(children, image(a.ImageElement.ImageURL, a.ImageElement.AltText, "msg-block-accessory"))
+			} else if a != nil && a.ButtonElement != nil {
+				children = append(children, r.button(a.ButtonElement))
+			}
+			nodes = append(nodes, node{kind: nodeDiv, class: "msg-block-section", children: children})
+		case *slack.HeaderBlock:
+			if b.Text != nil {
+				nodes = append(nodes, node{kind: nodeHeader, class: "msg-block-header", children: r.textObject(b.Text)})
+			}
+		case *slack.DividerBlock:
+			nodes = append(nodes, node{kind: nodeRule})
+		case *slack.ImageBlock:
+			children := []node{image(b.ImageURL, b.AltText, "")}
+			if b.Title != nil {
+				children = append(children, node{kind: nodeDiv, class: "msg-block-image-title", children: r.textObject(b.Title)})
+			}
+			nodes = append(nodes, node{kind: nodeDiv, class: "msg-block-image", children: children})
+		case *slack.ContextBlock:
+			var children []node
+			for _, e := range b.ContextElements.Elements {
+				switch e := e.(type) {
+				case *slack.TextBlockObject:
+					children = append(children, r.textObject(e)...)
+				case *slack.ImageBlockElement:
+					children = append(children, image(e.ImageURL, e.AltText, "msg-block-context-image"))
+				}
+				children = append(children, node{kind: nodeText, text: " "})
+			}
+			nodes = append(nodes, node{kind: nodeDiv, class: "msg-block-context", children: children})
+		case *slack.ActionBlock:
+			if b.Elements == nil {
+				continue
+			}
+			var children []node
+			for _, e := range b.Elements.ElementSet {
+				if button, ok := e.(*slack.ButtonBlockElement); ok {
+					children = append(children, r.button(button), node{kind: nodeText, text: " "})
+				}
+			}
+			nodes = append(nodes, node{kind: nodeDiv, class: "msg-block-actions", children: children})
+		case *slack.RichTextBlock:
+			nodes = append(nodes, r.richText(b)...)
+		}
+	}
+	return nodes
+}
+
+func (r *Renderer) textObject(t *slack.TextBlockObject) []node {
+	if t.Type == slack.MarkdownType {
+		return r.mrkdwn(t.Text)
+	}
+	return r.emojify(t.Text)
+}
+
+// emojify is plain text with its :emoji: turned in to the real thing
+func (r *Renderer) emojify(s string) []node {
+	var (
+		nodes []node
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		if s[i] != ':' {
+			continue
+		}
+		if n, size := r.emojiAt(s[i:]); size > 0 {
+			if i > start {
+				nodes = append(nodes, node{kind: nodeText, text: s[start:i]})
+			}
+			nodes = append(nodes, n)
+			i += size - 1
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		nodes = append(nodes, node{kind: nodeText, text: s[start:]})
+	}
+	return nodes
+}
+
+func (r *Renderer) button(b *slack.ButtonBlockElement) node {
+	var label []node
+	if b.Text != nil {
+		label = r.textObject(b.Text)
+	}
+	if b.URL == "" {
+		return node{kind: nodeLink, class: "msg-block-button", children: label}
+	}
+	return link(b.URL, "msg-block-button", label)
+}
+
+func image(src string, alt string, class string) node {
+	if !SafeURL(src) {
+		return node{kind: nodeText, text: alt}
+	}
+	return node{kind: nodeImage, href: src, text: alt, class: class}
+}
+
+// richText renders rich_text blocks. The Slack library only understands
+// their sections, lists, quotes and preformatted text come to us raw.
+func (r *Renderer) richText(b *slack.RichTextBlock) []node {
+	var nodes []node
+	for _, e := range b.Elements {
+		switch e := e.(type) {
+		case *slack.RichTextSection:
+			nodes = append(nodes, node{kind: nodeDiv, class: "msg-rich-text", children: r.richTextSection(e.Elements, false)})
+		case *slack.RichTextUnknown:
+			switch e.Type {
+			case "rich_text_preformatted", "rich_text_quote":
+				var section slack.RichTextSection
+				if err := json.Unmarshal([]byte(e.Raw), &section); err != nil {
+					continue
+				}
+				if e.Type == "rich_text_quote" {
+					nodes = append(nodes, node{kind: nodeQuote, children: r.richTextSection(section.Elements, false)})
+				} else {
+					nodes = append(nodes, node{kind: nodePre, children: r.richTextSection(section.Elements, true)})
+				}
+			case "rich_text_list":
+				var list struct {
+					Style    string            `json:"style"`
+					Elements []json.RawMessage `json:"elements"`
+				}
+				if err := json.Unmarshal([]byte(e.Raw), &list); err != nil {
+					continue
+				}
+				var items []node
+				for _, raw := range list.Elements {
+					var section slack.RichTextSection
+					if err := json.Unmarshal(raw, &section); err == nil {
+						items = append(items, node{kind: nodeItem, children: r.richTextSection(section.Elements, false)})
+					}
+				}
+				nodes = append(nodes, node{kind: nodeList, class: list.Style, children: items})
+			}
+		}
+	}
+	return nodes
+}
+
+func (r *Renderer) richTextSection(elements []slack.RichTextSectionElement, pre bool) []node {
+	var nodes []node
+	for _, e := range elements {
+		var (
+			n     []node
+			style *slack.RichTextSectionTextStyle
+		)
+		switch e := e.(type) {
+		case *slack.RichTextSectionTextElement:
+			style = e.Style
+			for i, line := range strings.Split(e.Text, "\n") {
+				if i > 0 {
+					if pre {
+						n = append(n, node{kind: nodeText, text: "\n"})
+					} else {
+						n = append(n, node{kind: nodeBreak})
+					}
+				}
+				if line != "" {
+					n = append(n, node{kind: nodeText, text: line})
+				}
+			}
+		case *slack.RichTextSectionUserElement:
+			n, style = []node{r.userMention(e.UserID, "")}, e.Style
+		case *slack.RichTextSectionChannelElement:
+			n, style = []node{r.channelMention(e.ChannelID, "")}, e.Style
+		case *slack.RichTextSectionUserGroupElement:
+			n = []node{{kind: nodeMention, class: "msg-mention-group", text: "@" + e.UsergroupID}}
+		case *slack.RichTextSectionBroadcastElement:
+			n = []node{{kind: nodeMention, class: "msg-mention-broadcast", text: "@" + e.Range}}
+		case *slack.RichTextSectionEmojiElement:
+			style = e.Style
+			if emoji, ok := r.emojiNode(e.Name); ok {
+				n = []node{emoji}
+			} else {
+				n = []node{{kind: nodeText, text: ":" + e.Name + ":"}}
+			}
+		case *slack.RichTextSectionLinkElement:
+			style = e.Style
+			var label []node
+			if e.Text != "" {
+				label = []node{{kind: nodeText, text: e.Text}}
+			}
+			n = []node{link(e.URL, "", label)}
+		case *slack.RichTextSectionDateElement:
+			if sec, err := strconv.ParseInt(e.Timestamp, 10, 64); err == nil {
+				n = []node{{kind: nodeText, text: time.Unix(sec, 0).UTC().Format("2006-01-02 15:04 MST")}}
+			}
+		case *slack.RichTextSectionColorElement:
+			n = []node{{kind: nodeText, text: e.Value}}
+		}
+		nodes = append(nodes, styled(n, style)...)
+	}
+	return nodes
+}
+
+func styled(nodes []node, style *slack.RichTextSectionTextStyle) []node {
+	if style == nil || len(nodes) == 0 {
+		return nodes
+	}
+	if style.Code {
+		nodes = []node{{kind: nodeCode, children: nodes}}
+	}
+	if style.Strike {
+		nodes = []node{{kind: nodeStrike, children: nodes}}
+	}
+	if style.Italic {
+		nodes = []node{{kind: nodeItalic, children: nodes}}
+	}
+	if style.Bold {
+		nodes = []node{{kind: nodeBold, children: nodes}}
+	}
+	return nodes
+}
+
+// attachment renders a legacy attachment, with its pretext above the bar
+// in its color like Slack does.
+func (r *Renderer) attachment(a *slack.Attachment) []node {
+	var nodes, children []node
+	if a.Pretext != "" {
+		nodes = append(nodes, node{kind: nodeDiv, class: "msg-attachment-pretext", children: r.mrkdwn(a.Pretext)})
+	}
+
+	if a.AuthorName != "" {
+		author := []node{{kind: nodeText, text: a.AuthorName}}
+		if a.AuthorLink != "" {
+			author = []node{link(a.AuthorLink, "", author)}
+		}
+		children = append(children, node{kind: nodeDiv, class: "msg-attachment-author", children: author})
+	}
+	if a.Title != "" {
+		title := r.literal(a.Title)
+		if a.TitleLink != "" {
+			title = []node{link(a.TitleLink, "", title)}
+		}
+		children = append(children, node{kind: nodeDiv, class: "msg-attachment-title", children: title})
+	}
+	if a.Text != "" {
+		children = append(children, node{kind: nodeDiv, class: "msg-attachment-text", children: r.mrkdwn(a.Text)})
+	}
+	if len(a.Fields) > 0 {
+		var fields []node
+		for _, f := range a.Fields {
+			class := "msg-attachment-field"
+			if f.Short {
+				class += " msg-attachment-field-short"
+			}
+			fields = append(fields, node{kind: nodeDiv, class: class, children: []node{
+				{kind: nodeDiv, class: "msg-attachment-field-title", children: r.literal(f.Title)},
+				{kind: nodeDiv, class: "msg-attachment-field-value", children: r.mrkdwn(f.Value)},
+			}})
+		}
+		children = append(children, node{kind: nodeDiv, class: "msg-attachment-fields", children: fields})
+	}
+	if a.ImageURL != "" {
+		children = append(children, image(a.ImageURL, a.Fallback, "msg-attachment-image"))
+	} else if a.ThumbURL != "" {
+		children = append(children, image(a.ThumbURL, a.Fallback, "msg-attachment-thumb"))
+	}
+	children = append(children, r.blocks(a.Blocks)...)
+	if a.Footer != "" {
+		children = append(children, node{kind: nodeDiv, class: "msg-attachment-footer", children: r.mrkdwn(a.Footer)})
+	}
+
+	// The fallback is for clients that can't show any of the above
+	if len(children) == 0 && a.Fallback != "" {
+		children = r.mrkdwn(a.Fallback)
+	}
+	if len(children) == 0 {
+		return nodes
+	}
+	return append(nodes, node{kind: nodeDiv, class: "msg-attachment", color: attachmentColor(a.Color), children: children})
+}
+
+var hexColor = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// attachmentColor is the hex color of an attachment's bar, which can be
+// given by name.
+func attachmentColor(color string) string {
+	switch color {
+	case "good":
+		return "2eb886"
+	case "warning":
+		return "daa038"
+	case "danger":
+		return "a30200"
+	}
+	if m := hexColor.FindStringSubmatch(color); m != nil {
+		return m[1]
+	}
+	return ""
+}
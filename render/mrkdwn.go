@@ -0,0 +1,318 @@
+package render
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kyokomi/emoji/v2"
+)
+
+// Search wraps matches in these, see storage.MessageResult
+const (
+	hlStart = "[hl]"
+	hlEnd   = "[/hl]"
+)
+
+var (
+	unescaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+	// Highlights inside link targets would break them
+	unhighlighter = strings.NewReplacer(hlStart, "", hlEnd, "")
+
+	emphasis = map[byte]nodeKind{
+		'*': nodeBold,
+		'_': nodeItalic,
+		'~': nodeStrike,
+	}
+)
+
+// mrkdwn parses Slack's markup: ```preformatted``` blocks, > quoted lines
+// and, within lines, <links and mentions>, `code`, *bold*, _italic_,
+// ~strike~ and :emoji:. Slack escapes &, < and > in the text.
+func (r *Renderer) mrkdwn(s string) []node {
+	parts := strings.Split(s, "```")
+	if len(parts)%2 == 0 {
+		// The last fence isn't closed, so it's just text
+		parts[len(parts)-2] += "```" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	var nodes []node
+	for i, part := range parts {
+		if i%2 == 1 {
+			nodes = append(nodes, node{kind: nodePre, children: r.literal(strings.Trim(part, "\n"))})
+			continue
+		}
+		if i > 0 {
+			part = strings.TrimPrefix(part, "\n")
+		}
+		if i < len(parts)-1 {
+			part = strings.TrimSuffix(part, "\n")
+		}
+		if part != "" {
+			nodes = append(nodes, r.lines(part)...)
+		}
+	}
+	return nodes
+}
+
+// lines parses text outside preformatted blocks, gathering quoted lines
+func (r *Renderer) lines(s string) []node {
+	var (
+		nodes []node
+		quote *node
+	)
+	for i, line := range strings.Split(s, "\n") {
+		if q, ok := quoted(line); ok {
+			if quote == nil {
+				nodes = append(nodes, node{kind: nodeQuote})
+				quote = &nodes[len(nodes)-1]
+			} else {
+				quote.children = append(quote.children, node{kind: nodeBreak})
+			}
+			quote.children = append(quote.children, r.inline(q)...)
+			continue
+		}
+
+		// A quote ends its line already
+		if i > 0 && quote == nil {
+			nodes = append(nodes, node{kind: nodeBreak})
+		}
+		quote = nil
+		nodes = append(nodes, r.inline(line)...)
+	}
+	return nodes
+}
+
+func quoted(line string) (string, bool) {
+	for _, prefix := range []string{"&gt;", ">"} {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line[len(prefix):], " "), true
+		}
+	}
+	return "", false
+}
+
+// inline parses a line of mrkdwn.
+func (r *Renderer) inline(s string) []node {
+	var (
+		nodes []node
+		text  strings.Builder
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, node{kind: nodeText, text: unescaper.Replace(text.String())})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '<':
+			if end := strings.IndexByte(s[i+1:], '>'); end >= 0 {
+				flush()
+				nodes = append(nodes, r.entity(s[i+1:i+1+end]))
+				i += end + 2
+				continue
+			}
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				flush()
+				nodes = append(nodes, node{kind: nodeCode, children: r.literal(s[i+1 : i+1+end])})
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(s[i:], hlStart):
+			if end := strings.Index(s[i:], hlEnd); end >= 0 {
+				flush()
+				nodes = append(nodes, node{kind: nodeHighlight, children: r.inline(s[i+len(hlStart) : i+end])})
+				i += end + len(hlEnd)
+				continue
+			}
+		case c == ':':
+			if n, size := r.emojiAt(s[i:]); size > 0 {
+				flush()
+				nodes = append(nodes, n)
+				i += size
+				continue
+			}
+		case emphasis[c] != 0:
+			if end := closing(s, i); end > 0 {
+				flush()
+				nodes = append(nodes, node{kind: emphasis[c], children: r.inline(s[i+1 : end])})
+				i = end + 1
+				continue
+			}
+		}
+		text.WriteByte(s[i])
+		i++
+	}
+	flush()
+	return nodes
+}
+
+// closing finds the marker that closes the one at s[i]. Like Slack, markers
+// only count at the edges of words: *bold* but not snake_case_names.
+func closing(s string, i int) int {
+	m := s[i]
+	if prev, _ := utf8.DecodeLastRuneInString(s[:i]); i > 0 && (isWord(prev) || prev == rune(m)) {
+		return -1
+	}
+	if next, _ := utf8.DecodeRuneInString(s[i+1:]); i+1 >= len(s) || unicode.IsSpace(next) || next == rune(m) {
+		return -1
+	}
+
+	for j := i + 2; j < len(s); j++ {
+		switch s[j] {
+		// Markers inside links and code don't count
+		case '<':
+			if end := strings.IndexByte(s[j+1:], '>'); end >= 0 {
+				j += end + 1
+			}
+		case '`':
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+			}
+		case m:
+			prev, _ := utf8.DecodeLastRuneInString(s[:j])
+			next, _ := utf8.DecodeRuneInString(s[j+1:])
+			if !unicode.IsSpace(prev) && (j+1 == len(s) || !isWord(next)) {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+func isWord(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// literal is text where only links, mentions and highlights mean anything,
+// such as code and attachment titles.
+func (r *Renderer) literal(s string) []node {
+	var (
+		nodes []node
+		text  strings.Builder
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, node{kind: nodeText, text: unescaper.Replace(text.String())})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] == '<' {
+			if end := strings.IndexByte(s[i+1:], '>'); end >= 0 {
+				flush()
+				nodes = append(nodes, r.entity(s[i+1:i+1+end]))
+				i += end + 2
+				continue
+			}
+		} else if strings.HasPrefix(s[i:], hlStart) {
+			if end := strings.Index(s[i:], hlEnd); end >= 0 {
+				flush()
+				nodes = append(nodes, node{kind: nodeHighlight, children: r.literal(s[i+len(hlStart) : i+end])})
+				i += end + len(hlEnd)
+				continue
+			}
+		}
+		text.WriteByte(s[i])
+		i++
+	}
+	flush()
+	return nodes
+}
+
+// entity renders the inside of <...>: a mention, a special command like
+// <!here> or a link, each with an optional |label.
+func (r *Renderer) entity(s string) node {
+	target, label := s, ""
+	if i := strings.IndexByte(s, '|'); i >= 0 {
+		target, label = s[:i], s[i+1:]
+	}
+	target = unhighlighter.Replace(target)
+
+	switch {
+	case strings.HasPrefix(target, "@"):
+		return r.userMention(target[1:], unhighlighter.Replace(unescaper.Replace(label)))
+	case strings.HasPrefix(target, "#"):
+		return r.channelMention(target[1:], unhighlighter.Replace(unescaper.Replace(label)))
+	case strings.HasPrefix(target, "!"):
+		cmd := target[1:]
+		switch {
+		case cmd == "here" || cmd == "channel" || cmd == "everyone":
+			return node{kind: nodeMention, class: "msg-mention-broadcast", text: "@" + cmd}
+		case strings.HasPrefix(cmd, "subteam^"):
+			if label == "" {
+				label = "@" + strings.TrimPrefix(cmd, "subteam^")
+			}
+			return node{kind: nodeMention, class: "msg-mention-group", text: unhighlighter.Replace(unescaper.Replace(label))}
+		}
+		// Dates and the like come with the text to show
+		if label == "" {
+			label = cmd
+		}
+		return node{kind: nodeText, text: unhighlighter.Replace(unescaper.Replace(label))}
+	}
+
+	var children []node
+	if label != "" {
+		children = r.literal(label)
+	}
+	return link(unescaper.Replace(target), "", children)
+}
+
+// emojiAt reads the :short_code: at the start of s, with an optional
+// :skin-tone-N: after it, and returns how long it was. Names we don't know
+// stay text.
+func (r *Renderer) emojiAt(s string) (node, int) {
+	end := strings.IndexByte(s[1:], ':')
+	if end < 1 || end > 64 {
+		return node{}, 0
+	}
+	name := s[1 : end+1]
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("_+-'", c)) {
+			return node{}, 0
+		}
+	}
+	n, ok := r.emojiNode(name)
+	if !ok {
+		return node{}, 0
+	}
+	size := end + 2
+
+	// Custom emoji don't come in skin tones
+	if rest := s[size:]; n.href == "" && strings.HasPrefix(rest, ":skin-tone-") && len(rest) >= 13 && rest[12] == ':' {
+		if tone := rest[11]; tone >= '2' && tone <= '6' {
+			n.text += string(rune(0x1F3FB + int(tone-'2')))
+			n.title += rest[:13]
+			size += 13
+		}
+	}
+	return n, size
+}
+
+// emojiNode is the emoji called name, a standard one or one of the team's
+// custom emoji, which are images.
+func (r *Renderer) emojiNode(name string) (node, bool) {
+	code, ok := emoji.CodeMap()[":"+name+":"]
+	if !ok {
+		url, aliasFor, custom := r.resolver.Emoji(name)
+		if !custom {
+			return node{}, false
+		}
+		if url != "" {
+			if !SafeURL(url) && !localPath(url) {
+				return node{kind: nodeEmoji, text: ":" + name + ":", title: ":" + name + ":"}, true
+			}
+			return node{kind: nodeEmoji, text: ":" + name + ":", href: url, title: ":" + name + ":"}, true
+		}
+		if code, ok = emoji.CodeMap()[":"+aliasFor+":"]; !ok {
+			return node{}, false
+		}
+	}
+	return node{kind: nodeEmoji, text: strings.TrimSpace(code), title: ":" + name + ":"}, true
+}
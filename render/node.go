@@ -0,0 +1,191 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type nodeKind int
+
+const (
+	nodeText nodeKind = iota
+	nodeBreak
+	nodeBold
+	nodeItalic
+	nodeStrike
+	nodeCode
+	nodePre
+	nodeQuote
+	nodeHighlight
+	nodeLink
+	nodeMention
+	nodeEmoji
+	nodeDiv
+	nodeHeader
+	nodeRule
+	nodeImage
+	nodeList
+	nodeItem
+)
+
+// node is a piece of a message, written out by writeHTML or text.
+type node struct {
+	kind nodeKind
+	// text of text, mention and emoji nodes, alt text of images
+	text string
+	// href of links, mentions, images and custom emoji
+	href string
+	// title of emoji, their short code
+	title string
+	class string
+	// color of the bar next to attachments
+	color    string
+	children []node
+}
+
+var tags = map[nodeKind]string{
+	nodeBold:   "b",
+	nodeItalic: "i",
+	nodeStrike: "s",
+	nodeCode:   "code",
+	nodePre:    "pre",
+	nodeQuote:  "blockquote",
+	nodeHeader: "h3",
+	nodeItem:   "li",
+}
+
+func writeHTML(b *strings.Builder, nodes []node) {
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeText:
+			b.WriteString(html.EscapeString(n.text))
+		case nodeBreak:
+			b.WriteString("<br>")
+		case nodeRule:
+			b.WriteString("<hr>")
+		case nodeHighlight:
+			b.WriteString(`<em class="hl">`)
+			writeHTML(b, n.children)
+			b.WriteString("</em>")
+		case nodeLink:
+			if n.href == "" {
+				b.WriteString(`<span` + classAttr(n.class) + `>`)
+				writeHTML(b, n.children)
+				b.WriteString("</span>")
+				continue
+			}
+			b.WriteString(`<a href="` + html.EscapeString(n.href) + `"` + classAttr(n.class) + ` target="_blank" rel="noopener noreferrer">`)
+			writeHTML(b, n.children)
+			b.WriteString("</a>")
+		case nodeMention:
+			class := classAttr("msg-mention " + n.class)
+			if n.href != "" {
+				b.WriteString(`<a href="` + html.EscapeString(n.href) + `"` + class + `>` + html.EscapeString(n.text) + `</a>`)
+			} else {
+				b.WriteString(`<span` + class + `>` + html.EscapeString(n.text) + `</span>`)
+			}
+		case nodeEmoji:
+			if n.href != "" {
+				b.WriteString(`<img class="emoji emoji-custom" src="` + html.EscapeString(n.href) + `" alt="` + html.EscapeString(n.text) + `" title="` + html.EscapeString(n.title) + `">`)
+				continue
+			}
+			b.WriteString(`<span class="emoji" title="` + html.EscapeString(n.title) + `">` + html.EscapeString(n.text) + `</span>`)
+		case nodeImage:
+			b.WriteString(`<img src="` + html.EscapeString(n.href) + `" alt="` + html.EscapeString(n.text) + `"` + classAttr(n.class) + `>`)
+		case nodeDiv:
+			b.WriteString(`<div` + classAttr(n.class))
+			if n.color != "" {
+				b.WriteString(` style="border-left-color: #` + n.color + `"`)
+			}
+			b.WriteString(`>`)
+			writeHTML(b, n.children)
+			b.WriteString("</div>")
+		case nodeList:
+			tag := "ul"
+			if n.class == "ordered" {
+				tag = "ol"
+			}
+			b.WriteString("<" + tag + ">")
+			writeHTML(b, n.children)
+			b.WriteString("</" + tag + ">")
+		default:
+			tag := tags[n.kind]
+			b.WriteString("<" + tag + classAttr(n.class) + ">")
+			writeHTML(b, n.children)
+			b.WriteString("</" + tag + ">")
+		}
+	}
+}
+
+func classAttr(class string) string {
+	if class == "" {
+		return ""
+	}
+	return ` class="` + html.EscapeString(class) + `"`
+}
+
+var (
+	trailingSpace = regexp.MustCompile(`(?m)[ \t]+$`)
+	blankLines    = regexp.MustCompile(`\n{3,}`)
+)
+
+func text(nodes []node) string {
+	var b strings.Builder
+	writeText(&b, nodes)
+	s := trailingSpace.ReplaceAllString(b.String(), "")
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
+
+// newline starts a new line, unless we're at the start of one
+func newline(b *strings.Builder) {
+	if s := b.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		b.WriteString("\n")
+	}
+}
+
+func writeText(b *strings.Builder, nodes []node) {
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeText, nodeMention, nodeEmoji:
+			b.WriteString(n.text)
+		case nodeBreak:
+			b.WriteString("\n")
+		case nodeImage:
+			if n.text != "" {
+				newline(b)
+				b.WriteString("[" + n.text + "]\n")
+			}
+		case nodeLink:
+			label := text(n.children)
+			b.WriteString(label)
+			if n.href != "" && n.href != label && strings.TrimPrefix(n.href, "mailto:") != label {
+				b.WriteString(" (" + n.href + ")")
+			}
+		case nodeQuote:
+			newline(b)
+			for _, line := range strings.Split(text(n.children), "\n") {
+				b.WriteString("> " + line + "\n")
+			}
+		case nodePre, nodeDiv, nodeHeader:
+			newline(b)
+			writeText(b, n.children)
+			newline(b)
+		case nodeRule:
+			newline(b)
+			b.WriteString("\n")
+		case nodeList:
+			newline(b)
+			for i, item := range n.children {
+				bullet := "• "
+				if n.class == "ordered" {
+					bullet = strconv.Itoa(i+1) + ". "
+				}
+				b.WriteString(bullet + text(item.children) + "\n")
+			}
+		default:
+			writeText(b, n.children)
+		}
+	}
+}
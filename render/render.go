@@ -0,0 +1,155 @@
+// Package render turns archived Slack messages into HTML and plain text:
+// the mrkdwn of their text, Block Kit layouts, legacy attachments and
+// files. Everything Slack sent is escaped, and only http, https and mailto
+// links are kept, so the HTML is safe to put in a page as it is.
+package render
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Resolver names the users and channels messages mention, and finds the
+// team's custom emoji.
+type Resolver interface {
+	UserName(id string) (string, bool)
+	ChannelName(id string) (string, bool)
+	// Emoji returns the image URL of the custom emoji called name or, for
+	// aliases of standard emoji, the name of that emoji.
+	Emoji(name string) (url string, aliasFor string, ok bool)
+}
+
+// Renderer renders messages, resolving mentions with its Resolver.
+type Renderer struct {
+	resolver Resolver
+}
+
+func New(resolver Resolver) *Renderer {
+	return &Renderer{resolver: resolver}
+}
+
+// HTML renders msg as HTML.
+func (r *Renderer) HTML(msg *slack.Msg) string {
+	var b strings.Builder
+	writeHTML(&b, r.message(msg))
+	return b.String()
+}
+
+// Text renders msg as plain text.
+func (r *Renderer) Text(msg *slack.Msg) string {
+	return text(r.message(msg))
+}
+
+// message is the body of msg followed by its attachments, files and
+// reactions. Slack shows blocks in place of the text, but the rich_text
+// blocks of messages people write say the same as the text, which is what
+// search highlights.
+func (r *Renderer) message(msg *slack.Msg) []node {
+	var nodes []node
+	if hasLayout(msg.Blocks) {
+		nodes = r.blocks(msg.Blocks)
+	} else {
+		nodes = r.mrkdwn(msg.Text)
+	}
+
+	for i := range msg.Attachments {
+		nodes = append(nodes, r.attachment(&msg.Attachments[i])...)
+	}
+
+	if len(msg.Files) > 0 {
+		var files []node
+		for _, f := range msg.Files {
+			name := f.Title
+			if name == "" {
+				name = f.Name
+			}
+			files = append(files, node{kind: nodeDiv, class: "msg-file", children: []node{link(f.Permalink, "", []node{{kind: nodeText, text: name}})}})
+		}
+		nodes = append(nodes, node{kind: nodeDiv, class: "msg-files", children: files})
+	}
+
+	if len(msg.Reactions) > 0 {
+		var reactions []node
+		for _, reaction := range msg.Reactions {
+			n, ok := r.emojiNode(reaction.Name)
+			if !ok {
+				n = node{kind: nodeText, text: ":" + reaction.Name + ":"}
+			}
+			reactions = append(reactions,
+				node{kind: nodeLink, class: "msg-reaction", children: []node{n, {kind: nodeText, text: " " + strconv.Itoa(reaction.Count)}}},
+				node{kind: nodeText, text: " "},
+			)
+		}
+		nodes = append(nodes, node{kind: nodeDiv, class: "msg-reactions", children: reactions})
+	}
+	return nodes
+}
+
+func hasLayout(blocks slack.Blocks) bool {
+	for _, b := range blocks.BlockSet {
+		if _, ok := b.(*slack.RichTextBlock); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Renderer) userMention(id string, label string) node {
+	name := strings.TrimPrefix(label, "@")
+	if n, ok := r.resolver.UserName(id); ok {
+		name = n
+	} else if name == "" {
+		name = id
+	}
+	return node{kind: nodeMention, class: "msg-mention-user", text: "@" + name}
+}
+
+// channelMention links to the channel in the archive, if we know it.
+func (r *Renderer) channelMention(id string, label string) node {
+	n, ok := r.resolver.ChannelName(id)
+	if !ok {
+		if label == "" {
+			label = id
+		}
+		return node{kind: nodeMention, class: "msg-mention-channel", text: "#" + label}
+	}
+	return node{kind: nodeMention, class: "msg-mention-channel", text: "#" + n, href: "/" + url.PathEscape(n)}
+}
+
+// link is a link to href, or just its label when href isn't a URL we let
+// through.
+func link(href string, class string, label []node) node {
+	if len(label) == 0 {
+		label = []node{{kind: nodeText, text: href}}
+	}
+	if !SafeURL(href) {
+		href = ""
+	}
+	return node{kind: nodeLink, href: href, class: class, children: label}
+}
+
+// localPath tells whether s is a path on the archive itself, like the
+// images of the custom emoji it keeps. Paths starting // or with a
+// backslash in are taken as other hosts by browsers.
+func localPath(s string) bool {
+	return strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") && !strings.ContainsRune(s, '\\')
+}
+
+// SafeURL tells whether s is a link we let through: http, https and mailto
+// ones, not javascript: and the like.
+func SafeURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+type resolver struct{}
+
+func (resolver) UserName(id string) (string, bool) {
+	if id == "U1" {
+		return `<b>bob</b>`, true
+	}
+	return "", false
+}
+
+func (resolver) ChannelName(id string) (string, bool) {
+	if id == "C1" {
+		return `general"><script>`, true
+	}
+	return "", false
+}
+
+func (resolver) Emoji(name string) (string, string, bool) {
+	switch name {
+	case "evil":
+		return `https://example.com/e.png" onerror="alert(1)`, "", true
+	case "script":
+		return "javascript:alert(1)", "", true
+	case "other-host":
+		return `/\example.com/e.png`, "", true
+	case "archived":
+		return "/v1/emoji/archived", "", true
+	}
+	return "", "", false
+}
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  slack.Msg
+		want string
+	}{
+		{
+			name: "escaped text",
+			msg:  slack.Msg{Text: "&lt;script&gt;alert(1)&lt;/script&gt; &amp; more"},
+			want: "&lt;script&gt;alert(1)&lt;/script&gt; &amp; more",
+		},
+		{
+			name: "link",
+			msg:  slack.Msg{Text: "<https://example.com/?a=1&amp;b=2|example>"},
+			want: `<a href="https://example.com/?a=1&amp;b=2" target="_blank" rel="noopener noreferrer">example</a>`,
+		},
+		{
+			name: "quote in link",
+			msg:  slack.Msg{Text: `<https://example.com/" onclick="alert(1)|x>`},
+			want: `<a href="https://example.com/&#34; onclick=&#34;alert(1)" target="_blank" rel="noopener noreferrer">x</a>`,
+		},
+		{
+			name: "javascript link",
+			msg:  slack.Msg{Text: "<javascript:alert(1)|click>"},
+			want: "<span>click</span>",
+		},
+		{
+			name: "data link",
+			msg:  slack.Msg{Text: "<data:text/html;base64,PHNjcmlwdD4=>"},
+			want: "<span>data:text/html;base64,PHNjcmlwdD4=</span>",
+		},
+		{
+			name: "user mention",
+			msg:  slack.Msg{Text: "<@U1>"},
+			want: `<span class="msg-mention msg-mention-user">@&lt;b&gt;bob&lt;/b&gt;</span>`,
+		},
+		{
+			name: "channel mention",
+			msg:  slack.Msg{Text: "<#C1>"},
+			want: `<a href="/general%22%3E%3Cscript%3E" class="msg-mention msg-mention-channel">#general&#34;&gt;&lt;script&gt;</a>`,
+		},
+		{
+			name: "custom emoji",
+			msg:  slack.Msg{Text: ":evil:"},
+			want: `<img class="emoji emoji-custom" src="https://example.com/e.png&#34; onerror=&#34;alert(1)" alt=":evil:" title=":evil:">`,
+		},
+		{
+			name: "custom emoji with a script",
+			msg:  slack.Msg{Text: ":script:"},
+			want: `<span class="emoji" title=":script:">:script:</span>`,
+		},
+		{
+			name: "custom emoji on another host",
+			msg:  slack.Msg{Text: ":other-host:"},
+			want: `<span class="emoji" title=":other-host:">:other-host:</span>`,
+		},
+		{
+			name: "archived custom emoji",
+			msg:  slack.Msg{Text: ":archived:"},
+			want: `<img class="emoji emoji-custom" src="/v1/emoji/archived" alt=":archived:" title=":archived:">`,
+		},
+		{
+			name: "highlight",
+			msg:  slack.Msg{Text: "[hl]&lt;b&gt;[/hl]"},
+			want: `<em class="hl">&lt;b&gt;</em>`,
+		},
+		{
+			name: "attachment",
+			msg: slack.Msg{Attachments: []slack.Attachment{{
+				Title:     `"&gt;&lt;img src=x onerror=alert(1)&gt;`,
+				TitleLink: "javascript:alert(1)",
+				Color:     `red;background:url(x)`,
+			}}},
+			want: `<div class="msg-attachment"><div class="msg-attachment-title"><span>&#34;&gt;&lt;img src=x onerror=alert(1)&gt;</span></div></div>`,
+		},
+		{
+			name: "file",
+			msg:  slack.Msg{Files: []slack.File{{Name: "<x>.txt", Permalink: "vbscript:x"}}},
+			want: `<div class="msg-files"><div class="msg-file"><span>&lt;x&gt;.txt</span></div></div>`,
+		},
+	}
+
+	r := New(resolver{})
+	for _, tt := range tests {
+		if got := r.HTML(&tt.msg); got != tt.want {
+			t.Errorf("%s: HTML() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	r := New(resolver{})
+	msg := &slack.Msg{Text: "*hi* &lt;there&gt; <@U1> <https://example.com|site>"}
+	if got, want := r.Text(msg), "hi <there> @<b>bob</b> site (https://example.com)"; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		safe bool
+	}{
+		{"https://example.com/", true},
+		{"http://example.com/path?q=1", true},
+		{"HTTPS://EXAMPLE.COM", true},
+		{"mailto:someone@example.com", true},
+		{"https:///path", false},
+		{"javascript:alert(1)", false},
+		{"JavaScript:alert(1)", false},
+		{" javascript:alert(1)", false},
+		{"data:text/html,<script>", false},
+		{"vbscript:msgbox", false},
+		{"//example.com", false},
+		{"/relative", false},
+		{"", false},
+		{"%", false},
+	}
+	for _, tt := range tests {
+		if got := SafeURL(tt.url); got != tt.safe {
+			t.Errorf("SafeURL(%q) = %v, want %v", tt.url, got, tt.safe)
+		}
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		local bool
+	}{
+		{"/v1/emoji/party", true},
+		{"/", true},
+		{"//example.com/e.png", false},
+		{`/\example.com/e.png`, false},
+		{"https://example.com/e.png", false},
+		{"v1/emoji/party", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := localPath(tt.path); got != tt.local {
+			t.Errorf("localPath(%q) = %v, want %v", tt.path, got, tt.local)
+		}
+	}
+}
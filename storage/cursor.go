@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+)
+
+var (
+	// ErrInvalidCursor is returned for cursor tokens Cursor.String didn't
+	// make.
+	ErrInvalidCursor = errors.New("storage: invalid cursor")
+	// ErrCursorRelevance is returned when paging by cursor through results
+	// sorted by relevance, which aren't in the order of a Cursor.
+	ErrCursorRelevance = errors.New("storage: results sorted by relevance can't be paged by cursor")
+)
+
+// Cursor is the position of a message in a list ordered by time. Slack
+// timestamps are unique within a channel, so with the channel they tell
+// apart any two messages, even in shared channels.
+type Cursor struct {
+	Timestamp time.Time
+	ChannelID string
+}
+
+// CursorOf is the position of m.
+func CursorOf(m *models.Message) *Cursor {
+	return &Cursor{Timestamp: *m.Timestamp, ChannelID: m.ChannelID}
+}
+
+// String is the opaque token API clients send back for the next page.
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(models.TimeToTimestamp(c.Timestamp) + ":" + c.ChannelID))
+}
+
+// ParseCursor reads a token made by Cursor.String.
+func ParseCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 || parts[1] == "" || !strings.Contains(parts[0], ".") {
+		return nil, ErrInvalidCursor
+	}
+	t, err := models.TimestampToTime(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Timestamp: *t, ChannelID: parts[1]}, nil
+}
+
+// Keyset is how a backend selects a page of a MessageQuery: the messages
+// whose ("timestamp", channel_id) compares with Op to Cursor's, if there's
+// a Cursor, ordered newest first when Descending. Backends select one more
+// message than the query's Limit so Page can tell if there are more.
+type Keyset struct {
+	Cursor     *Cursor
+	Op         string
+	Descending bool
+	// Reverse is set when selecting the page before a cursor, which is done
+	// in the opposite of the query's order
+	Reverse bool
+}
+
+// Keyset works out the Keyset of the page query asks for.
+func (query MessageQuery) Keyset() (Keyset, error) {
+	k := Keyset{Descending: !query.Ascending}
+	if query.After == nil && query.Before == nil {
+		return k, nil
+	}
+	if query.After != nil && query.Before != nil {
+		return k, errors.New("storage: only one of After and Before can be set")
+	}
+	if query.Relevance && query.Query != "" {
+		return k, ErrCursorRelevance
+	}
+
+	k.Cursor, k.Op = query.After, ">"
+	if query.Before != nil {
+		k.Cursor, k.Reverse = query.Before, true
+		k.Descending = !k.Descending
+	}
+	if k.Descending {
+		k.Op = "<"
+	}
+	return k, nil
+}
+
+// Page trims the messages a backend selected for k to the query's Limit,
+// puts them back in the query's order and sets the cursors of the pages
+// either side. Results sorted by relevance don't get cursors.
+func (query MessageQuery) Page(result *MessageResult, k Keyset) {
+	more := query.Limit > 0 && len(result.Messages) > query.Limit
+	if more {
+		result.Messages = result.Messages[:query.Limit]
+	}
+	if k.Reverse {
+		for i, j := 0, len(result.Messages)-1; i < j; i, j = i+1, j-1 {
+			result.Messages[i], result.Messages[j] = result.Messages[j], result.Messages[i]
+		}
+	}
+
+	if len(result.Messages) == 0 || (query.Relevance && query.Query != "") {
+		return
+	}
+	first, last := CursorOf(&result.Messages[0]), CursorOf(&result.Messages[len(result.Messages)-1])
+
+	switch {
+	case k.Reverse:
+		// We came back from the page after this one
+		result.Next = last
+		if more {
+			result.Prev = first
+		}
+	case k.Cursor != nil:
+		result.Prev = first
+		if more {
+			result.Next = last
+		}
+	default:
+		if query.Offset > 0 {
+			result.Prev = first
+		}
+		if more {
+			result.Next = last
+		}
+	}
+}
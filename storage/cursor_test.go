@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{Timestamp: time.Unix(1500000000, 123456000), ChannelID: "C1"},
+		{Timestamp: time.Unix(1500000000, 0), ChannelID: "C0123ABCD"},
+		// Channel IDs never have colons, but only the first one splits
+		{Timestamp: time.Unix(1, 1000), ChannelID: "C:1"},
+	}
+	for _, c := range tests {
+		got, err := ParseCursor(c.String())
+		if err != nil {
+			t.Errorf("ParseCursor(%q): %s", c.String(), err)
+			continue
+		}
+		if !got.Timestamp.Equal(c.Timestamp) || got.ChannelID != c.ChannelID {
+			t.Errorf("ParseCursor(%q) = %+v, want %+v", c.String(), *got, c)
+		}
+	}
+}
+
+func TestParseCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []string{
+		"",
+		"not base64!",
+		encode("1500000000.123456C1"),
+		encode("1500000000.123456:"),
+		encode("1500000000:C1"),
+		encode("abc.def:C1"),
+		encode(":C1"),
+		base64.StdEncoding.EncodeToString([]byte("1500000000.123456:C1")) + "=",
+	}
+	for _, token := range tests {
+		if c, err := ParseCursor(token); err != ErrInvalidCursor {
+			t.Errorf("ParseCursor(%q) = %+v, %v, want ErrInvalidCursor", token, c, err)
+		}
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	c := &Cursor{Timestamp: time.Unix(1500000000, 0), ChannelID: "C1"}
+	tests := []struct {
+		name  string
+		query MessageQuery
+		want  Keyset
+		err   error
+	}{
+		{"first page", MessageQuery{}, Keyset{Descending: true}, nil},
+		{"first page ascending", MessageQuery{Ascending: true}, Keyset{}, nil},
+		{"after", MessageQuery{After: c}, Keyset{Cursor: c, Op: "<", Descending: true}, nil},
+		{"after ascending", MessageQuery{After: c, Ascending: true}, Keyset{Cursor: c, Op: ">"}, nil},
+		{"before", MessageQuery{Before: c}, Keyset{Cursor: c, Op: ">", Reverse: true}, nil},
+		{"before ascending", MessageQuery{Before: c, Ascending: true}, Keyset{Cursor: c, Op: "<", Descending: true, Reverse: true}, nil},
+		{"relevance", MessageQuery{After: c, Relevance: true, Query: "foo"}, Keyset{Descending: true}, ErrCursorRelevance},
+		{"relevance without query", MessageQuery{After: c, Relevance: true}, Keyset{Cursor: c, Op: "<", Descending: true}, nil},
+	}
+	for _, tt := range tests {
+		got, err := tt.query.Keyset()
+		if err != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Keyset() = %+v, %v, want %+v, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := (MessageQuery{After: c, Before: c}).Keyset(); err == nil {
+		t.Error("Keyset() with After and Before: no error")
+	}
+}
+
+func TestPage(t *testing.T) {
+	messages := func(secs ...int64) []models.Message {
+		var list []models.Message
+		for _, s := range secs {
+			ts := time.Unix(s, 0)
+			list = append(list, models.Message{ChannelID: "C1", Timestamp: &ts})
+		}
+		return list
+	}
+	cursor := func(s int64) *Cursor { return &Cursor{Timestamp: time.Unix(s, 0), ChannelID: "C1"} }
+	c := cursor(10)
+
+	tests := []struct {
+		name       string
+		query      MessageQuery
+		k          Keyset
+		selected   []models.Message
+		want       []int64
+		prev, next *Cursor
+	}{
+		{"first page", MessageQuery{Pager: Pager{Limit: 2}}, Keyset{Descending: true}, messages(9, 8, 7), []int64{9, 8}, nil, cursor(8)},
+		{"only page", MessageQuery{Pager: Pager{Limit: 2}}, Keyset{Descending: true}, messages(9, 8), []int64{9, 8}, nil, nil},
+		{"offset", MessageQuery{Pager: Pager{Limit: 2, Offset: 2}}, Keyset{Descending: true}, messages(7), []int64{7}, cursor(7), nil},
+		{"after", MessageQuery{Pager: Pager{Limit: 2}, After: c}, Keyset{Cursor: c, Op: "<", Descending: true}, messages(9, 8, 7), []int64{9, 8}, cursor(9), cursor(8)},
+		{"last page after", MessageQuery{Pager: Pager{Limit: 2}, After: c}, Keyset{Cursor: c, Op: "<", Descending: true}, messages(9), []int64{9}, cursor(9), nil},
+		{"before", MessageQuery{Pager: Pager{Limit: 2}, Before: c}, Keyset{Cursor: c, Op: ">", Reverse: true}, messages(11, 12, 13), []int64{12, 11}, cursor(12), cursor(11)},
+		{"first page before", MessageQuery{Pager: Pager{Limit: 2}, Before: c}, Keyset{Cursor: c, Op: ">", Reverse: true}, messages(11), []int64{11}, nil, cursor(11)},
+		{"relevance", MessageQuery{Pager: Pager{Limit: 2}, Relevance: true, Query: "foo"}, Keyset{Descending: true}, messages(8, 9, 7), []int64{8, 9}, nil, nil},
+		{"empty", MessageQuery{Pager: Pager{Limit: 2}, After: c}, Keyset{Cursor: c, Op: "<", Descending: true}, nil, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		result := &MessageResult{Messages: tt.selected}
+		tt.query.Page(result, tt.k)
+
+		var got []int64
+		for _, m := range result.Messages {
+			got = append(got, m.Timestamp.Unix())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: messages %v, want %v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(result.Prev, tt.prev) || !reflect.DeepEqual(result.Next, tt.next) {
+			t.Errorf("%s: prev %v next %v, want %v %v", tt.name, result.Prev, result.Next, tt.prev, tt.next)
+		}
+	}
+}
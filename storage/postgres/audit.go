@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"github.com/go-pg/pg"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type audit struct {
+	db *pg.DB
+}
+
+func (r audit) Add(entry *models.AuditEntry) error {
+	_, err := r.db.Model(entry).Insert()
+	return err
+}
+
+func (r audit) List(filter storage.AuditFilter) ([]models.AuditEntry, int, error) {
+	list := []models.AuditEntry{}
+	q := r.db.Model(&list)
+	if filter.UserID != "" {
+		q.Where("user_id = ?", filter.UserID)
+	}
+	if filter.ChannelID != "" {
+		q.Where("channel_id = ?", filter.ChannelID)
+	}
+	if filter.Route != "" {
+		q.Where("route = ?", filter.Route)
+	}
+	if filter.From != nil {
+		q.Where("created_at >= ?", filter.From)
+	}
+	if filter.To != nil {
+		q.Where("created_at <= ?", filter.To)
+	}
+	count, err := q.Order("id DESC").
+		Offset(filter.Offset).
+		Limit(filter.Limit).
+		SelectAndCount()
+	return list, count, err
+}
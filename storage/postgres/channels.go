@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type channels struct {
+	db orm.DB
+}
+
+func (r channels) Get(id string) (*models.Channel, error) {
+	channel := &models.Channel{ID: id}
+	if err := r.db.Model(channel).WherePK().Select(); err != nil {
+		return nil, notFound(err)
+	}
+	return channel, nil
+}
+
+func (r channels) List(filter storage.ChannelFilter) ([]models.Channel, int, error) {
+	var channels []models.Channel
+	q := r.db.Model(&channels)
+	if filter.TeamID != "" {
+		q = q.Where("EXISTS (SELECT 1 FROM channel_teams AS ct WHERE ct.channel_id = ?TableAlias.id AND ct.team_id = ?)", filter.TeamID)
+	}
+	count, err := q.Order("id").Offset(filter.Offset).Limit(filter.Limit).SelectAndCount()
+	return channels, count, err
+}
+
+func (r channels) Upsert(c *models.Channel) error {
+	_, err := r.db.Model(c).
+		OnConflict("(id) DO UPDATE").
+		Set("name = EXCLUDED.name, is_channel = EXCLUDED.is_channel, creator_id = EXCLUDED.creator_id, " +
+			"is_archived = EXCLUDED.is_archived, is_deleted = EXCLUDED.is_deleted, is_general = EXCLUDED.is_general, is_group = EXCLUDED.is_group, " +
+			"members = EXCLUDED.members, topic = EXCLUDED.topic, purpose = EXCLUDED.purpose, " +
+			"is_member = EXCLUDED.is_member, last_read = EXCLUDED.last_read, unread_count = EXCLUDED.unread_count, " +
+			"num_members = EXCLUDED.num_members, unread_count_display = EXCLUDED.unread_count_display, " +
+			"is_shared = EXCLUDED.is_shared, is_org_shared = EXCLUDED.is_org_shared, is_ext_shared = EXCLUDED.is_ext_shared").
+		Insert()
+	if err != nil {
+		return err
+	}
+	return r.LinkTeam(c.ID, c.TeamID)
+}
+
+func (r channels) LinkTeam(channelID, teamID string) error {
+	_, err := r.db.Model(&models.ChannelTeam{ChannelID: channelID, TeamID: teamID}).OnConflict("DO NOTHING").Insert()
+	return err
+}
+
+func (r channels) InTeam(channelID, teamID string) (bool, error) {
+	return r.db.Model((*models.ChannelTeam)(nil)).Where("channel_id = ? AND team_id = ?", channelID, teamID).Exists()
+}
+
+func (r channels) AddEvent(event *models.ChannelEvent) error {
+	_, err := r.db.Model(event).Insert()
+	return err
+}
+
+func (r channels) Events(channelID string, pager storage.Pager) ([]models.ChannelEvent, int, error) {
+	events := []models.ChannelEvent{}
+	count, err := r.db.Model(&events).
+		Where("channel_id = ?", channelID).
+		Order("timestamp", "id").
+		Offset(pager.Offset).
+		Limit(pager.Limit).
+		SelectAndCount()
+	return events, count, err
+}
+
+func (r channels) FirstMessagesSince(teamID string, since *time.Time) (res []storage.ChannelSince, err error) {
+	// Channels shared between workspaces of an Enterprise Grid org are only
+	// synced through the team that first archived them
+	query := r.db.Model((*models.Channel)(nil)).
+		Column("id").
+		ColumnExpr("min(messages.timestamp) AS first_since").
+		Join("LEFT JOIN messages ON channel.id = messages.channel_id").
+		Where("channel.team_id = ?", teamID)
+	if since != nil {
+		query = query.Where("messages.timestamp > ?", since)
+	}
+	query = query.Group("channel.id")
+	err = query.Select(&res)
+
+	if err != nil && err != pg.ErrNoRows {
+		return res, err
+	}
+	return res, nil
+}
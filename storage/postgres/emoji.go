@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type emoji struct {
+	db orm.DB
+}
+
+func (r emoji) Get(teamID, name string) (*models.Emoji, error) {
+	e := &models.Emoji{TeamID: teamID, Name: name}
+	if err := r.db.Model(e).WherePK().Select(); err != nil {
+		return nil, notFound(err)
+	}
+	return e, nil
+}
+
+func (r emoji) List(teamID string) ([]models.Emoji, error) {
+	var list []models.Emoji
+	err := r.db.Model(&list).Where("team_id = ?", teamID).Order("name").Select()
+	return list, err
+}
+
+func (r emoji) Upsert(e *models.Emoji) error {
+	_, err := r.db.Model(e).
+		OnConflict("(team_id, name) DO UPDATE").
+		Set("alias_for = EXCLUDED.alias_for, url = EXCLUDED.url, blob_key = EXCLUDED.blob_key, content_type = EXCLUDED.content_type").
+		Set("updated_at = EXCLUDED.updated_at, deleted_at = EXCLUDED.deleted_at").
+		Insert()
+	return err
+}
+
+func (r emoji) Delete(teamID, name string, at time.Time) error {
+	res, err := r.db.Model((*models.Emoji)(nil)).
+		Set("deleted_at = ?", at).
+		Set("updated_at = ?", at).
+		Where("team_id = ?", teamID).
+		Where("name = ?", name).
+		Where("deleted_at IS NULL").
+		Update()
+	if err == nil && res.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type legalHolds struct {
+	db orm.DB
+}
+
+func (r legalHolds) Get(id int64) (*models.LegalHold, error) {
+	hold := &models.LegalHold{ID: id}
+	err := r.db.Model(hold).WherePK().Select()
+	return hold, notFound(err)
+}
+
+func (r legalHolds) List(released bool) ([]models.LegalHold, error) {
+	holds := []models.LegalHold{}
+	q := r.db.Model(&holds)
+	if !released {
+		q = q.Where("released_at IS NULL")
+	}
+	err := q.Order("id DESC").Select()
+	return holds, err
+}
+
+func (r legalHolds) Create(hold *models.LegalHold) error {
+	_, err := r.db.Model(hold).Insert()
+	return err
+}
+
+func (r legalHolds) Release(id int64, by string, at time.Time) error {
+	res, err := r.db.Model((*models.LegalHold)(nil)).
+		Set("released_by = ?, released_at = ?", by, at).
+		Where("id = ?", id).
+		Where("released_at IS NULL").
+		Update()
+	if err == nil && res.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+// heldMessage is a message with how many revisions of it were kept, which
+// isn't a column of the messages table.
+type heldMessage struct {
+	models.Message `pg:",inherit"`
+	Revisions      int
+}
+
+func (r legalHolds) Messages(hold *models.LegalHold, pager storage.Pager) ([]storage.HeldMessage, error) {
+	var messages []heldMessage
+	q := r.db.Model(&messages).
+		ColumnExpr("?TableAlias.*").
+		ColumnExpr(`(SELECT count(*) FROM message_revisions AS r WHERE r.channel_id = ?TableAlias.channel_id AND r."timestamp" = ?TableAlias."timestamp") AS revisions`)
+	if len(hold.ChannelIDs) > 0 {
+		q.Where("?TableAlias.channel_id IN (?)", pg.In(hold.ChannelIDs))
+	}
+	if len(hold.UserIDs) > 0 {
+		q.Where("?TableAlias.user_id IN (?)", pg.In(hold.UserIDs))
+	}
+	if hold.PostedFrom != nil {
+		q.Where(`?TableAlias."timestamp" >= ?`, hold.PostedFrom)
+	}
+	if hold.PostedTo != nil {
+		q.Where(`?TableAlias."timestamp" <= ?`, hold.PostedTo)
+	}
+	err := q.Order("timestamp", "channel_id").
+		Offset(pager.Offset).
+		Limit(pager.Limit).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]storage.HeldMessage, len(messages))
+	for i, m := range messages {
+		list[i] = storage.HeldMessage{Message: m.Message, Revisions: m.Revisions}
+	}
+	return list, nil
+}
@@ -0,0 +1,386 @@
+package postgres
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	errwrap "github.com/pkg/errors"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type messages struct {
+	db          orm.DB
+	hasTrigrams func() bool
+}
+
+func (r messages) Create(m *models.Message) error {
+	_, err := r.db.Model(m).OnConflict("DO NOTHING").Insert()
+	return err
+}
+
+func (r messages) Upsert(m *models.Message) error {
+	_, err := r.db.Model(m).
+		OnConflict(`(channel_id, user_id, "timestamp") DO UPDATE`).
+		Set("thread_timestamp = EXCLUDED.thread_timestamp, msg = EXCLUDED.msg").
+		Insert()
+	return err
+}
+
+func (r messages) Get(channelID string, ts time.Time) (*models.Message, error) {
+	m := new(models.Message)
+	err := r.db.Model(m).
+		Where("channel_id = ?", channelID).
+		Where(`"timestamp" = ?`, ts).
+		Limit(1).
+		Select()
+	return m, notFound(err)
+}
+
+func (r messages) List(after *storage.Cursor, limit int) ([]models.Message, error) {
+	var messages []models.Message
+	q := r.db.Model(&messages).
+		Order("timestamp", "channel_id").
+		Limit(limit)
+	if after != nil {
+		q = q.Where(`("timestamp", channel_id) > (?, ?)`, after.Timestamp, after.ChannelID)
+	}
+	err := q.Select()
+	return messages, err
+}
+
+func (r messages) Discover(query storage.DiscoveryQuery, after *storage.Cursor, limit int) ([]models.Message, error) {
+	var messages []models.Message
+	q := r.db.Model(&messages)
+	if len(query.UserIDs) > 0 {
+		q.Where("user_id IN (?)", pg.In(query.UserIDs))
+	}
+	if len(query.ChannelIDs) > 0 {
+		q.Where("channel_id IN (?)", pg.In(query.ChannelIDs))
+	}
+	if query.Keywords != "" {
+		q.Where("tsv @@ message_search_query(?)", query.Keywords)
+	}
+	if query.From != nil {
+		q.Where(`"timestamp" >= ?`, query.From)
+	}
+	if query.To != nil {
+		q.Where(`"timestamp" <= ?`, query.To)
+	}
+	if after != nil {
+		q.Where(`("timestamp", channel_id) > (?, ?)`, after.Timestamp, after.ChannelID)
+	}
+	err := q.Order("timestamp", "channel_id").
+		Limit(limit).
+		Select()
+	return messages, err
+}
+
+func (r messages) Tombstone(channelID string, ts, at time.Time) error {
+	res, err := r.db.Model((*models.Message)(nil)).
+		Set("deleted_at = ?", at).
+		Where("channel_id = ?", channelID).
+		Where(`"timestamp" = ?`, ts).
+		Where("deleted_at IS NULL").
+		Update()
+	if err == nil && res.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+func (r messages) AddRevision(rev *models.MessageRevision) error {
+	_, err := r.db.Model(rev).Insert()
+	return err
+}
+
+func (r messages) Delete(channelID string, ts time.Time) error {
+	_, err := r.db.Model((*models.Message)(nil)).
+		Where("channel_id = ?", channelID).
+		Where(`"timestamp" = ?`, ts).
+		Delete()
+	return err
+}
+
+// reindexBatch is how many messages Reindex updates per statement
+const reindexBatch = 1000
+
+func (r messages) Reindex(all bool) (int, error) {
+	stale := "tsv_config IS DISTINCT FROM message_search_config(channel_id)"
+	if all {
+		stale = "true"
+	}
+
+	var (
+		total int
+		last  struct {
+			ChannelID string
+			UserID    string
+			Timestamp time.Time
+			Count     int
+		}
+	)
+	for {
+		// Updating a row is enough for messages_upsert_trigger to index it
+		// again. Going through the primary key in order means every row is
+		// visited once, even with all set.
+		_, err := r.db.QueryOne(&last, `
+			WITH batch AS (
+				SELECT channel_id, user_id, "timestamp" FROM messages
+				WHERE (channel_id, user_id, "timestamp") > (?, ?, ?) AND `+stale+`
+				ORDER BY channel_id, user_id, "timestamp"
+				LIMIT ?
+			), updated AS (
+				UPDATE messages AS m SET msg = m.msg FROM batch
+				WHERE m.channel_id = batch.channel_id AND m.user_id = batch.user_id AND m."timestamp" = batch."timestamp"
+				RETURNING 1
+			)
+			SELECT channel_id, user_id, "timestamp", (SELECT count(*) FROM updated) AS count
+			FROM batch
+			ORDER BY channel_id DESC, user_id DESC, "timestamp" DESC
+			LIMIT 1`,
+			last.ChannelID, last.UserID, last.Timestamp, reindexBatch)
+		if err == pg.ErrNoRows {
+			return total, nil
+		} else if err != nil {
+			return total, errwrap.Wrap(err, "Error reindexing messages")
+		}
+
+		total += last.Count
+		log.Infof("Reindexed %d messages", total)
+	}
+}
+
+// rankedMessage is a message with its search rank, which isn't a column of
+// the messages table.
+type rankedMessage struct {
+	models.Message `pg:",inherit"`
+	Score          float64
+}
+
+const headlineOptions = "StartSel=[hl] StopSel=[/hl] HighlightAll=true"
+
+// highlightColumn is msg with the matches of the search term (?0) in the
+// text and attachment titles highlighted, CJK ones included (see
+// migration 18).
+var highlightColumn = func() string {
+	text := `CASE WHEN ?TableAlias.msg->>'text' IS NULL THEN ?TableAlias.msg ` +
+		`ELSE jsonb_set(?TableAlias.msg, '{text}', to_jsonb(message_headline(?TableAlias.tsv_config, ?TableAlias.msg->>'text', message_search_query(?0), '` + headlineOptions + `'))) END`
+
+	attachments := `(SELECT coalesce(jsonb_agg(CASE WHEN a->>'title' IS NULL THEN a ` +
+		`ELSE jsonb_set(a, '{title}', to_jsonb(message_headline(?TableAlias.tsv_config, a->>'title', message_search_query(?0), '` + headlineOptions + `'))) END ORDER BY i), '[]'::jsonb) ` +
+		`FROM jsonb_array_elements(?TableAlias.msg->'attachments') WITH ORDINALITY AS att(a, i))`
+
+	return `CASE WHEN jsonb_typeof(?TableAlias.msg->'attachments') = 'array' ` +
+		`THEN jsonb_set(` + text + `, '{attachments}', ` + attachments + `) ` +
+		`ELSE ` + text + ` END AS msg`
+}()
+
+// scoreColumn ranks matches of the search term (?0) with the weights the
+// messages_upsert_trigger gives the text (A), titles and file names (B) and
+// the rest of the body (C), boosted for newer messages by storage.RecencyScale (?1, in seconds).
+const scoreColumn = `ts_rank_cd('{0.1, 0.2, 0.4, 1.0}', ?TableAlias.tsv, message_search_query(?0)) * ` +
+	`(1 + ?1 / (?1 + greatest(0, extract(epoch FROM now() - ?TableAlias."timestamp")))) AS score`
+
+const (
+	// regexpTimeout is how long searches with a regular expression may run
+	regexpTimeout = 30 * time.Second
+	// queryCanceled is the SQLSTATE of statements that ran out of time
+	queryCanceled = "57014"
+)
+
+func (r messages) Search(query storage.MessageQuery) (*storage.MessageResult, error) {
+	// Regular expressions can't use the indexes, and some are slow on
+	// every message, so they get a deadline
+	if db, ok := r.db.(*pg.DB); ok && query.Mode != storage.SearchFullText {
+		if terms, err := storage.ParseSubstringQuery(query.Query); err == nil && storage.HasRegexp(terms) {
+			var result *storage.MessageResult
+			err := db.RunInTransaction(func(tx *pg.Tx) error {
+				if _, err := tx.Exec(`SET LOCAL statement_timeout = ?`, int(regexpTimeout/time.Millisecond)); err != nil {
+					return err
+				}
+				var err error
+				result, err = messages{tx, r.hasTrigrams}.Search(query)
+				return err
+			})
+			if pgErr, ok := errwrap.Cause(err).(pg.Error); ok && pgErr.Field('C') == queryCanceled {
+				return nil, storage.ErrSearchTimeout
+			}
+			return result, err
+		}
+	}
+
+	result := &storage.MessageResult{
+		Buckets: map[string]int64{},
+	}
+
+	k, err := query.Keyset()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []rankedMessage
+	qry := r.db.Model(&messages)
+
+	fullText := query.Query != "" && query.Mode == storage.SearchFullText
+	var terms []storage.SubstringTerm
+	if fullText {
+		qry.Where(`?TableAlias.tsv @@ message_search_query(?)`, query.Query)
+	} else if query.Query != "" {
+		if terms, err = storage.ParseSubstringQuery(query.Query); err != nil {
+			return nil, err
+		} else if len(terms) == 0 {
+			return result, nil
+		}
+		fuzzy := query.Mode == storage.SearchFuzzy && r.hasTrigrams()
+		for _, t := range terms {
+			qry.Where(substringCondition(t, fuzzy), substringArgs(t)...)
+		}
+	}
+
+	// Shared Enterprise Grid channels are visible from every team they're in
+	qry.Column("Channel._").Where("EXISTS (SELECT 1 FROM channel_teams AS ct WHERE ct.channel_id = ?TableAlias.channel_id AND ct.team_id = ?)", query.TeamID)
+
+	if query.ChannelID != "" {
+		qry.Where("?TableAlias.channel_id = ?", query.ChannelID)
+	}
+
+	if query.From != nil && query.To != nil {
+		qry.Where("?TableAlias.timestamp BETWEEN ? AND ?", query.From, query.To)
+	} else if query.From != nil {
+		qry.Where("?TableAlias.timestamp >= ?", query.From)
+	} else if query.To != nil {
+		qry.Where("?TableAlias.timestamp <= ?", query.To)
+	}
+	if query.ArchivedAfter != nil {
+		qry.Where("?TableAlias.archived_at > ?", query.ArchivedAfter)
+	}
+
+	qry.Where("?TableAlias.deleted_at IS NULL")
+	qry.Where(`NOT ?TableAlias."msg" @> '{"hidden": true}'`)
+	qry.Where(`?TableAlias."msg"->>'subtype' IS NULL OR ?TableAlias."msg"->>'subtype' NOT IN ('message_changed', 'message_deleted', 'channel_join', 'channel_leave', 'pinned_item')`)
+
+	if query.Aggregate {
+		var res []struct {
+			ChannelID string
+			Matches   int64
+		}
+		agg := qry.Copy()
+		if err := agg.Group("channel_id").Column("channel_id").ColumnExpr("count(*) as matches").Select(&res); err != nil {
+			return nil, errwrap.Wrap(err, "Error performing aggregate search")
+		}
+		for _, c := range res {
+			result.Buckets[c.ChannelID] = c.Matches
+		}
+	}
+
+	// The total counts the matches on every page, not just those past the
+	// cursor
+	count := qry.Copy()
+
+	qry.ColumnExpr(`?TableAlias.channel_id, ?TableAlias.user_id, ?TableAlias."timestamp", ?TableAlias.thread_timestamp, ?TableAlias.deleted_at`)
+	if fullText {
+		qry.ColumnExpr(highlightColumn, query.Query)
+		qry.ColumnExpr(scoreColumn, query.Query, storage.RecencyScale.Seconds())
+	} else if terms != nil {
+		expr, args := substringScoreColumn(terms, query.Mode == storage.SearchFuzzy && r.hasTrigrams())
+		qry.ColumnExpr(`?TableAlias.msg`)
+		qry.ColumnExpr(expr, args...)
+	} else {
+		qry.ColumnExpr(`?TableAlias.msg, 0 AS score`)
+	}
+
+	if k.Cursor != nil {
+		qry.Where(`(?TableAlias."timestamp", ?TableAlias.channel_id) `+k.Op+` (?, ?)`, k.Cursor.Timestamp, k.Cursor.ChannelID)
+	} else {
+		qry.Offset(query.Offset)
+	}
+
+	if query.Relevance && query.Query != "" {
+		qry.Order("score DESC")
+	}
+	if k.Descending {
+		qry.Order("timestamp DESC", "channel_id DESC")
+	} else {
+		qry.Order("timestamp", "channel_id")
+	}
+
+	if query.Limit > 0 {
+		qry.Limit(query.Limit + 1)
+	}
+	if err := qry.Relation("User").Select(); err != nil {
+		return nil, errwrap.Wrap(err, "Error selecting messages")
+	}
+	if !query.NoCount {
+		if result.TotalCount, err = count.Count(); err != nil {
+			return nil, errwrap.Wrap(err, "Error counting messages")
+		}
+	}
+
+	result.Messages = make([]models.Message, len(messages))
+	for i, m := range messages {
+		result.Messages[i] = m.Message
+		result.Messages[i].Score = m.Score
+		if terms != nil && m.Msg != nil {
+			result.Messages[i].Msg.Text = storage.HighlightSubstrings(m.Msg.Text, terms)
+		}
+	}
+	query.Page(result, k)
+	return result, nil
+}
+
+// substringCondition matches a term of an exact or fuzzy search against the
+// message text, which migration 6 indexes with pg_trgm when it can. Fuzzy
+// terms also match words at least pg_trgm.word_similarity_threshold, by
+// default storage.FuzzyThreshold, similar.
+func substringCondition(t storage.SubstringTerm, fuzzy bool) string {
+	cond := `?TableAlias.msg->>'text' ILIKE ?0`
+	if t.Regexp {
+		cond = `?TableAlias.msg->>'text' ~* ?0`
+	} else if fuzzy && t.Fuzzy() {
+		cond = `(?TableAlias.msg->>'text' ILIKE ?0 OR ?1 <% (?TableAlias.msg->>'text'))`
+	}
+	if t.Negated {
+		return `NOT coalesce(` + cond + `, false)`
+	}
+	return cond
+}
+
+func substringArgs(t storage.SubstringTerm) []interface{} {
+	if t.Regexp {
+		// ParseSubstringQuery made sure it can be written for Postgres
+		re, _ := t.PostgresRegexp()
+		return []interface{}{re}
+	}
+	return []interface{}{t.LikePattern(), t.Text}
+}
+
+// substringScoreColumn scores exact and fuzzy matches: every term that's
+// there counts one, a fuzzy one its similarity. They get the same boost for
+// newer messages as full text matches.
+func substringScoreColumn(terms []storage.SubstringTerm, fuzzy bool) (string, []interface{}) {
+	var (
+		parts []string
+		args  []interface{}
+	)
+	for _, t := range terms {
+		switch {
+		case t.Negated:
+		case fuzzy && t.Fuzzy():
+			parts = append(parts, `CASE WHEN ?TableAlias.msg->>'text' ILIKE ? THEN 1 ELSE word_similarity(?, ?TableAlias.msg->>'text') END`)
+			args = append(args, t.LikePattern(), t.Text)
+		default:
+			parts = append(parts, `1`)
+		}
+	}
+	if len(parts) == 0 {
+		parts = []string{`1`}
+	}
+	scale := storage.RecencyScale.Seconds()
+	args = append(args, scale, scale)
+	return `(` + strings.Join(parts, ` + `) + `) * ` +
+		`(1 + ? / (? + greatest(0, extract(epoch FROM now() - ?TableAlias."timestamp")))) AS score`, args
+}
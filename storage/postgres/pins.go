@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type pins struct {
+	db orm.DB
+}
+
+func (r pins) List(channelID string, removed bool) ([]models.Pin, error) {
+	list := []models.Pin{}
+	q := r.db.Model(&list).Where("channel_id = ?", channelID)
+	if !removed {
+		q = q.Where("removed_at IS NULL")
+	}
+	err := q.Order("pinned_at DESC").Select()
+	return list, err
+}
+
+func (r pins) Upsert(pin *models.Pin) error {
+	_, err := r.db.Model(pin).
+		OnConflict("(channel_id, item_id) DO UPDATE").
+		Set("type = EXCLUDED.type, item = EXCLUDED.item").
+		Set("pinned_by = CASE WHEN ?TableAlias.removed_at IS NULL THEN ?TableAlias.pinned_by ELSE EXCLUDED.pinned_by END").
+		Set("pinned_at = CASE WHEN ?TableAlias.removed_at IS NULL THEN ?TableAlias.pinned_at ELSE EXCLUDED.pinned_at END").
+		Set("removed_at = NULL").
+		Insert()
+	return err
+}
+
+func (r pins) Remove(channelID, itemID string, at time.Time) error {
+	res, err := r.db.Model((*models.Pin)(nil)).
+		Set("removed_at = ?", at).
+		Where("channel_id = ?", channelID).
+		Where("item_id = ?", itemID).
+		Where("removed_at IS NULL").
+		Update()
+	if err == nil && res.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+type bookmarks struct {
+	db orm.DB
+}
+
+func (r bookmarks) List(channelID string, removed bool) ([]models.Bookmark, error) {
+	list := []models.Bookmark{}
+	q := r.db.Model(&list).Where("channel_id = ?", channelID)
+	if !removed {
+		q = q.Where("removed_at IS NULL")
+	}
+	err := q.Order("rank", "created_at").Select()
+	return list, err
+}
+
+func (r bookmarks) Upsert(bookmark *models.Bookmark) error {
+	_, err := r.db.Model(bookmark).
+		OnConflict("(id) DO UPDATE").
+		Set("channel_id = EXCLUDED.channel_id, title = EXCLUDED.title, link = EXCLUDED.link, emoji = EXCLUDED.emoji").
+		Set("icon_url = EXCLUDED.icon_url, type = EXCLUDED.type, rank = EXCLUDED.rank, updated_by = EXCLUDED.updated_by").
+		Set("created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at, removed_at = NULL").
+		Insert()
+	return err
+}
+
+func (r bookmarks) Remove(id string, at time.Time) error {
+	res, err := r.db.Model((*models.Bookmark)(nil)).
+		Set("removed_at = ?", at).
+		Where("id = ?", id).
+		Where("removed_at IS NULL").
+		Update()
+	if err == nil && res.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+// Package postgres is the go-pg backed archive store. The schema is managed
+// by the go-pg migrations in the migrations package.
+package postgres
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/go-pg/pg"
+	"github.com/pkg/errors"
+
+	"github.com/ashb/slackarchive/logs"
+	dbmigrations "github.com/ashb/slackarchive/migrations"
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+var log = logs.New("storage")
+
+func init() {
+	storage.Register("postgres", open)
+	storage.Register("postgresql", open)
+}
+
+func open(dsn *url.URL, debug bool) (storage.Store, error) {
+	return Open(dsn.String(), debug)
+}
+
+type Store struct {
+	db *pg.DB
+
+	// trigrams is shared with the stores WithContext returns
+	trigrams *trigrams
+}
+
+type trigrams struct {
+	once      sync.Once
+	installed bool
+}
+
+// Open connects to the Postgres database at dsn.
+func Open(dsn string, debug bool) (*Store, error) {
+	opts, err := pg.ParseURL(dsn)
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't create db")
+	}
+	db := pg.Connect(opts)
+	if debug {
+		db.AddQueryHook(models.DBLogger{Logger: log})
+	} else {
+		db.AddQueryHook(models.DBLogger{})
+	}
+	return &Store{db: db, trigrams: &trigrams{}}, nil
+}
+
+// DB is the underlying connection, for the migrate command.
+func (s *Store) DB() *pg.DB {
+	return s.db
+}
+
+func (s *Store) Teams() storage.TeamRepository       { return teams{s.db} }
+func (s *Store) Users() storage.UserRepository       { return users{s.db} }
+func (s *Store) Channels() storage.ChannelRepository { return channels{s.db} }
+func (s *Store) Messages() storage.MessageRepository { return messages{s.db, s.hasTrigrams} }
+func (s *Store) Emoji() storage.EmojiRepository      { return emoji{s.db} }
+func (s *Store) Pins() storage.PinRepository         { return pins{s.db} }
+func (s *Store) Purges() storage.PurgeRepository     { return purges{s.db} }
+func (s *Store) Audit() storage.AuditRepository      { return audit{s.db} }
+func (s *Store) Bookmarks() storage.BookmarkRepository {
+	return bookmarks{s.db}
+}
+func (s *Store) LegalHolds() storage.LegalHoldRepository {
+	return legalHolds{s.db}
+}
+func (s *Store) SavedSearches() storage.SavedSearchRepository {
+	return savedSearches{s.db}
+}
+
+// hasTrigrams is whether the pg_trgm extension is installed. Migration 6
+// only creates it when the server has it, fuzzy search is exact without.
+func (s *Store) hasTrigrams() bool {
+	s.trigrams.once.Do(func() {
+		_, err := s.db.QueryOne(pg.Scan(&s.trigrams.installed), `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')`)
+		if err != nil {
+			log.Warningf("Couldn't check for pg_trgm: %s", err)
+		} else if !s.trigrams.installed {
+			log.Warning("The pg_trgm extension isn't installed, fuzzy search only finds exact matches")
+		}
+	})
+	return s.trigrams.installed
+}
+
+func (s *Store) Migrate() (int64, int64, error) {
+	oldVersion, newVersion, err := dbmigrations.Migrate(s.db)
+	if err == nil && dbmigrations.NeedsReindex(oldVersion, newVersion) {
+		log.Warning("Messages archived so far aren't searchable by their blocks, attachments and files yet, run `slackarchive reindex --all`")
+	}
+	return oldVersion, newVersion, err
+}
+
+func (s *Store) SchemaVersion() (int64, int64, error) {
+	version, err := dbmigrations.Version(s.db)
+	return version, dbmigrations.LatestVersion(), err
+}
+
+func (s *Store) WithContext(ctx context.Context) storage.Store {
+	return &Store{db: s.db.WithContext(ctx), trigrams: s.trigrams}
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// notFound translates go-pg's missing row error to storage's.
+func notFound(err error) error {
+	if err == pg.ErrNoRows {
+		return storage.ErrNotFound
+	}
+	return err
+}
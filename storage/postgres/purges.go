@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type purges struct {
+	db orm.DB
+}
+
+func (r purges) Messages(channelID string, before time.Time, holds []models.LegalHold, limit int) (int, *time.Time, *time.Time, error) {
+	held, args := heldCondition(holds)
+	var (
+		n              int
+		oldest, newest pg.NullTime
+	)
+	_, err := r.db.QueryOne(pg.Scan(&n, &oldest, &newest), `
+		WITH batch AS (
+			SELECT channel_id, user_id, "timestamp" FROM messages
+			WHERE channel_id = ? AND "timestamp" < ? AND NOT `+held+`
+			ORDER BY "timestamp"
+			LIMIT ?
+		), deleted AS (
+			DELETE FROM messages AS m USING batch AS b
+			WHERE m.channel_id = b.channel_id AND m.user_id = b.user_id AND m."timestamp" = b."timestamp"
+			RETURNING m."timestamp"
+		)
+		SELECT count(*), min("timestamp"), max("timestamp") FROM deleted`,
+		append(append([]interface{}{channelID, before}, args...), limit)...)
+	if err != nil || n == 0 {
+		return 0, nil, nil, err
+	}
+	return n, &oldest.Time, &newest.Time, nil
+}
+
+// heldCondition matches the messages any of holds covers.
+func heldCondition(holds []models.LegalHold) (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for i := range holds {
+		h := &holds[i]
+		if !h.Active() {
+			continue
+		}
+		cond := []string{"true"}
+		if len(h.ChannelIDs) > 0 {
+			cond = append(cond, "channel_id IN (?)")
+			args = append(args, pg.In(h.ChannelIDs))
+		}
+		if len(h.UserIDs) > 0 {
+			cond = append(cond, "user_id IN (?)")
+			args = append(args, pg.In(h.UserIDs))
+		}
+		if h.PostedFrom != nil {
+			cond = append(cond, `"timestamp" >= ?`)
+			args = append(args, *h.PostedFrom)
+		}
+		if h.PostedTo != nil {
+			cond = append(cond, `"timestamp" <= ?`)
+			args = append(args, *h.PostedTo)
+		}
+		conds = append(conds, "("+strings.Join(cond, " AND ")+")")
+	}
+	if len(conds) == 0 {
+		return "false", nil
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+func (r purges) Pins(channelID string, before time.Time) (int, error) {
+	res, err := r.db.Exec(`
+		DELETE FROM pins
+		WHERE channel_id = ? AND CASE type
+			WHEN 'message' THEN to_timestamp(item_id::double precision)
+			WHEN 'file' THEN coalesce(to_timestamp(nullif(item->'file'->>'created', '0')::double precision), pinned_at)
+			ELSE pinned_at
+		END < ?`,
+		channelID, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+func (r purges) Bookmarks(channelID string, before time.Time) (int, error) {
+	res, err := r.db.Exec(`DELETE FROM bookmarks WHERE channel_id = ? AND created_at < ?`, channelID, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+func (r purges) Deleted(holds []models.LegalHold) (int, error) {
+	held, args := heldCondition(holds)
+	res, err := r.db.Exec(`DELETE FROM messages WHERE deleted_at IS NOT NULL AND NOT `+held, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+func (r purges) Add(purge *models.Purge) error {
+	_, err := r.db.Model(purge).Insert()
+	return err
+}
+
+func (r purges) List(pager storage.Pager) ([]models.Purge, int, error) {
+	list := []models.Purge{}
+	count, err := r.db.Model(&list).
+		Order("id DESC").
+		Offset(pager.Offset).
+		Limit(pager.Limit).
+		SelectAndCount()
+	return list, count, err
+}
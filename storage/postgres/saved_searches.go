@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type savedSearches struct {
+	db orm.DB
+}
+
+func (r savedSearches) Get(id int64) (*models.SavedSearch, error) {
+	search := &models.SavedSearch{ID: id}
+	if err := r.db.Model(search).WherePK().Select(); err != nil {
+		return nil, notFound(err)
+	}
+	return search, nil
+}
+
+func (r savedSearches) List(userID string) ([]models.SavedSearch, error) {
+	var searches []models.SavedSearch
+	err := r.db.Model(&searches).Where("user_id = ?", userID).Order("id").Select()
+	return searches, err
+}
+
+func (r savedSearches) ListNotifying(teamID string) ([]models.SavedSearch, error) {
+	var searches []models.SavedSearch
+	err := r.db.Model(&searches).Where("team_id = ?", teamID).Where("notify").Order("id").Select()
+	return searches, err
+}
+
+func (r savedSearches) Create(search *models.SavedSearch) error {
+	_, err := r.db.Model(search).Insert()
+	return err
+}
+
+func (r savedSearches) Update(search *models.SavedSearch) error {
+	_, err := r.db.Model(search).WherePK().Update()
+	return err
+}
+
+func (r savedSearches) Delete(id int64) error {
+	res, err := r.db.Model((*models.SavedSearch)(nil)).Where("id = ?", id).Delete()
+	if err == nil && res.RowsAffected() == 0 {
+		return storage.ErrNotFound
+	}
+	return err
+}
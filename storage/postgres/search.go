@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ashb/slackarchive/config"
+)
+
+type searchConfig struct {
+	tableName struct{} `sql:"search_configs"`
+
+	Scope  string `sql:",pk"`
+	ID     string `sql:",pk,notnull"`
+	Config string `sql:",notnull"`
+}
+
+func (s *Store) ConfigureSearch(search config.SearchConfig) error {
+	var configs []searchConfig
+	if search.Language != "" {
+		configs = append(configs, searchConfig{Scope: "default", Config: search.Language})
+	}
+	for id, language := range search.Teams {
+		configs = append(configs, searchConfig{Scope: "team", ID: id, Config: language})
+	}
+	for id, language := range search.Channels {
+		configs = append(configs, searchConfig{Scope: "channel", ID: id, Config: language})
+	}
+
+	return s.db.RunInTransaction(func(tx *pg.Tx) error {
+		for _, c := range configs {
+			if c.Config == "cjk" {
+				continue
+			}
+			if _, err := tx.Exec(`SELECT ?::regconfig`, c.Config); err != nil {
+				return fmt.Errorf("unknown text search configuration %q for %s %s", c.Config, c.Scope, c.ID)
+			}
+		}
+
+		var old []searchConfig
+		if err := tx.Model(&old).Select(); err != nil {
+			return err
+		}
+		if !sameSearchConfigs(old, configs) {
+			log.Warning("Search languages have changed, run `slackarchive reindex` to update existing messages")
+		}
+
+		if _, err := tx.Exec(`DELETE FROM search_configs`); err != nil {
+			return err
+		}
+		if len(configs) == 0 {
+			return nil
+		}
+		_, err := tx.Model(&configs).Insert()
+		return err
+	})
+}
+
+func sameSearchConfigs(a, b []searchConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := map[searchConfig]bool{}
+	for _, c := range a {
+		seen[c] = true
+	}
+	for _, c := range b {
+		if !seen[c] {
+			return false
+		}
+	}
+	return true
+}
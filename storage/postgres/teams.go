@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ashb/slackarchive/models"
+)
+
+type teams struct {
+	db orm.DB
+}
+
+func (r teams) Get(id string) (*models.Team, error) {
+	team := &models.Team{ID: id}
+	if err := r.db.Model(team).WherePK().Select(); err != nil {
+		return nil, notFound(err)
+	}
+	return team, nil
+}
+
+func (r teams) GetByDomain(domain string) (*models.Team, error) {
+	team := &models.Team{}
+	if err := r.db.Model(team).Where("domain = ?", domain).Select(); err != nil {
+		return nil, notFound(err)
+	}
+	return team, nil
+}
+
+func (r teams) List() ([]models.Team, error) {
+	var teams []models.Team
+	err := r.db.Model(&teams).Where("is_disabled IS NOT TRUE").Select()
+	return teams, err
+}
+
+func (r teams) Upsert(team *models.Team) error {
+	_, err := r.db.Model(team).OnConflict("(id) DO UPDATE").Insert()
+	return err
+}
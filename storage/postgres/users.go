@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type users struct {
+	db orm.DB
+}
+
+func (r users) Get(id string) (*models.User, error) {
+	user := &models.User{ID: id}
+	if err := r.db.Model(user).WherePK().Select(); err != nil {
+		return nil, notFound(err)
+	}
+	return user, nil
+}
+
+func (r users) GetMany(ids []string) ([]models.User, error) {
+	users := []models.User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Model(&users).Where("id IN (?)", pg.In(ids)).Select()
+	return users, err
+}
+
+func (r users) Exists(id string) (bool, error) {
+	return r.db.Model(&models.User{ID: id}).WherePK().Exists()
+}
+
+func (r users) List(filter storage.UserFilter) ([]models.User, int, error) {
+	var users []models.User
+	count, err := r.db.Model(&users).
+		Where("?TableAlias.team_id = ?", filter.TeamID).
+		Order("name ASC").
+		Offset(filter.Offset).
+		Limit(filter.Limit).
+		SelectAndCount()
+	return users, count, err
+}
+
+func (r users) Create(user *models.User) error {
+	_, err := r.db.Model(user).OnConflict("DO NOTHING").Insert()
+	return err
+}
+
+func (r users) Update(user *models.User) error {
+	_, err := r.db.Model(user).WherePK().Update()
+	return err
+}
+
+func (r users) Upsert(user *models.User) error {
+	_, err := r.db.Model(user).OnConflict("(id) DO UPDATE").Insert()
+	return err
+}
+
+func (r users) UpsertBot(user *models.User) error {
+	_, err := r.db.Model(user).OnConflict("(id) DO UPDATE").Set("name = excluded.NAME, deleted = excluded.deleted, profile = excluded.profile").Insert()
+	return err
+}
+
+func (r users) UpsertExternal(user *models.User) error {
+	_, err := r.db.Model(user).
+		OnConflict("(id) DO UPDATE").
+		Set("name = EXCLUDED.name, deleted = EXCLUDED.deleted, profile = EXCLUDED.profile, " +
+			"is_external = EXCLUDED.is_external, home_team_id = EXCLUDED.home_team_id, home_team_name = EXCLUDED.home_team_name").
+		Insert()
+	return err
+}
+
+func (r users) History(id string) ([]models.UserProfileHistory, error) {
+	history := []models.UserProfileHistory{}
+	err := r.db.Model(&history).Where("user_id = ?", id).Order("changed_at", "id").Select()
+	return history, err
+}
+
+func (r users) LatestHistory(id string) (*models.UserProfileHistory, error) {
+	entry := &models.UserProfileHistory{}
+	err := r.db.Model(entry).Where("user_id = ?", id).Order("changed_at DESC", "id DESC").Limit(1).Select()
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return entry, nil
+}
+
+func (r users) AddHistory(entry *models.UserProfileHistory) error {
+	_, err := r.db.Model(entry).Insert()
+	return err
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxRepeat is the most a {n,m} repeat can count to in a Postgres
+	// regular expression.
+	maxRepeat = 255
+	// maxPostgresRegexp keeps Unicode classes like \pL, hundreds of ranges
+	// written out, from making expressions Postgres finds too complex.
+	maxPostgresRegexp = 4096
+)
+
+// PostgresRegexp is the regular expression of a /regexp/ term written for
+// Postgres' ~* operator.
+//
+// Postgres' advanced regular expressions aren't RE2: some of what RE2
+// accepts is an error there, and some, like \b, means something else. So
+// the term is parsed as RE2, which is what highlights it and what SQLite
+// runs, and written out again in the subset the two agree on. Backreferences,
+// which make Postgres backtrack, can't be written in RE2 to begin with.
+func (t SubstringTerm) PostgresRegexp() (string, error) {
+	re, err := syntax.Parse(t.Text, syntax.Perl|syntax.FoldCase)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := writePostgresRegexp(&b, re); err != nil {
+		return "", err
+	}
+	if b.Len() > maxPostgresRegexp {
+		return "", fmt.Errorf("too complex")
+	}
+	return b.String(), nil
+}
+
+func writePostgresRegexp(b *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		b.WriteString("(?:)")
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == 0 {
+				return fmt.Errorf("NUL can't be in messages")
+			}
+			writePostgresRune(b, r)
+		}
+	case syntax.OpCharClass:
+		b.WriteByte('[')
+		for i := 0; i < len(re.Rune); i += 2 {
+			// Postgres text has no NULs to match
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			if lo == 0 {
+				lo = 1
+			}
+			if hi < lo {
+				continue
+			}
+			writePostgresRune(b, lo)
+			if hi != lo {
+				b.WriteByte('-')
+				writePostgresRune(b, hi)
+			}
+		}
+		if b.String()[b.Len()-1] == '[' {
+			return fmt.Errorf("empty character class")
+		}
+		b.WriteByte(']')
+	case syntax.OpAnyCharNotNL:
+		b.WriteString(`[^\n]`)
+	case syntax.OpAnyChar:
+		b.WriteByte('.')
+	case syntax.OpBeginText:
+		b.WriteByte('^')
+	case syntax.OpEndText:
+		b.WriteByte('$')
+	case syntax.OpWordBoundary:
+		b.WriteString(`\y`)
+	case syntax.OpNoWordBoundary:
+		b.WriteString(`\Y`)
+	case syntax.OpCapture:
+		return writePostgresGroup(b, re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		if err := writePostgresGroup(b, re.Sub[0]); err != nil {
+			return err
+		}
+		switch re.Op {
+		case syntax.OpStar:
+			b.WriteByte('*')
+		case syntax.OpPlus:
+			b.WriteByte('+')
+		case syntax.OpQuest:
+			b.WriteByte('?')
+		default:
+			if re.Min > maxRepeat || re.Max > maxRepeat {
+				return fmt.Errorf("repeat count over %d", maxRepeat)
+			}
+			switch {
+			case re.Max == -1:
+				fmt.Fprintf(b, "{%d,}", re.Min)
+			case re.Min == re.Max:
+				fmt.Fprintf(b, "{%d}", re.Min)
+			default:
+				fmt.Fprintf(b, "{%d,%d}", re.Min, re.Max)
+			}
+		}
+		if re.Flags&syntax.NonGreedy != 0 {
+			b.WriteByte('?')
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := writePostgresRegexp(b, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		b.WriteString("(?:")
+		for i, sub := range re.Sub {
+			if i > 0 {
+				b.WriteByte('|')
+			}
+			if err := writePostgresRegexp(b, sub); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+	case syntax.OpBeginLine, syntax.OpEndLine:
+		return fmt.Errorf("multi-line mode isn't supported")
+	default:
+		return fmt.Errorf("unsupported %s", re)
+	}
+	return nil
+}
+
+// writePostgresGroup writes re as a non-capturing group, for a repeat to
+// apply to all of it.
+func writePostgresGroup(b *strings.Builder, re *syntax.Regexp) error {
+	b.WriteString("(?:")
+	if err := writePostgresRegexp(b, re); err != nil {
+		return err
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// writePostgresRune writes r so it's literal in and out of brackets: letters
+// and digits as they are, other printable characters escaped with a
+// backslash and the rest as \u or \U escapes.
+func writePostgresRune(b *strings.Builder, r rune) {
+	switch {
+	case r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+		b.WriteRune(r)
+	case r < 0x80 && unicode.IsPrint(r):
+		b.WriteByte('\\')
+		b.WriteRune(r)
+	case r < 0x80:
+		fmt.Fprintf(b, `\u%04x`, r)
+	case unicode.IsPrint(r) && r <= 0xFFFF:
+		b.WriteRune(r)
+	case r <= 0xFFFF:
+		fmt.Fprintf(b, `\u%04x`, r)
+	default:
+		fmt.Fprintf(b, `\U%08x`, r)
+	}
+}
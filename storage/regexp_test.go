@@ -0,0 +1,37 @@
+package storage
+
+import "testing"
+
+func TestPostgresRegexp(t *testing.T) {
+	tests := []struct {
+		re, want string
+	}{
+		{`v\d{2}`, `V(?:[0-9]){2}`},
+		{`^foo$`, `^FOO$`},
+		{`\bERR-\d+\b`, `\yERR\-(?:[0-9])+\y`},
+		{`\Bx`, `\YX`},
+		{`a.b`, `A[^\n]B`},
+		{`(?s)a.b`, `A.B`},
+		{`(?P<name>ab)+?`, `(?:(?:AB))+?`},
+		{`foo|bar`, `(?:FOO|BAR)`},
+		{`x{2,}`, `(?:X){2,}`},
+		{`x{3,5}`, `(?:X){3,5}`},
+		{`\Q1+1\E`, `1\+1`},
+		{`[^a-c]`, "[\\u0001-\\@D-\\`d-\\U0010ffff]"},
+		{`a|`, `(?:A|(?:))`},
+		{`\x{1F600}\t`, `\U0001f600\u0009`},
+		{`\Afoo\z`, `^FOO$`},
+		{`x{255}`, `(?:X){255}`},
+	}
+	for _, tt := range tests {
+		if got, err := (SubstringTerm{Text: tt.re, Regexp: true}).PostgresRegexp(); err != nil || got != tt.want {
+			t.Errorf("PostgresRegexp(%q) = %q, %v, want %q", tt.re, got, err, tt.want)
+		}
+	}
+
+	for _, re := range []string{`(?m)^x`, `x(?m)$`, `x{256}`, `\x00`, `\pL`, `[^\x00-\x{10FFFF}]`} {
+		if got, err := (SubstringTerm{Text: re, Regexp: true}).PostgresRegexp(); err == nil {
+			t.Errorf("PostgresRegexp(%q) = %q, want an error", re, got)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type audit struct {
+	s *Store
+}
+
+func (r audit) Add(e *models.AuditEntry) error {
+	res, err := r.s.exec(`
+		INSERT INTO audit_log (user_id, method, route, path, params, channel_id, status, remote_addr, user_agent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.UserID, e.Method, e.Route, e.Path, jsonColumn{e.Params}, e.ChannelID, e.Status, e.RemoteAddr, e.UserAgent,
+		toMicros(&e.CreatedAt),
+	)
+	if err != nil {
+		return err
+	}
+	e.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r audit) List(filter storage.AuditFilter) ([]models.AuditEntry, int, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	if filter.UserID != "" {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.ChannelID != "" {
+		where = append(where, "channel_id = ?")
+		args = append(args, filter.ChannelID)
+	}
+	if filter.Route != "" {
+		where = append(where, "route = ?")
+		args = append(args, filter.Route)
+	}
+	if filter.From != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, toMicros(filter.From))
+	}
+	if filter.To != nil {
+		where = append(where, "created_at <= ?")
+		args = append(args, toMicros(filter.To))
+	}
+	body := ` FROM audit_log WHERE ` + strings.Join(where, ` AND `)
+
+	var count int
+	if err := r.s.queryRow(`SELECT count(*)`+body, args...).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.s.query(`
+		SELECT id, user_id, method, route, path, params, channel_id, status, remote_addr, user_agent, created_at`+
+		body+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	list := []models.AuditEntry{}
+	for rows.Next() {
+		var (
+			e                                models.AuditEntry
+			channelID, remoteAddr, userAgent sql.NullString
+			createdAt                        sql.NullInt64
+		)
+		err := rows.Scan(&e.ID, &e.UserID, &e.Method, &e.Route, &e.Path, jsonColumn{&e.Params}, &channelID, &e.Status,
+			&remoteAddr, &userAgent, &createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.ChannelID = channelID.String
+		e.RemoteAddr = remoteAddr.String
+		e.UserAgent = userAgent.String
+		e.CreatedAt = *fromMicros(createdAt)
+		list = append(list, e)
+	}
+	return list, count, rows.Err()
+}
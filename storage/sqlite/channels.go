@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type channels struct {
+	s *Store
+}
+
+const channelColumns = `id, name, team_id, is_channel, coalesce(creator_id, ''), is_archived, is_deleted, is_general, is_group,
+	members, topic, purpose, is_member, coalesce(last_read, ''), coalesce(unread_count, 0), num_members,
+	coalesce(unread_count_display, 0), is_shared, is_org_shared, is_ext_shared`
+
+func scanChannel(row scanner) (*models.Channel, error) {
+	c := &models.Channel{}
+	err := row.Scan(&c.ID, &c.Name, &c.TeamID, &c.IsChannel, &c.CreatorID, &c.IsArchived, &c.IsDeleted, &c.IsGeneral, &c.IsGroup,
+		jsonColumn{&c.Members}, jsonColumn{&c.Topic}, jsonColumn{&c.Purpose}, &c.IsMember, &c.LastRead, &c.UnreadCount, &c.NumMembers,
+		&c.UnreadCountDisplay, &c.IsShared, &c.IsOrgShared, &c.IsExtShared)
+	return c, err
+}
+
+func (r channels) Get(id string) (*models.Channel, error) {
+	c, err := scanChannel(r.s.queryRow(`SELECT `+channelColumns+` FROM channels WHERE id = ?`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return c, nil
+}
+
+func (r channels) List(filter storage.ChannelFilter) ([]models.Channel, int, error) {
+	where, args := `1 = 1`, []interface{}{}
+	if filter.TeamID != "" {
+		where = `EXISTS (SELECT 1 FROM channel_teams AS ct WHERE ct.channel_id = channels.id AND ct.team_id = ?)`
+		args = append(args, filter.TeamID)
+	}
+
+	var count int
+	if err := r.s.queryRow(`SELECT count(*) FROM channels WHERE `+where, args...).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.s.query(`SELECT `+channelColumns+` FROM channels WHERE `+where+` ORDER BY id LIMIT ? OFFSET ?`,
+		append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var channels []models.Channel
+	for rows.Next() {
+		c, err := scanChannel(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		channels = append(channels, *c)
+	}
+	return channels, count, rows.Err()
+}
+
+func (r channels) Upsert(c *models.Channel) error {
+	var creator interface{}
+	if c.CreatorID != "" {
+		creator = c.CreatorID
+	}
+
+	_, err := r.s.exec(`
+		INSERT INTO channels (id, name, team_id, is_channel, creator_id, is_archived, is_deleted, is_general, is_group,
+			members, topic, purpose, is_member, last_read, unread_count, num_members,
+			unread_count_display, is_shared, is_org_shared, is_ext_shared)
+		VALUES (`+placeholders(20)+`)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name, is_channel = excluded.is_channel, creator_id = excluded.creator_id,
+			is_archived = excluded.is_archived, is_deleted = excluded.is_deleted, is_general = excluded.is_general, is_group = excluded.is_group,
+			members = excluded.members, topic = excluded.topic, purpose = excluded.purpose,
+			is_member = excluded.is_member, last_read = excluded.last_read, unread_count = excluded.unread_count,
+			num_members = excluded.num_members, unread_count_display = excluded.unread_count_display,
+			is_shared = excluded.is_shared, is_org_shared = excluded.is_org_shared, is_ext_shared = excluded.is_ext_shared`,
+		c.ID, c.Name, c.TeamID, c.IsChannel, creator, c.IsArchived, c.IsDeleted, c.IsGeneral, c.IsGroup,
+		jsonColumn{c.Members}, jsonColumn{c.Topic}, jsonColumn{c.Purpose}, c.IsMember, c.LastRead, c.UnreadCount, c.NumMembers,
+		c.UnreadCountDisplay, c.IsShared, c.IsOrgShared, c.IsExtShared,
+	)
+	if err != nil {
+		return err
+	}
+	return r.LinkTeam(c.ID, c.TeamID)
+}
+
+func (r channels) LinkTeam(channelID, teamID string) error {
+	_, err := r.s.exec(`INSERT INTO channel_teams (channel_id, team_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, channelID, teamID)
+	return err
+}
+
+func (r channels) InTeam(channelID, teamID string) (bool, error) {
+	var exists bool
+	err := r.s.queryRow(`SELECT EXISTS (SELECT 1 FROM channel_teams WHERE channel_id = ? AND team_id = ?)`, channelID, teamID).Scan(&exists)
+	return exists, err
+}
+
+const channelEventColumns = `id, channel_id, type, coalesce(user_id, ''), name, old_name, value, "timestamp"`
+
+func (r channels) AddEvent(e *models.ChannelEvent) error {
+	res, err := r.s.exec(`
+		INSERT INTO channel_events (channel_id, type, user_id, name, old_name, value, "timestamp")
+		VALUES (?, ?, nullif(?, ''), ?, ?, ?, ?)`,
+		e.ChannelID, e.Type, e.UserID, e.Name, e.OldName, e.Value, toMicros(&e.Timestamp),
+	)
+	if err != nil {
+		return err
+	}
+	e.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r channels) Events(channelID string, pager storage.Pager) ([]models.ChannelEvent, int, error) {
+	var count int
+	if err := r.s.queryRow(`SELECT count(*) FROM channel_events WHERE channel_id = ?`, channelID).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.s.query(`SELECT `+channelEventColumns+` FROM channel_events WHERE channel_id = ? ORDER BY "timestamp", id LIMIT ? OFFSET ?`,
+		channelID, pager.Limit, pager.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []models.ChannelEvent{}
+	for rows.Next() {
+		var (
+			e  models.ChannelEvent
+			ts sql.NullInt64
+		)
+		if err := rows.Scan(&e.ID, &e.ChannelID, &e.Type, &e.UserID, &e.Name, &e.OldName, &e.Value, &ts); err != nil {
+			return nil, 0, err
+		}
+		e.Timestamp = *fromMicros(ts)
+		events = append(events, e)
+	}
+	return events, count, rows.Err()
+}
+
+func (r channels) FirstMessagesSince(teamID string, since *time.Time) ([]storage.ChannelSince, error) {
+	// Channels shared between workspaces of an Enterprise Grid org are only
+	// synced through the team that first archived them
+	query := `SELECT channels.id, min(messages."timestamp")
+		FROM channels LEFT JOIN messages ON channels.id = messages.channel_id
+		WHERE channels.team_id = ?`
+	args := []interface{}{teamID}
+	if since != nil {
+		query += ` AND messages."timestamp" > ?`
+		args = append(args, toMicros(since))
+	}
+	query += ` GROUP BY channels.id`
+
+	rows, err := r.s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []storage.ChannelSince
+	for rows.Next() {
+		var (
+			c     storage.ChannelSince
+			first sql.NullInt64
+		)
+		if err := rows.Scan(&c.ID, &first); err != nil {
+			return nil, err
+		}
+		c.FirstSince = fromMicros(first)
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
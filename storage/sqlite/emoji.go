@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type emoji struct {
+	s *Store
+}
+
+const emojiColumns = `team_id, name, alias_for, url, blob_key, content_type, created_at, updated_at, deleted_at`
+
+func scanEmoji(row scanner) (*models.Emoji, error) {
+	var (
+		e                         models.Emoji
+		created, updated, deleted sql.NullInt64
+	)
+	err := row.Scan(&e.TeamID, &e.Name, &e.AliasFor, &e.URL, &e.BlobKey, &e.ContentType, &created, &updated, &deleted)
+	if err != nil {
+		return nil, err
+	}
+	e.CreatedAt = *fromMicros(created)
+	e.UpdatedAt = *fromMicros(updated)
+	e.DeletedAt = fromMicros(deleted)
+	return &e, nil
+}
+
+func (r emoji) Get(teamID, name string) (*models.Emoji, error) {
+	e, err := scanEmoji(r.s.queryRow(`SELECT `+emojiColumns+` FROM emoji WHERE team_id = ? AND name = ?`, teamID, name))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return e, nil
+}
+
+func (r emoji) List(teamID string) ([]models.Emoji, error) {
+	rows, err := r.s.query(`SELECT `+emojiColumns+` FROM emoji WHERE team_id = ? ORDER BY name`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Emoji
+	for rows.Next() {
+		e, err := scanEmoji(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
+	}
+	return list, rows.Err()
+}
+
+func (r emoji) Upsert(e *models.Emoji) error {
+	_, err := r.s.exec(`
+		INSERT INTO emoji (team_id, name, alias_for, url, blob_key, content_type, created_at, updated_at, deleted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (team_id, name) DO UPDATE SET
+			alias_for = excluded.alias_for, url = excluded.url, blob_key = excluded.blob_key, content_type = excluded.content_type,
+			updated_at = excluded.updated_at, deleted_at = excluded.deleted_at`,
+		e.TeamID, e.Name, e.AliasFor, e.URL, e.BlobKey, e.ContentType,
+		toMicros(&e.CreatedAt), toMicros(&e.UpdatedAt), toMicros(e.DeletedAt),
+	)
+	return err
+}
+
+func (r emoji) Delete(teamID, name string, at time.Time) error {
+	res, err := r.s.exec(`UPDATE emoji SET deleted_at = ?, updated_at = ? WHERE team_id = ? AND name = ? AND deleted_at IS NULL`,
+		toMicros(&at), toMicros(&at), teamID, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
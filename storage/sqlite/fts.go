@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"strings"
+)
+
+// ftsQuery translates the web search syntax the API accepts, the same as
+// Postgres' websearch_to_tsquery, in to an FTS5 query:
+//
+//	foo bar          both words
+//	"foo bar"        the phrase
+//	foo or bar       either word
+//	-foo             without the word
+//
+// Every term is quoted so punctuation in the input can't be read as FTS5
+// syntax. An empty string is returned when nothing can be searched for.
+func ftsQuery(query string) string {
+	var (
+		groups   [][]string
+		excluded []string
+		or       bool
+	)
+
+	for _, token := range tokenize(query) {
+		switch {
+		case token.text == "":
+			continue
+		case !token.quoted && strings.EqualFold(token.text, "or"):
+			or = len(groups) > 0
+			continue
+		case token.negated:
+			excluded = append(excluded, quote(token.text))
+		case or:
+			last := len(groups) - 1
+			groups[last] = append(groups[last], quote(token.text))
+		default:
+			groups = append(groups, []string{quote(token.text)})
+		}
+		or = false
+	}
+
+	// FTS5 can't express "everything but", so a query made only of excluded
+	// words finds nothing, like it does in Postgres
+	if len(groups) == 0 {
+		return ""
+	}
+
+	var terms []string
+	for _, group := range groups {
+		if len(group) == 1 {
+			terms = append(terms, group[0])
+		} else {
+			terms = append(terms, "("+strings.Join(group, " OR ")+")")
+		}
+	}
+
+	fts := "(" + strings.Join(terms, " AND ") + ")"
+	for _, term := range excluded {
+		fts += " NOT " + term
+	}
+	return fts
+}
+
+type ftsToken struct {
+	text    string
+	quoted  bool
+	negated bool
+}
+
+func tokenize(query string) []ftsToken {
+	var tokens []ftsToken
+
+	for i := 0; i < len(query); {
+		if query[i] == ' ' || query[i] == '\t' || query[i] == '\n' {
+			i++
+			continue
+		}
+
+		var token ftsToken
+		if query[i] == '-' {
+			token.negated = true
+			i++
+		}
+
+		if i < len(query) && query[i] == '"' {
+			token.quoted = true
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				token.text = query[i+1:]
+				i = len(query)
+			} else {
+				token.text = query[i+1 : i+1+end]
+				i += end + 2
+			}
+		} else {
+			end := strings.IndexAny(query[i:], " \t\n")
+			if end < 0 {
+				end = len(query) - i
+			}
+			token.text = query[i : i+end]
+			i += end
+		}
+
+		token.text = strings.TrimSpace(token.text)
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+func quote(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
@@ -0,0 +1,36 @@
+package sqlite
+
+import "testing"
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"foo", `("foo")`},
+		{"foo bar", `("foo" AND "bar")`},
+		{`"foo bar"`, `("foo bar")`},
+		{`"foo bar`, `("foo bar")`},
+		{"foo or bar", `(("foo" OR "bar"))`},
+		{"foo OR bar baz", `(("foo" OR "bar") AND "baz")`},
+		{"foo or bar or baz", `(("foo" OR "bar" OR "baz"))`},
+		{"or foo", `("foo")`},
+		{"foo or", `("foo")`},
+		{`"or" foo`, `("or" AND "foo")`},
+		{"foo -bar", `("foo") NOT "bar"`},
+		{`foo -"bar baz"`, `("foo") NOT "bar baz"`},
+		{"-foo", ""},
+		{"-", ""},
+		// FTS5 syntax is only ever text
+		{`foo* NEAR(a b) col:x ^start`, `("foo*" AND "NEAR(a" AND "b)" AND "col:x" AND "^start")`},
+		{`AND NOT`, `("AND" AND "NOT")`},
+		{`say "hi""`, `("say" AND "hi")`},
+		{`a"b`, `("a""b")`},
+	}
+	for _, tt := range tests {
+		if got := ftsQuery(tt.query); got != tt.want {
+			t.Errorf("ftsQuery(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
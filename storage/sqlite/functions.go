@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// driverName is go-sqlite3 with the functions exact and fuzzy search need,
+// which Postgres has built in or gets from pg_trgm.
+const driverName = "sqlite3_slackarchive"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// X REGEXP Y calls regexp(Y, X)
+			if err := conn.RegisterFunc("regexp", regexpMatch, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("word_similarity", wordSimilarity, true)
+		},
+	})
+}
+
+var regexpCache sync.Map
+
+func regexpMatch(pattern, s string) (bool, error) {
+	if re, ok := regexpCache.Load(pattern); ok {
+		return re.(*regexp.Regexp).MatchString(s), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	regexpCache.Store(pattern, re)
+	return re.MatchString(s), nil
+}
+
+// wordSimilarity is close to pg_trgm's word_similarity: the largest share
+// of the trigrams of term found in a single word of text.
+func wordSimilarity(term, text string) float64 {
+	want := trigrams(term)
+	if len(want) == 0 {
+		return 0
+	}
+
+	var best float64
+	for _, word := range words(text) {
+		have := map[string]bool{}
+		for _, t := range trigrams(word) {
+			have[t] = true
+		}
+		found := 0
+		for _, t := range want {
+			if have[t] {
+				found++
+			}
+		}
+		if s := float64(found) / float64(len(want)); s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// trigrams of the words of s, lower cased and padded like pg_trgm does.
+func trigrams(s string) []string {
+	seen := map[string]bool{}
+	var all []string
+	for _, word := range words(s) {
+		padded := []rune("  " + strings.ToLower(word) + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			if t := string(padded[i : i+3]); !seen[t] {
+				seen[t] = true
+				all = append(all, t)
+			}
+		}
+	}
+	return all
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type legalHolds struct {
+	s *Store
+}
+
+const legalHoldColumns = `id, name, description, user_ids, channel_ids, posted_from, posted_to, created_by, created_at, released_by, released_at`
+
+func scanLegalHold(row scanner) (*models.LegalHold, error) {
+	var (
+		h                               models.LegalHold
+		from, to, createdAt, releasedAt sql.NullInt64
+	)
+	err := row.Scan(&h.ID, &h.Name, &h.Description, jsonColumn{&h.UserIDs}, jsonColumn{&h.ChannelIDs}, &from, &to,
+		&h.CreatedBy, &createdAt, &h.ReleasedBy, &releasedAt)
+	if err != nil {
+		return nil, err
+	}
+	h.PostedFrom = fromMicros(from)
+	h.PostedTo = fromMicros(to)
+	h.CreatedAt = *fromMicros(createdAt)
+	h.ReleasedAt = fromMicros(releasedAt)
+	return &h, nil
+}
+
+func (r legalHolds) Get(id int64) (*models.LegalHold, error) {
+	h, err := scanLegalHold(r.s.queryRow(`SELECT `+legalHoldColumns+` FROM legal_holds WHERE id = ?`, id))
+	return h, notFound(err)
+}
+
+func (r legalHolds) List(released bool) ([]models.LegalHold, error) {
+	query := `SELECT ` + legalHoldColumns + ` FROM legal_holds`
+	if !released {
+		query += ` WHERE released_at IS NULL`
+	}
+	rows, err := r.s.query(query + ` ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []models.LegalHold{}
+	for rows.Next() {
+		h, err := scanLegalHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *h)
+	}
+	return holds, rows.Err()
+}
+
+func (r legalHolds) Create(h *models.LegalHold) error {
+	res, err := r.s.exec(`
+		INSERT INTO legal_holds (name, description, user_ids, channel_ids, posted_from, posted_to, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		h.Name, h.Description, jsonColumn{h.UserIDs}, jsonColumn{h.ChannelIDs}, toMicros(h.PostedFrom), toMicros(h.PostedTo),
+		h.CreatedBy, toMicros(&h.CreatedAt),
+	)
+	if err != nil {
+		return err
+	}
+	h.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r legalHolds) Release(id int64, by string, at time.Time) error {
+	res, err := r.s.exec(`UPDATE legal_holds SET released_by = ?, released_at = ? WHERE id = ? AND released_at IS NULL`,
+		by, toMicros(&at), id)
+	return affected(res, err)
+}
+
+func (r legalHolds) Messages(h *models.LegalHold, pager storage.Pager) ([]storage.HeldMessage, error) {
+	where, args := []string{"1"}, []interface{}{}
+	if len(h.ChannelIDs) > 0 {
+		where = append(where, `channel_id IN (`+placeholders(len(h.ChannelIDs))+`)`)
+		for _, id := range h.ChannelIDs {
+			args = append(args, id)
+		}
+	}
+	if len(h.UserIDs) > 0 {
+		where = append(where, `user_id IN (`+placeholders(len(h.UserIDs))+`)`)
+		for _, id := range h.UserIDs {
+			args = append(args, id)
+		}
+	}
+	if h.PostedFrom != nil {
+		where = append(where, `"timestamp" >= ?`)
+		args = append(args, toMicros(h.PostedFrom))
+	}
+	if h.PostedTo != nil {
+		where = append(where, `"timestamp" <= ?`)
+		args = append(args, toMicros(h.PostedTo))
+	}
+
+	rows, err := r.s.query(`
+		SELECT `+messageColumns+`,
+			(SELECT count(*) FROM message_revisions AS r WHERE r.channel_id = messages.channel_id AND r."timestamp" = messages."timestamp")
+		FROM messages WHERE `+strings.Join(where, ` AND `)+`
+		ORDER BY "timestamp", channel_id LIMIT ? OFFSET ?`,
+		append(args, pager.Limit, pager.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []storage.HeldMessage{}
+	for rows.Next() {
+		var revisions int
+		m, err := scanMessage(rows, &revisions)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, storage.HeldMessage{Message: *m, Revisions: revisions})
+	}
+	return list, rows.Err()
+}
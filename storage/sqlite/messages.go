@@ -0,0 +1,438 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	errwrap "github.com/pkg/errors"
+	"github.com/slack-go/slack"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type messages struct {
+	s *Store
+}
+
+func (r messages) insert(m *models.Message, onConflict string) error {
+	archivedAt := m.ArchivedAt
+	if archivedAt == nil {
+		now := time.Now()
+		archivedAt = &now
+	}
+	_, err := r.s.exec(`
+		INSERT INTO messages (channel_id, user_id, "timestamp", thread_timestamp, msg, archived_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (channel_id, user_id, "timestamp") `+onConflict,
+		m.ChannelID, m.UserID, toMicros(m.Timestamp), toMicros(m.ThreadTimestamp), jsonColumn{m.Msg}, toMicros(archivedAt),
+	)
+	return err
+}
+
+func (r messages) Create(m *models.Message) error {
+	return r.insert(m, `DO NOTHING`)
+}
+
+func (r messages) Upsert(m *models.Message) error {
+	return r.insert(m, `DO UPDATE SET thread_timestamp = excluded.thread_timestamp, msg = excluded.msg`)
+}
+
+const messageColumns = `channel_id, user_id, "timestamp", thread_timestamp, msg, deleted_at, archived_at`
+
+func scanMessage(row scanner, extra ...interface{}) (*models.Message, error) {
+	var (
+		m                                   models.Message
+		ts, threadTs, deletedAt, archivedAt sql.NullInt64
+	)
+	m.Msg = &slack.Msg{}
+	dest := append([]interface{}{&m.ChannelID, &m.UserID, &ts, &threadTs, jsonColumn{m.Msg}, &deletedAt, &archivedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	m.Timestamp = fromMicros(ts)
+	m.ThreadTimestamp = fromMicros(threadTs)
+	m.DeletedAt = fromMicros(deletedAt)
+	m.ArchivedAt = fromMicros(archivedAt)
+	return &m, nil
+}
+
+func (r messages) Get(channelID string, ts time.Time) (*models.Message, error) {
+	m, err := scanMessage(r.s.queryRow(`SELECT `+messageColumns+` FROM messages WHERE channel_id = ? AND "timestamp" = ? LIMIT 1`,
+		channelID, toMicros(&ts)))
+	return m, notFound(err)
+}
+
+func (r messages) List(after *storage.Cursor, limit int) ([]models.Message, error) {
+	return r.list(nil, nil, after, limit)
+}
+
+func (r messages) Discover(query storage.DiscoveryQuery, after *storage.Cursor, limit int) ([]models.Message, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if len(query.UserIDs) > 0 {
+		where = append(where, `user_id IN (`+placeholders(len(query.UserIDs))+`)`)
+		for _, id := range query.UserIDs {
+			args = append(args, id)
+		}
+	}
+	if len(query.ChannelIDs) > 0 {
+		where = append(where, `channel_id IN (`+placeholders(len(query.ChannelIDs))+`)`)
+		for _, id := range query.ChannelIDs {
+			args = append(args, id)
+		}
+	}
+	if query.Keywords != "" {
+		match := ftsQuery(query.Keywords)
+		if match == "" {
+			return nil, nil
+		}
+		where = append(where, `rowid IN (SELECT rowid FROM messages_fts WHERE messages_fts MATCH ?)`)
+		args = append(args, match)
+	}
+	if query.From != nil {
+		where = append(where, `"timestamp" >= ?`)
+		args = append(args, toMicros(query.From))
+	}
+	if query.To != nil {
+		where = append(where, `"timestamp" <= ?`)
+		args = append(args, toMicros(query.To))
+	}
+	return r.list(where, args, after, limit)
+}
+
+// list pages through the messages matching the where conditions, oldest
+// first.
+func (r messages) list(where []string, args []interface{}, after *storage.Cursor, limit int) ([]models.Message, error) {
+	if after != nil {
+		where = append(where, `("timestamp" > ? OR ("timestamp" = ? AND channel_id > ?))`)
+		ts := toMicros(&after.Timestamp)
+		args = append(args, ts, ts, after.ChannelID)
+	}
+	query := `SELECT ` + messageColumns + ` FROM messages`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY "timestamp", channel_id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *m)
+	}
+	return messages, rows.Err()
+}
+
+func (r messages) Tombstone(channelID string, ts, at time.Time) error {
+	res, err := r.s.exec(`UPDATE messages SET deleted_at = ? WHERE channel_id = ? AND "timestamp" = ? AND deleted_at IS NULL`,
+		toMicros(&at), channelID, toMicros(&ts))
+	return affected(res, err)
+}
+
+func (r messages) AddRevision(rev *models.MessageRevision) error {
+	res, err := r.s.exec(`
+		INSERT INTO message_revisions (channel_id, user_id, "timestamp", msg, replaced_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		rev.ChannelID, rev.UserID, toMicros(&rev.Timestamp), jsonColumn{rev.Msg}, toMicros(&rev.ReplacedAt),
+	)
+	if err != nil {
+		return err
+	}
+	rev.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r messages) Delete(channelID string, ts time.Time) error {
+	_, err := r.s.exec(`DELETE FROM messages WHERE channel_id = ? AND "timestamp" = ?`, channelID, toMicros(&ts))
+	return err
+}
+
+// Reindex rebuilds the FTS5 index. Every message is indexed the same way,
+// so without all there's never anything to do.
+func (r messages) Reindex(all bool) (int, error) {
+	if !all {
+		return 0, nil
+	}
+
+	tx, err := r.s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	defer r.s.logQuery(reindexFTS, nil, time.Now())
+	if _, err := tx.Exec(`DELETE FROM messages_fts`); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(reindexFTS)
+	if err != nil {
+		return 0, errwrap.Wrap(err, "Error reindexing messages")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+func (r messages) Search(query storage.MessageQuery) (*storage.MessageResult, error) {
+	result := &storage.MessageResult{
+		Buckets: map[string]int64{},
+	}
+
+	k, err := query.Keyset()
+	if err != nil {
+		return nil, err
+	}
+
+	from := `messages`
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	fullText := query.Query != "" && query.Mode == storage.SearchFullText
+	var terms []storage.SubstringTerm
+	if fullText {
+		match := ftsQuery(query.Query)
+		if match == "" {
+			return result, nil
+		}
+		from += ` JOIN messages_fts ON messages_fts.rowid = messages.rowid`
+		where = append(where, `messages_fts MATCH ?`)
+		args = append(args, match)
+	} else if query.Query != "" {
+		if terms, err = storage.ParseSubstringQuery(query.Query); err != nil {
+			return nil, err
+		} else if len(terms) == 0 {
+			return result, nil
+		}
+		for _, t := range terms {
+			cond, condArgs := substringCondition(t, query.Mode == storage.SearchFuzzy)
+			where = append(where, cond)
+			args = append(args, condArgs...)
+		}
+	}
+
+	// Shared Enterprise Grid channels are visible from every team they're in
+	where = append(where, `EXISTS (SELECT 1 FROM channel_teams AS ct WHERE ct.channel_id = messages.channel_id AND ct.team_id = ?)`)
+	args = append(args, query.TeamID)
+
+	if query.ChannelID != "" {
+		where = append(where, `messages.channel_id = ?`)
+		args = append(args, query.ChannelID)
+	}
+
+	if query.From != nil {
+		where = append(where, `messages."timestamp" >= ?`)
+		args = append(args, toMicros(query.From))
+	}
+	if query.To != nil {
+		where = append(where, `messages."timestamp" <= ?`)
+		args = append(args, toMicros(query.To))
+	}
+	if query.ArchivedAfter != nil {
+		where = append(where, `messages.archived_at > ?`)
+		args = append(args, toMicros(query.ArchivedAfter))
+	}
+
+	where = append(where,
+		`messages.deleted_at IS NULL`,
+		`coalesce(json_extract(messages.msg, '$.hidden'), 0) = 0`,
+		`coalesce(json_extract(messages.msg, '$.subtype'), '') NOT IN ('message_changed', 'message_deleted', 'channel_join', 'channel_leave', 'pinned_item')`,
+	)
+
+	body := ` FROM ` + from + ` WHERE ` + strings.Join(where, ` AND `)
+
+	if query.Aggregate {
+		rows, err := r.s.query(`SELECT messages.channel_id, count(*)`+body+` GROUP BY messages.channel_id`, args...)
+		if err != nil {
+			return nil, errwrap.Wrap(err, "Error performing aggregate search")
+		}
+		for rows.Next() {
+			var (
+				channelID string
+				matches   int64
+			)
+			if err := rows.Scan(&channelID, &matches); err != nil {
+				rows.Close()
+				return nil, errwrap.Wrap(err, "Error performing aggregate search")
+			}
+			result.Buckets[channelID] = matches
+		}
+		rows.Close()
+	}
+
+	if !query.NoCount {
+		if err := r.s.queryRow(`SELECT count(*)`+body, args...).Scan(&result.TotalCount); err != nil {
+			return nil, errwrap.Wrap(err, "Error counting messages")
+		}
+	}
+
+	offset := query.Offset
+	if k.Cursor != nil {
+		// The total counts the matches on every page, not just those past
+		// the cursor
+		body += ` AND (messages."timestamp", messages.channel_id) ` + k.Op + ` (?, ?)`
+		args = append(args, toMicros(&k.Cursor.Timestamp), k.Cursor.ChannelID)
+		offset = 0
+	}
+
+	columns := `NULL, NULL, 0 AS score`
+	var columnArgs []interface{}
+	if terms != nil {
+		columns, columnArgs = substringScoreColumn(terms, query.Mode == storage.SearchFuzzy)
+		columns = `NULL, NULL, ` + columns
+	} else if fullText {
+		// bm25 is negative, lower is better. The text is weighted above
+		// titles, links and file names, and those above the rest of the
+		// body, like the A, B and C weights of the Postgres index.
+		columns = `highlight(messages_fts, 0, '[hl]', '[/hl]'), highlight(messages_fts, 1, '[hl]', '[/hl]'),
+			-bm25(messages_fts, 1.0, 0.4, 0.4, 0.4, 0.2) * (1 + ? / (? + max(0, ? - messages."timestamp") / 1000000.0)) AS score`
+		scale := storage.RecencyScale.Seconds()
+		now := time.Now()
+		columnArgs = []interface{}{scale, scale, toMicros(&now)}
+	}
+
+	order := ` ORDER BY messages."timestamp" ASC, messages.channel_id ASC`
+	if k.Descending {
+		order = ` ORDER BY messages."timestamp" DESC, messages.channel_id DESC`
+	}
+	if query.Relevance && query.Query != "" {
+		order = ` ORDER BY score DESC,` + strings.TrimPrefix(order, ` ORDER BY`)
+	}
+
+	// One more than asked for tells Page whether there's another page
+	limit := query.Limit
+	if limit > 0 {
+		limit++
+	}
+	args = append(append(columnArgs, args...), limit, offset)
+	rows, err := r.s.query(`SELECT messages.channel_id, messages.user_id, messages."timestamp", messages.thread_timestamp, messages.msg, `+columns+
+		body+order+` LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "Error selecting messages")
+	}
+
+	var userIDs []string
+	for rows.Next() {
+		var (
+			m            models.Message
+			ts, threadTs sql.NullInt64
+			text, titles sql.NullString
+		)
+		m.Msg = &slack.Msg{}
+		if err := rows.Scan(&m.ChannelID, &m.UserID, &ts, &threadTs, jsonColumn{m.Msg}, &text, &titles, &m.Score); err != nil {
+			rows.Close()
+			return nil, errwrap.Wrap(err, "Error selecting messages")
+		}
+		m.Timestamp = fromMicros(ts)
+		m.ThreadTimestamp = fromMicros(threadTs)
+		if text.Valid {
+			m.Msg.Text = text.String
+		} else if terms != nil {
+			m.Msg.Text = storage.HighlightSubstrings(m.Msg.Text, terms)
+		}
+		if titles.Valid {
+			highlightTitles(m.Msg, titles.String)
+		}
+		result.Messages = append(result.Messages, m)
+		userIDs = append(userIDs, m.UserID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errwrap.Wrap(err, "Error selecting messages")
+	}
+
+	// With a single connection the users can only be loaded once the
+	// messages have been read
+	users, err := users{r.s}.GetMany(userIDs)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "Error selecting messages")
+	}
+	byID := map[string]*models.User{}
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	for i := range result.Messages {
+		result.Messages[i].User = byID[result.Messages[i].UserID]
+	}
+
+	query.Page(result, k)
+	return result, nil
+}
+
+// messageText is the text of the message, empty rather than NULL for the
+// functions in functions.go
+const messageText = `coalesce(json_extract(messages.msg, '$.text'), '')`
+
+// substringCondition matches a term of an exact or fuzzy search against the
+// message text. Fuzzy terms also match words at least
+// storage.FuzzyThreshold similar.
+func substringCondition(t storage.SubstringTerm, fuzzy bool) (string, []interface{}) {
+	cond, args := messageText+` LIKE ? ESCAPE '\'`, []interface{}{t.LikePattern()}
+	if t.Regexp {
+		cond, args = messageText+` REGEXP ?`, []interface{}{"(?i)" + t.Text}
+	} else if fuzzy && t.Fuzzy() {
+		cond = `(` + cond + ` OR word_similarity(?, ` + messageText + `) >= ?)`
+		args = append(args, t.Text, storage.FuzzyThreshold)
+	}
+	if t.Negated {
+		cond = `NOT ` + cond
+	}
+	return cond, args
+}
+
+// substringScoreColumn scores exact and fuzzy matches like Postgres does:
+// every term that's there counts one, a fuzzy one its similarity.
+func substringScoreColumn(terms []storage.SubstringTerm, fuzzy bool) (string, []interface{}) {
+	var (
+		parts []string
+		args  []interface{}
+	)
+	for _, t := range terms {
+		switch {
+		case t.Negated:
+		case fuzzy && t.Fuzzy():
+			parts = append(parts, `CASE WHEN `+messageText+` LIKE ? ESCAPE '\' THEN 1 ELSE word_similarity(?, `+messageText+`) END`)
+			args = append(args, t.LikePattern(), t.Text)
+		default:
+			parts = append(parts, `1`)
+		}
+	}
+	if len(parts) == 0 {
+		parts = []string{`1`}
+	}
+	scale := storage.RecencyScale.Seconds()
+	now := time.Now()
+	args = append(args, scale, scale, toMicros(&now))
+	return `(` + strings.Join(parts, ` + `) + `) * ` +
+		`(1 + ? / (? + max(0, ? - messages."timestamp") / 1000000.0)) AS score`, args
+}
+
+// highlightTitles copies the highlighted attachment titles, one per line, on
+// to the attachments of msg.
+func highlightTitles(msg *slack.Msg, titles string) {
+	lines := strings.Split(titles, "\n")
+	if len(lines) != len(msg.Attachments) {
+		return
+	}
+	for i := range msg.Attachments {
+		if msg.Attachments[i].Title != "" {
+			msg.Attachments[i].Title = lines[i]
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type pins struct {
+	s *Store
+}
+
+func (r pins) List(channelID string, removed bool) ([]models.Pin, error) {
+	query := `SELECT channel_id, item_id, type, item, pinned_by, pinned_at, removed_at FROM pins WHERE channel_id = ?`
+	if !removed {
+		query += ` AND removed_at IS NULL`
+	}
+	rows, err := r.s.query(query+` ORDER BY pinned_at DESC`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Pin{}
+	for rows.Next() {
+		var (
+			pin                 models.Pin
+			pinnedAt, removedAt sql.NullInt64
+		)
+		if err := rows.Scan(&pin.ChannelID, &pin.ItemID, &pin.Type, jsonColumn{&pin.Item}, &pin.PinnedBy, &pinnedAt, &removedAt); err != nil {
+			return nil, err
+		}
+		pin.PinnedAt = *fromMicros(pinnedAt)
+		pin.RemovedAt = fromMicros(removedAt)
+		list = append(list, pin)
+	}
+	return list, rows.Err()
+}
+
+func (r pins) Upsert(pin *models.Pin) error {
+	_, err := r.s.exec(`
+		INSERT INTO pins (channel_id, item_id, type, item, pinned_by, pinned_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (channel_id, item_id) DO UPDATE SET
+			type = excluded.type, item = excluded.item,
+			pinned_by = CASE WHEN removed_at IS NULL THEN pinned_by ELSE excluded.pinned_by END,
+			pinned_at = CASE WHEN removed_at IS NULL THEN pinned_at ELSE excluded.pinned_at END,
+			removed_at = NULL`,
+		pin.ChannelID, pin.ItemID, pin.Type, jsonColumn{pin.Item}, pin.PinnedBy, toMicros(&pin.PinnedAt),
+	)
+	return err
+}
+
+func (r pins) Remove(channelID, itemID string, at time.Time) error {
+	res, err := r.s.exec(`UPDATE pins SET removed_at = ? WHERE channel_id = ? AND item_id = ? AND removed_at IS NULL`,
+		toMicros(&at), channelID, itemID)
+	return affected(res, err)
+}
+
+type bookmarks struct {
+	s *Store
+}
+
+func (r bookmarks) List(channelID string, removed bool) ([]models.Bookmark, error) {
+	query := `SELECT id, channel_id, title, link, emoji, icon_url, type, rank, updated_by, created_at, updated_at, removed_at
+		FROM bookmarks WHERE channel_id = ?`
+	if !removed {
+		query += ` AND removed_at IS NULL`
+	}
+	rows, err := r.s.query(query+` ORDER BY rank, created_at`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Bookmark{}
+	for rows.Next() {
+		var (
+			b                               models.Bookmark
+			createdAt, updatedAt, removedAt sql.NullInt64
+		)
+		err := rows.Scan(&b.ID, &b.ChannelID, &b.Title, &b.Link, &b.Emoji, &b.IconURL, &b.Type, &b.Rank, &b.UpdatedBy,
+			&createdAt, &updatedAt, &removedAt)
+		if err != nil {
+			return nil, err
+		}
+		b.CreatedAt = *fromMicros(createdAt)
+		b.UpdatedAt = *fromMicros(updatedAt)
+		b.RemovedAt = fromMicros(removedAt)
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+func (r bookmarks) Upsert(b *models.Bookmark) error {
+	_, err := r.s.exec(`
+		INSERT INTO bookmarks (id, channel_id, title, link, emoji, icon_url, type, rank, updated_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			channel_id = excluded.channel_id, title = excluded.title, link = excluded.link, emoji = excluded.emoji,
+			icon_url = excluded.icon_url, type = excluded.type, rank = excluded.rank, updated_by = excluded.updated_by,
+			created_at = excluded.created_at, updated_at = excluded.updated_at, removed_at = NULL`,
+		b.ID, b.ChannelID, b.Title, b.Link, b.Emoji, b.IconURL, b.Type, b.Rank, b.UpdatedBy,
+		toMicros(&b.CreatedAt), toMicros(&b.UpdatedAt),
+	)
+	return err
+}
+
+func (r bookmarks) Remove(id string, at time.Time) error {
+	res, err := r.s.exec(`UPDATE bookmarks SET removed_at = ? WHERE id = ? AND removed_at IS NULL`, toMicros(&at), id)
+	return affected(res, err)
+}
+
+// affected turns updates that found nothing to update in to ErrNotFound.
+func affected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type purges struct {
+	s *Store
+}
+
+func (r purges) Messages(channelID string, before time.Time, holds []models.LegalHold, limit int) (int, *time.Time, *time.Time, error) {
+	tx, err := r.s.db.Begin()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		n              int
+		oldest, newest sql.NullInt64
+	)
+	held, heldArgs := heldCondition(holds)
+	purgeBatch := `SELECT rowid, "timestamp" FROM messages WHERE channel_id = ? AND "timestamp" < ? AND NOT ` + held +
+		` ORDER BY "timestamp" LIMIT ?`
+	args := append(append([]interface{}{channelID, toMicros(&before)}, heldArgs...), limit)
+	query := `SELECT count(*), min("timestamp"), max("timestamp") FROM (` + purgeBatch + `)`
+	started := time.Now()
+	err = tx.QueryRow(query, args...).Scan(&n, &oldest, &newest)
+	r.s.logQuery(query, args, started)
+	if err != nil || n == 0 {
+		return 0, nil, nil, err
+	}
+
+	query = `DELETE FROM messages WHERE rowid IN (SELECT rowid FROM (` + purgeBatch + `))`
+	started = time.Now()
+	_, err = tx.Exec(query, args...)
+	r.s.logQuery(query, args, started)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return n, fromMicros(oldest), fromMicros(newest), tx.Commit()
+}
+
+// heldCondition matches the messages any of holds covers.
+func heldCondition(holds []models.LegalHold) (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for i := range holds {
+		h := &holds[i]
+		if !h.Active() {
+			continue
+		}
+		cond := []string{"1"}
+		if len(h.ChannelIDs) > 0 {
+			cond = append(cond, "channel_id IN ("+placeholders(len(h.ChannelIDs))+")")
+			for _, id := range h.ChannelIDs {
+				args = append(args, id)
+			}
+		}
+		if len(h.UserIDs) > 0 {
+			cond = append(cond, "user_id IN ("+placeholders(len(h.UserIDs))+")")
+			for _, id := range h.UserIDs {
+				args = append(args, id)
+			}
+		}
+		if h.PostedFrom != nil {
+			cond = append(cond, `"timestamp" >= ?`)
+			args = append(args, toMicros(h.PostedFrom))
+		}
+		if h.PostedTo != nil {
+			cond = append(cond, `"timestamp" <= ?`)
+			args = append(args, toMicros(h.PostedTo))
+		}
+		conds = append(conds, "("+strings.Join(cond, " AND ")+")")
+	}
+	if len(conds) == 0 {
+		return "0", nil
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+func (r purges) Pins(channelID string, before time.Time) (int, error) {
+	res, err := r.s.exec(`
+		DELETE FROM pins
+		WHERE channel_id = ? AND CASE type
+			WHEN 'message' THEN CAST(item_id AS REAL) * 1000000
+			WHEN 'file' THEN coalesce(nullif(json_extract(item, '$.file.created'), 0) * 1000000, pinned_at)
+			ELSE pinned_at
+		END < ?`,
+		channelID, toMicros(&before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r purges) Bookmarks(channelID string, before time.Time) (int, error) {
+	res, err := r.s.exec(`DELETE FROM bookmarks WHERE channel_id = ? AND created_at < ?`, channelID, toMicros(&before))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r purges) Deleted(holds []models.LegalHold) (int, error) {
+	held, args := heldCondition(holds)
+	res, err := r.s.exec(`DELETE FROM messages WHERE deleted_at IS NOT NULL AND NOT `+held, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r purges) Add(p *models.Purge) error {
+	res, err := r.s.exec(`
+		INSERT INTO purges (team_id, channel_id, policy, before, messages, pins, bookmarks, oldest, newest, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.TeamID, p.ChannelID, p.Policy, toMicros(&p.Before), p.Messages, p.Pins, p.Bookmarks, toMicros(p.Oldest), toMicros(p.Newest),
+		toMicros(&p.StartedAt), toMicros(&p.FinishedAt),
+	)
+	if err != nil {
+		return err
+	}
+	p.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r purges) List(pager storage.Pager) ([]models.Purge, int, error) {
+	var count int
+	if err := r.s.queryRow(`SELECT count(*) FROM purges`).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.s.query(`
+		SELECT id, team_id, channel_id, policy, before, messages, pins, bookmarks, oldest, newest, started_at, finished_at
+		FROM purges ORDER BY id DESC LIMIT ? OFFSET ?`,
+		pager.Limit, pager.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	list := []models.Purge{}
+	for rows.Next() {
+		var (
+			p                                             models.Purge
+			before, oldest, newest, startedAt, finishedAt sql.NullInt64
+		)
+		err := rows.Scan(&p.ID, &p.TeamID, &p.ChannelID, &p.Policy, &before, &p.Messages, &p.Pins, &p.Bookmarks, &oldest, &newest,
+			&startedAt, &finishedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		p.Before = *fromMicros(before)
+		p.Oldest = fromMicros(oldest)
+		p.Newest = fromMicros(newest)
+		p.StartedAt = *fromMicros(startedAt)
+		p.FinishedAt = *fromMicros(finishedAt)
+		list = append(list, p)
+	}
+	return list, count, rows.Err()
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type savedSearches struct {
+	s *Store
+}
+
+const savedSearchColumns = `id, user_id, team_id, name, query, mode, coalesce(channel_id, ''), notify, created_at, last_seen_at, last_notified_at`
+
+func scanSavedSearch(row scanner) (*models.SavedSearch, error) {
+	var (
+		search                          models.SavedSearch
+		created, lastSeen, lastNotified sql.NullInt64
+	)
+	err := row.Scan(&search.ID, &search.UserID, &search.TeamID, &search.Name, &search.Query, &search.Mode, &search.ChannelID, &search.Notify,
+		&created, &lastSeen, &lastNotified)
+	if err != nil {
+		return nil, err
+	}
+	search.CreatedAt = *fromMicros(created)
+	search.LastSeenAt = *fromMicros(lastSeen)
+	search.LastNotifiedAt = fromMicros(lastNotified)
+	return &search, nil
+}
+
+func (r savedSearches) Get(id int64) (*models.SavedSearch, error) {
+	search, err := scanSavedSearch(r.s.queryRow(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = ?`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return search, nil
+}
+
+func (r savedSearches) list(where string, arg interface{}) ([]models.SavedSearch, error) {
+	rows, err := r.s.query(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE `+where+` ORDER BY id`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searches []models.SavedSearch
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, *search)
+	}
+	return searches, rows.Err()
+}
+
+func (r savedSearches) List(userID string) ([]models.SavedSearch, error) {
+	return r.list(`user_id = ?`, userID)
+}
+
+func (r savedSearches) ListNotifying(teamID string) ([]models.SavedSearch, error) {
+	return r.list(`team_id = ? AND notify`, teamID)
+}
+
+func (r savedSearches) Create(search *models.SavedSearch) error {
+	res, err := r.s.exec(`
+		INSERT INTO saved_searches (user_id, team_id, name, query, mode, channel_id, notify, created_at, last_seen_at, last_notified_at)
+		VALUES (?, ?, ?, ?, ?, nullif(?, ''), ?, ?, ?, ?)`,
+		search.UserID, search.TeamID, search.Name, search.Query, search.Mode, search.ChannelID, search.Notify,
+		toMicros(&search.CreatedAt), toMicros(&search.LastSeenAt), toMicros(search.LastNotifiedAt),
+	)
+	if err != nil {
+		return err
+	}
+	search.ID, err = res.LastInsertId()
+	return err
+}
+
+func (r savedSearches) Update(search *models.SavedSearch) error {
+	_, err := r.s.exec(`
+		UPDATE saved_searches SET
+			user_id = ?, team_id = ?, name = ?, query = ?, mode = ?, channel_id = nullif(?, ''), notify = ?,
+			created_at = ?, last_seen_at = ?, last_notified_at = ?
+		WHERE id = ?`,
+		search.UserID, search.TeamID, search.Name, search.Query, search.Mode, search.ChannelID, search.Notify,
+		toMicros(&search.CreatedAt), toMicros(&search.LastSeenAt), toMicros(search.LastNotifiedAt),
+		search.ID,
+	)
+	return err
+}
+
+func (r savedSearches) Delete(id int64) error {
+	res, err := r.s.exec(`DELETE FROM saved_searches WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
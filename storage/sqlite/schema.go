@@ -0,0 +1,550 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migrations are applied in order, the schema version is kept in SQLite's
+// user_version. Never edit a released migration, append a new one.
+var migrations = []string{
+	// 1: the schema of Postgres migrations 1 to 3
+	`
+	CREATE TABLE teams (
+		id text NOT NULL PRIMARY KEY,
+		name text,
+		domain text,
+		token text,
+		is_disabled boolean NOT NULL DEFAULT false,
+		is_hidden boolean NOT NULL DEFAULT false,
+		plan text,
+		icon text,
+		enterprise_id text,
+		enterprise_name text
+	);
+
+	CREATE INDEX teams_idx_domain ON teams (domain);
+
+	CREATE TABLE users (
+		id text NOT NULL PRIMARY KEY,
+		name text NOT NULL DEFAULT '',
+		team_id text NOT NULL REFERENCES teams(id),
+		enterprise_id text,
+		deleted boolean NOT NULL DEFAULT false,
+		color text,
+		profile text,
+		is_bot boolean NOT NULL DEFAULT false,
+		is_admin boolean NOT NULL DEFAULT false,
+		is_owner boolean NOT NULL DEFAULT false,
+		is_primary_owner boolean NOT NULL DEFAULT false,
+		is_restricted boolean NOT NULL DEFAULT false,
+		is_ultra_restricted boolean NOT NULL DEFAULT false,
+		has_files boolean NOT NULL DEFAULT false,
+		presence text,
+		is_external boolean NOT NULL DEFAULT false,
+		home_team_id text,
+		home_team_name text
+	);
+
+	CREATE INDEX users_idx_team ON users (team_id);
+
+	CREATE TABLE channels (
+		id text NOT NULL PRIMARY KEY,
+		name text NOT NULL,
+		team_id text NOT NULL REFERENCES teams(id),
+		is_channel boolean NOT NULL DEFAULT false,
+		creator_id text,
+		is_archived boolean NOT NULL DEFAULT false,
+		is_general boolean NOT NULL DEFAULT false,
+		is_group boolean NOT NULL DEFAULT false,
+		members text,
+		topic text,
+		purpose text,
+		is_member boolean NOT NULL DEFAULT false,
+		last_read text,
+		unread_count integer,
+		num_members integer NOT NULL DEFAULT 0,
+		unread_count_display integer,
+		is_shared boolean NOT NULL DEFAULT false,
+		is_org_shared boolean NOT NULL DEFAULT false,
+		is_ext_shared boolean NOT NULL DEFAULT false
+	);
+
+	CREATE TABLE channel_teams (
+		channel_id text NOT NULL REFERENCES channels(id),
+		team_id text NOT NULL REFERENCES teams(id),
+		PRIMARY KEY (channel_id, team_id)
+	);
+
+	CREATE INDEX channel_teams_idx_team ON channel_teams (team_id);
+
+	-- timestamps are microseconds since the epoch
+	CREATE TABLE messages (
+		channel_id text NOT NULL REFERENCES channels(id),
+		user_id text NOT NULL REFERENCES users(id),
+		"timestamp" integer NOT NULL,
+		thread_timestamp integer,
+		msg text,
+		PRIMARY KEY (channel_id, user_id, "timestamp")
+	);
+
+	CREATE INDEX messages_idx_timestamp ON messages ("timestamp");
+
+	-- The equivalent of the Postgres tsv column: message text weighted above
+	-- attachment titles and links. Rows share the rowid of their message.
+	CREATE VIRTUAL TABLE messages_fts USING fts5(text, attachments, tokenize = 'porter unicode61');
+
+	CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts (rowid, text, attachments) VALUES (
+			new.rowid,
+			coalesce(json_extract(new.msg, '$.text'), ''),
+			(SELECT group_concat(coalesce(json_extract(a.value, '$.title'), '') || ' ' || coalesce(json_extract(a.value, '$.title_link'), ''), ' ')
+				FROM json_each(new.msg, '$.attachments') AS a)
+		);
+	END;
+
+	CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.rowid;
+	END;
+
+	CREATE TRIGGER messages_fts_update AFTER UPDATE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.rowid;
+		INSERT INTO messages_fts (rowid, text, attachments) VALUES (
+			new.rowid,
+			coalesce(json_extract(new.msg, '$.text'), ''),
+			(SELECT group_concat(coalesce(json_extract(a.value, '$.title'), '') || ' ' || coalesce(json_extract(a.value, '$.title_link'), ''), ' ')
+				FROM json_each(new.msg, '$.attachments') AS a)
+		);
+	END;
+	`,
+
+	// 2: attachment titles get their own column, one line per attachment, so
+	// matches in them can be highlighted
+	`
+	DROP TRIGGER messages_fts_insert;
+	DROP TRIGGER messages_fts_delete;
+	DROP TRIGGER messages_fts_update;
+	DROP TABLE messages_fts;
+
+	CREATE VIRTUAL TABLE messages_fts USING fts5(text, titles, links, tokenize = 'porter unicode61');
+
+	CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts (rowid, text, titles, links) VALUES (
+			new.rowid,
+			coalesce(json_extract(new.msg, '$.text'), ''),
+			(SELECT group_concat(replace(coalesce(json_extract(a.value, '$.title'), ''), char(10), ' '), char(10))
+				FROM json_each(new.msg, '$.attachments') AS a),
+			(SELECT group_concat(coalesce(json_extract(a.value, '$.title_link'), ''), ' ')
+				FROM json_each(new.msg, '$.attachments') AS a)
+		);
+	END;
+
+	CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.rowid;
+	END;
+
+	CREATE TRIGGER messages_fts_update AFTER UPDATE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.rowid;
+		INSERT INTO messages_fts (rowid, text, titles, links) VALUES (
+			new.rowid,
+			coalesce(json_extract(new.msg, '$.text'), ''),
+			(SELECT group_concat(replace(coalesce(json_extract(a.value, '$.title'), ''), char(10), ' '), char(10))
+				FROM json_each(new.msg, '$.attachments') AS a),
+			(SELECT group_concat(coalesce(json_extract(a.value, '$.title_link'), ''), ' ')
+				FROM json_each(new.msg, '$.attachments') AS a)
+		);
+	END;
+
+	INSERT INTO messages_fts (rowid, text, titles, links)
+	SELECT
+		m.rowid,
+		coalesce(json_extract(m.msg, '$.text'), ''),
+		(SELECT group_concat(replace(coalesce(json_extract(a.value, '$.title'), ''), char(10), ' '), char(10))
+			FROM json_each(m.msg, '$.attachments') AS a),
+		(SELECT group_concat(coalesce(json_extract(a.value, '$.title_link'), ''), ' ')
+			FROM json_each(m.msg, '$.attachments') AS a)
+	FROM messages AS m;
+	`,
+
+	// 3: file names and the rest of the message content, block kit,
+	// attachment bodies and file previews, get columns of their own
+	`
+	DROP TRIGGER messages_fts_insert;
+	DROP TRIGGER messages_fts_delete;
+	DROP TRIGGER messages_fts_update;
+	DROP TABLE messages_fts;
+
+	CREATE VIRTUAL TABLE messages_fts USING fts5(text, titles, links, files, body, tokenize = 'porter unicode61');
+
+	CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts (rowid, text, titles, links, files, body) VALUES (` + ftsValues("new") + `);
+	END;
+
+	CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.rowid;
+	END;
+
+	CREATE TRIGGER messages_fts_update AFTER UPDATE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.rowid;
+		INSERT INTO messages_fts (rowid, text, titles, links, files, body) VALUES (` + ftsValues("new") + `);
+	END;
+
+	` + reindexFTS + `;
+	`,
+
+	// 4: saved searches, Postgres migration 7
+	`
+	CREATE TABLE saved_searches (
+		id integer PRIMARY KEY,
+		user_id text NOT NULL,
+		team_id text NOT NULL REFERENCES teams(id),
+		name text NOT NULL,
+		query text NOT NULL,
+		mode text NOT NULL DEFAULT '',
+		channel_id text REFERENCES channels(id) ON DELETE CASCADE,
+		notify boolean NOT NULL DEFAULT false,
+		created_at integer NOT NULL,
+		last_seen_at integer NOT NULL,
+		last_notified_at integer
+	);
+
+	CREATE INDEX saved_searches_idx_user ON saved_searches (user_id);
+	CREATE INDEX saved_searches_idx_team ON saved_searches (team_id) WHERE notify;
+	`,
+
+	// 5: indexes for cursor pagination, Postgres migration 8. The index on
+	// "timestamp" alone is the start of one of them, like Postgres' on
+	// channel_id of the other.
+	`
+	CREATE INDEX messages_idx_channel_timestamp ON messages (channel_id, "timestamp");
+	CREATE INDEX messages_idx_timestamp_channel ON messages ("timestamp", channel_id);
+	DROP INDEX messages_idx_timestamp;
+	`,
+
+	// 6: custom emoji, Postgres migration 9
+	`
+	CREATE TABLE emoji (
+		team_id text NOT NULL REFERENCES teams(id),
+		name text NOT NULL,
+		alias_for text NOT NULL DEFAULT '',
+		url text NOT NULL DEFAULT '',
+		blob_key text NOT NULL DEFAULT '',
+		content_type text NOT NULL DEFAULT '',
+		created_at integer NOT NULL,
+		updated_at integer NOT NULL,
+		deleted_at integer,
+		PRIMARY KEY (team_id, name)
+	);
+	`,
+
+	// 7: user profile history, Postgres migration 10
+	`
+	CREATE TABLE user_profile_history (
+		id integer PRIMARY KEY,
+		user_id text NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		change text NOT NULL,
+		name text NOT NULL,
+		deleted boolean NOT NULL DEFAULT false,
+		profile text,
+		changed_at integer NOT NULL
+	);
+
+	CREATE INDEX user_profile_history_idx_user ON user_profile_history (user_id, changed_at);
+	`,
+
+	// 8: channel timelines, Postgres migration 11
+	`
+	ALTER TABLE channels ADD COLUMN is_deleted boolean NOT NULL DEFAULT false;
+
+	CREATE TABLE channel_events (
+		id integer PRIMARY KEY,
+		channel_id text NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+		type text NOT NULL,
+		user_id text,
+		name text NOT NULL DEFAULT '',
+		old_name text NOT NULL DEFAULT '',
+		value text NOT NULL DEFAULT '',
+		"timestamp" integer NOT NULL
+	);
+
+	CREATE INDEX channel_events_idx_channel ON channel_events (channel_id, "timestamp");
+	`,
+
+	// 9: pins and bookmarks, Postgres migration 12
+	`
+	CREATE TABLE pins (
+		channel_id text NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+		item_id text NOT NULL,
+		type text NOT NULL,
+		item text,
+		pinned_by text NOT NULL DEFAULT '',
+		pinned_at integer NOT NULL,
+		removed_at integer,
+		PRIMARY KEY (channel_id, item_id)
+	);
+
+	CREATE TABLE bookmarks (
+		id text NOT NULL PRIMARY KEY,
+		channel_id text NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+		title text NOT NULL,
+		link text NOT NULL,
+		emoji text NOT NULL DEFAULT '',
+		icon_url text NOT NULL DEFAULT '',
+		type text NOT NULL,
+		rank text NOT NULL DEFAULT '',
+		updated_by text NOT NULL DEFAULT '',
+		created_at integer NOT NULL,
+		updated_at integer NOT NULL,
+		removed_at integer
+	);
+
+	CREATE INDEX bookmarks_idx_channel ON bookmarks (channel_id);
+	`,
+
+	// 10: retention purge audit log, Postgres migration 13
+	`
+	CREATE TABLE purges (
+		id integer PRIMARY KEY,
+		team_id text NOT NULL,
+		channel_id text NOT NULL,
+		policy text NOT NULL,
+		before integer NOT NULL,
+		messages integer NOT NULL,
+		pins integer NOT NULL,
+		oldest integer,
+		newest integer,
+		started_at integer NOT NULL,
+		finished_at integer NOT NULL
+	);
+	`,
+
+	// 11: legal holds, Postgres migration 14. User and channel IDs are JSON
+	// arrays.
+	`
+	CREATE TABLE legal_holds (
+		id integer PRIMARY KEY,
+		name text NOT NULL,
+		description text NOT NULL DEFAULT '',
+		user_ids text,
+		channel_ids text,
+		posted_from integer,
+		posted_to integer,
+		created_by text NOT NULL,
+		created_at integer NOT NULL,
+		released_by text NOT NULL DEFAULT '',
+		released_at integer
+	);
+
+	ALTER TABLE messages ADD COLUMN deleted_at integer;
+
+	CREATE TABLE message_revisions (
+		id integer PRIMARY KEY,
+		channel_id text NOT NULL,
+		user_id text NOT NULL,
+		"timestamp" integer NOT NULL,
+		msg text,
+		replaced_at integer NOT NULL,
+		FOREIGN KEY (channel_id, user_id, "timestamp") REFERENCES messages (channel_id, user_id, "timestamp") ON DELETE CASCADE
+	);
+
+	CREATE INDEX message_revisions_idx_message ON message_revisions (channel_id, "timestamp");
+	`,
+
+	// 12: audit log, Postgres migration 15. Params are JSON.
+	`
+	CREATE TABLE audit_log (
+		id integer PRIMARY KEY,
+		user_id text NOT NULL,
+		method text NOT NULL,
+		route text NOT NULL,
+		path text NOT NULL,
+		params text,
+		channel_id text,
+		status integer NOT NULL,
+		remote_addr text,
+		user_agent text,
+		created_at integer NOT NULL
+	);
+
+	CREATE INDEX audit_log_idx_created_at ON audit_log (created_at);
+	CREATE INDEX audit_log_idx_user ON audit_log (user_id, created_at);
+	CREATE INDEX audit_log_idx_channel ON audit_log (channel_id, created_at);
+	`,
+
+	// 13: bookmarks purged, Postgres migration 16
+	`
+	ALTER TABLE purges ADD COLUMN bookmarks integer NOT NULL DEFAULT 0;
+	`,
+
+	// 14: messages get an integer primary key. messages_fts rows are found
+	// by the rowid of their message, which VACUUM is free to renumber
+	// unless it's a column of the table.
+	`
+	CREATE TABLE messages_new (
+		id integer PRIMARY KEY,
+		channel_id text NOT NULL REFERENCES channels(id),
+		user_id text NOT NULL REFERENCES users(id),
+		"timestamp" integer NOT NULL,
+		thread_timestamp integer,
+		msg text,
+		deleted_at integer,
+		UNIQUE (channel_id, user_id, "timestamp")
+	);
+
+	INSERT INTO messages_new (channel_id, user_id, "timestamp", thread_timestamp, msg, deleted_at)
+	SELECT channel_id, user_id, "timestamp", thread_timestamp, msg, deleted_at FROM messages;
+
+	DROP TABLE messages;
+	ALTER TABLE messages_new RENAME TO messages;
+
+	CREATE INDEX messages_idx_channel_timestamp ON messages (channel_id, "timestamp");
+	CREATE INDEX messages_idx_timestamp_channel ON messages ("timestamp", channel_id);
+
+	CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts (rowid, text, titles, links, files, body) VALUES (` + ftsValues("new") + `);
+	END;
+
+	CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.id;
+	END;
+
+	CREATE TRIGGER messages_fts_update AFTER UPDATE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.id;
+		INSERT INTO messages_fts (rowid, text, titles, links, files, body) VALUES (` + ftsValues("new") + `);
+	END;
+
+	DELETE FROM messages_fts;
+	` + reindexFTS + `;
+	`,
+
+	// 15: when messages were archived, Postgres migration 17
+	`
+	ALTER TABLE messages ADD COLUMN archived_at integer;
+	`,
+}
+
+// reindexFTS fills messages_fts from scratch, the same way the triggers do.
+var reindexFTS = `
+	INSERT INTO messages_fts (rowid, text, titles, links, files, body)
+	SELECT ` + ftsValues("m") + `
+	FROM messages AS m`
+
+// ftsValues is the messages_fts row of the message row called m. Block kit
+// nests text objects at any depth, so every string field called text is
+// part of the body.
+func ftsValues(m string) string {
+	return strings.NewReplacer("$m", m).Replace(`
+		$m.rowid,
+		coalesce(json_extract($m.msg, '$.text'), ''),
+		(SELECT group_concat(replace(coalesce(json_extract(a.value, '$.title'), ''), char(10), ' '), char(10))
+			FROM json_each($m.msg, '$.attachments') AS a),
+		(SELECT group_concat(coalesce(json_extract(a.value, '$.title_link'), ''), ' ')
+			FROM json_each($m.msg, '$.attachments') AS a),
+		(SELECT group_concat(coalesce(json_extract(f.value, '$.name'), '') || ' ' || coalesce(json_extract(f.value, '$.title'), ''), ' ')
+			FROM json_each($m.msg, '$.files') AS f),
+		(SELECT group_concat(body, ' ') FROM (
+			SELECT t.value AS body FROM json_tree($m.msg, '$.blocks') AS t
+				WHERE t.key = 'text' AND t.type = 'text'
+			UNION ALL
+			SELECT t.value FROM json_tree($m.msg, '$.attachments') AS t
+				WHERE t.key = 'text' AND t.type = 'text'
+			UNION ALL
+			SELECT coalesce(json_extract(a.value, '$.pretext'), '') || ' ' || coalesce(json_extract(a.value, '$.fallback'), '') || ' ' || coalesce(json_extract(a.value, '$.footer'), '')
+				FROM json_each($m.msg, '$.attachments') AS a
+			UNION ALL
+			SELECT coalesce(json_extract(f.value, '$.title'), '') || ' ' || coalesce(json_extract(f.value, '$.value'), '')
+				FROM json_each($m.msg, '$.attachments') AS a, json_each(a.value, '$.fields') AS f
+			UNION ALL
+			SELECT json_extract(f.value, '$.preview') FROM json_each($m.msg, '$.files') AS f
+		))`)
+}
+
+func (s *Store) version() (int64, error) {
+	var version int64
+	err := s.queryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+func (s *Store) SchemaVersion() (int64, int64, error) {
+	version, err := s.version()
+	return version, int64(len(migrations)), err
+}
+
+func (s *Store) Migrate() (oldVersion, newVersion int64, err error) {
+	if oldVersion, err = s.version(); err != nil {
+		return
+	}
+	newVersion = oldVersion
+
+	if latest := int64(len(migrations)); oldVersion > latest {
+		err = fmt.Errorf("database schema version %d is newer than this release supports (%d), please upgrade slackarchive", oldVersion, latest)
+		return
+	}
+
+	if oldVersion == int64(len(migrations)) {
+		return
+	}
+
+	// Rebuilding a table, the way SQLite changes one, drops it first,
+	// which would take the rows referencing it along with foreign keys on.
+	// They're switched off for the migrations, which have to leave the
+	// foreign keys intact instead. The pragma is a no-op in a transaction
+	// and per connection, hence the connection of our own.
+	conn, err := s.db.Conn(s.ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var foreignKeys bool
+	if err = conn.QueryRowContext(s.ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return
+	}
+	if foreignKeys {
+		if _, err = conn.ExecContext(s.ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return
+		}
+		defer conn.ExecContext(s.ctx, "PRAGMA foreign_keys = ON")
+	}
+
+	for _, migration := range migrations[oldVersion:] {
+		if err = s.migrate(conn, migration, newVersion+1); err != nil {
+			return
+		}
+		newVersion++
+	}
+
+	return oldVersion, newVersion, nil
+}
+
+// migrate applies migration, which brings the schema to version, in a
+// transaction on conn.
+func (s *Store) migrate(conn *sql.Conn, migration string, version int64) error {
+	tx, err := conn.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	started := time.Now()
+	_, err = tx.Exec(migration)
+	s.logQuery(migration, nil, started)
+	if err != nil {
+		return fmt.Errorf("sqlite migration %d: %s", version, err)
+	}
+
+	var table string
+	if err := tx.QueryRow("PRAGMA foreign_key_check").Scan(&table, new(interface{}), new(interface{}), new(interface{})); err == nil {
+		return fmt.Errorf("sqlite migration %d: broke a foreign key of %s", version, table)
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	// PRAGMA doesn't take bind parameters
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,244 @@
+// Package sqlite is an embedded archive store for small installs that don't
+// want to run Postgres. Search uses an FTS5 index, so the binary has to be
+// built with the sqlite_fts5 tag.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ashb/slackarchive/config"
+	"github.com/ashb/slackarchive/logs"
+	"github.com/ashb/slackarchive/metrics"
+	"github.com/ashb/slackarchive/storage"
+	"github.com/ashb/slackarchive/tracing"
+)
+
+var log = logs.New("storage")
+
+func init() {
+	storage.Register("sqlite", open)
+	storage.Register("sqlite3", open)
+}
+
+// defaultOptions are passed to the driver unless the DSN sets them.
+var defaultOptions = map[string]string{
+	"_foreign_keys": "on",
+	"_journal_mode": "WAL",
+	"_busy_timeout": "5000",
+}
+
+// open accepts sqlite:///absolute/path.db, sqlite://relative.db and
+// sqlite:relative.db, with any go-sqlite3 options as query parameters.
+func open(dsn *url.URL, debug bool) (storage.Store, error) {
+	path := dsn.Opaque
+	if path == "" {
+		path = dsn.Host + dsn.Path
+	}
+	if path == "" {
+		return nil, fmt.Errorf("sqlite: no database path in dsn")
+	}
+
+	options := dsn.Query()
+	for k, v := range defaultOptions {
+		if options.Get(k) == "" {
+			options.Set(k, v)
+		}
+	}
+
+	return Open("file:"+path+"?"+options.Encode(), debug)
+}
+
+type Store struct {
+	db    *sql.DB
+	debug bool
+	// ctx is what queries run in, see WithContext
+	ctx context.Context
+}
+
+// Open opens the SQLite database described by a go-sqlite3 DSN.
+func Open(dsn string, debug bool) (*Store, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't open db")
+	}
+	// SQLite only has a single writer; serialising everything through one
+	// connection avoids "database is locked" between the bot and the API.
+	db.SetMaxOpenConns(1)
+
+	s := &Store{db: db, debug: debug, ctx: context.Background()}
+
+	var fts5 bool
+	if err := s.queryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		db.Close()
+		return nil, err
+	} else if !fts5 {
+		db.Close()
+		return nil, fmt.Errorf("sqlite: built without FTS5 support, rebuild with -tags sqlite_fts5")
+	}
+
+	return s, nil
+}
+
+func (s *Store) Teams() storage.TeamRepository       { return teams{s} }
+func (s *Store) Users() storage.UserRepository       { return users{s} }
+func (s *Store) Channels() storage.ChannelRepository { return channels{s} }
+func (s *Store) Messages() storage.MessageRepository { return messages{s} }
+func (s *Store) Emoji() storage.EmojiRepository      { return emoji{s} }
+func (s *Store) Pins() storage.PinRepository         { return pins{s} }
+func (s *Store) Purges() storage.PurgeRepository     { return purges{s} }
+func (s *Store) Audit() storage.AuditRepository      { return audit{s} }
+func (s *Store) Bookmarks() storage.BookmarkRepository {
+	return bookmarks{s}
+}
+func (s *Store) LegalHolds() storage.LegalHoldRepository {
+	return legalHolds{s}
+}
+func (s *Store) SavedSearches() storage.SavedSearchRepository {
+	return savedSearches{s}
+}
+
+// ConfigureSearch only warns: the FTS5 index has a single tokenizer, so
+// there is nothing to configure per team or channel.
+func (s *Store) ConfigureSearch(search config.SearchConfig) error {
+	if search.Language != "" || len(search.Teams) > 0 || len(search.Channels) > 0 {
+		log.Warning("SQLite archives index every message the same way, ignoring the search languages in the configuration")
+	}
+	return nil
+}
+
+func (s *Store) WithContext(ctx context.Context) storage.Store {
+	c := *s
+	c.ctx = ctx
+	return &c
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// exec, query and queryRow are the only ways the repositories talk to the
+// database, so there is one place to log and trace queries.
+func (s *Store) exec(query string, args ...interface{}) (res sql.Result, err error) {
+	ctx, span := tracing.StartQuery(s.ctx, "sqlite", query)
+	defer func() { tracing.EndQuery(span, err) }()
+	defer s.logQuery(query, args, time.Now())
+	return s.db.ExecContext(ctx, query, args...)
+}
+
+func (s *Store) query(query string, args ...interface{}) (rows *sql.Rows, err error) {
+	ctx, span := tracing.StartQuery(s.ctx, "sqlite", query)
+	defer func() { tracing.EndQuery(span, err) }()
+	defer s.logQuery(query, args, time.Now())
+	return s.db.QueryContext(ctx, query, args...)
+}
+
+func (s *Store) queryRow(query string, args ...interface{}) *row {
+	ctx, span := tracing.StartQuery(s.ctx, "sqlite", query)
+	started := time.Now()
+	return &row{
+		Row:     s.db.QueryRowContext(ctx, query, args...),
+		span:    span,
+		store:   s,
+		query:   query,
+		args:    args,
+		started: started,
+	}
+}
+
+// row is a *sql.Row whose query is logged and traced once it's scanned,
+// which is when errors show up.
+type row struct {
+	*sql.Row
+	span    trace.Span
+	store   *Store
+	query   string
+	args    []interface{}
+	started time.Time
+}
+
+func (r *row) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+	r.store.logQuery(r.query, r.args, r.started)
+	if err == sql.ErrNoRows {
+		tracing.EndQuery(r.span, nil)
+	} else {
+		tracing.EndQuery(r.span, err)
+	}
+	return err
+}
+
+// logQuery times a query for the metrics, up to its first row for queries
+// returning rows.
+func (s *Store) logQuery(query string, args []interface{}, started time.Time) {
+	metrics.DBQuery(query, time.Since(started))
+	if s.debug {
+		log.Debugf("%s %v", strings.Join(strings.Fields(query), " "), args)
+	}
+}
+
+// notFound translates database/sql's missing row error to storage's.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+// Timestamps are stored as microseconds since the epoch, the precision of
+// Slack's message timestamps.
+func toMicros(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UnixNano() / int64(time.Microsecond)
+}
+
+func fromMicros(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(0, v.Int64*int64(time.Microsecond))
+	return &t
+}
+
+// jsonColumn stores structured fields (profiles, topics, messages) as JSON
+// text, the way Postgres stores them as jsonb.
+type jsonColumn struct {
+	v interface{}
+}
+
+func (c jsonColumn) Value() (driver.Value, error) {
+	b, err := json.Marshal(c.v)
+	return string(b), err
+}
+
+func (c jsonColumn) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), c.v)
+	case []byte:
+		return json.Unmarshal(src, c.v)
+	default:
+		return fmt.Errorf("sqlite: can't scan %T in to JSON", src)
+	}
+}
+
+// placeholders returns "?, ?, ..." for n arguments.
+func placeholders(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"github.com/ashb/slackarchive/models"
+)
+
+type teams struct {
+	s *Store
+}
+
+const teamColumns = `id, coalesce(name, ''), coalesce(domain, ''), coalesce(token, ''), coalesce(enterprise_id, ''), coalesce(enterprise_name, ''), coalesce(plan, ''), icon`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTeam(row scanner) (*models.Team, error) {
+	team := &models.Team{}
+	err := row.Scan(&team.ID, &team.Name, &team.Domain, &team.Token, &team.EnterpriseID, &team.EnterpriseName, &team.Plan, jsonColumn{&team.Icon})
+	return team, err
+}
+
+func (r teams) Get(id string) (*models.Team, error) {
+	team, err := scanTeam(r.s.queryRow(`SELECT `+teamColumns+` FROM teams WHERE id = ?`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return team, nil
+}
+
+func (r teams) GetByDomain(domain string) (*models.Team, error) {
+	team, err := scanTeam(r.s.queryRow(`SELECT `+teamColumns+` FROM teams WHERE domain = ?`, domain))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return team, nil
+}
+
+func (r teams) List() ([]models.Team, error) {
+	rows, err := r.s.query(`SELECT ` + teamColumns + ` FROM teams WHERE is_disabled IS NOT TRUE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []models.Team
+	for rows.Next() {
+		team, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, *team)
+	}
+	return teams, rows.Err()
+}
+
+func (r teams) Upsert(team *models.Team) error {
+	_, err := r.s.exec(`
+		INSERT INTO teams (id, name, domain, token, enterprise_id, enterprise_name, plan, icon)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name, domain = excluded.domain, token = excluded.token,
+			enterprise_id = excluded.enterprise_id, enterprise_name = excluded.enterprise_name,
+			plan = excluded.plan, icon = excluded.icon`,
+		team.ID, team.Name, team.Domain, team.Token, team.EnterpriseID, team.EnterpriseName, team.Plan, jsonColumn{team.Icon},
+	)
+	return err
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/ashb/slackarchive/models"
+	"github.com/ashb/slackarchive/storage"
+)
+
+type users struct {
+	s *Store
+}
+
+const userColumns = `id, name, team_id, coalesce(enterprise_id, ''), deleted, coalesce(color, ''), profile,
+	is_bot, is_admin, is_owner, is_primary_owner, is_restricted, is_ultra_restricted, has_files,
+	coalesce(presence, ''), is_external, coalesce(home_team_id, ''), coalesce(home_team_name, '')`
+
+func scanUser(row scanner) (*models.User, error) {
+	u := &models.User{}
+	err := row.Scan(&u.ID, &u.Name, &u.TeamID, &u.EnterpriseID, &u.Deleted, &u.Color, jsonColumn{&u.Profile},
+		&u.IsBot, &u.IsAdmin, &u.IsOwner, &u.IsPrimaryOwner, &u.IsRestricted, &u.IsUltraRestricted, &u.HasFiles,
+		&u.Presence, &u.IsExternal, &u.HomeTeamID, &u.HomeTeamName)
+	return u, err
+}
+
+func (r users) selectUsers(query string, args ...interface{}) ([]models.User, error) {
+	rows, err := r.s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (r users) Get(id string) (*models.User, error) {
+	u, err := scanUser(r.s.queryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return u, nil
+}
+
+func (r users) GetMany(ids []string) ([]models.User, error) {
+	if len(ids) == 0 {
+		return []models.User{}, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return r.selectUsers(`SELECT `+userColumns+` FROM users WHERE id IN (`+placeholders(len(ids))+`)`, args...)
+}
+
+func (r users) Exists(id string) (bool, error) {
+	var exists bool
+	err := r.s.queryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (r users) List(filter storage.UserFilter) ([]models.User, int, error) {
+	var count int
+	if err := r.s.queryRow(`SELECT count(*) FROM users WHERE team_id = ?`, filter.TeamID).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	users, err := r.selectUsers(`SELECT `+userColumns+` FROM users WHERE team_id = ? ORDER BY name ASC LIMIT ? OFFSET ?`,
+		filter.TeamID, filter.Limit, filter.Offset)
+	return users, count, err
+}
+
+// insertUser is shared by the insert flavours, which only differ in what
+// they do with an existing row.
+func (r users) insertUser(u *models.User, onConflict string) error {
+	_, err := r.s.exec(`
+		INSERT INTO users (id, name, team_id, enterprise_id, deleted, color, profile,
+			is_bot, is_admin, is_owner, is_primary_owner, is_restricted, is_ultra_restricted, has_files,
+			presence, is_external, home_team_id, home_team_name)
+		VALUES (`+placeholders(18)+`)
+		ON CONFLICT (id) `+onConflict,
+		u.ID, u.Name, u.TeamID, u.EnterpriseID, u.Deleted, u.Color, jsonColumn{u.Profile},
+		u.IsBot, u.IsAdmin, u.IsOwner, u.IsPrimaryOwner, u.IsRestricted, u.IsUltraRestricted, u.HasFiles,
+		u.Presence, u.IsExternal, u.HomeTeamID, u.HomeTeamName,
+	)
+	return err
+}
+
+func (r users) Create(u *models.User) error {
+	return r.insertUser(u, `DO NOTHING`)
+}
+
+func (r users) Update(u *models.User) error {
+	_, err := r.s.exec(`
+		UPDATE users SET name = ?, team_id = ?, enterprise_id = ?, deleted = ?, color = ?, profile = ?,
+			is_bot = ?, is_admin = ?, is_owner = ?, is_primary_owner = ?, is_restricted = ?, is_ultra_restricted = ?,
+			has_files = ?, presence = ?, is_external = ?, home_team_id = ?, home_team_name = ?
+		WHERE id = ?`,
+		u.Name, u.TeamID, u.EnterpriseID, u.Deleted, u.Color, jsonColumn{u.Profile},
+		u.IsBot, u.IsAdmin, u.IsOwner, u.IsPrimaryOwner, u.IsRestricted, u.IsUltraRestricted,
+		u.HasFiles, u.Presence, u.IsExternal, u.HomeTeamID, u.HomeTeamName,
+		u.ID,
+	)
+	return err
+}
+
+func (r users) Upsert(u *models.User) error {
+	return r.insertUser(u, `DO UPDATE SET
+		name = excluded.name, team_id = excluded.team_id, enterprise_id = excluded.enterprise_id,
+		deleted = excluded.deleted, color = excluded.color, profile = excluded.profile,
+		is_bot = excluded.is_bot, is_admin = excluded.is_admin, is_owner = excluded.is_owner,
+		is_primary_owner = excluded.is_primary_owner, is_restricted = excluded.is_restricted,
+		is_ultra_restricted = excluded.is_ultra_restricted, has_files = excluded.has_files,
+		presence = excluded.presence, is_external = excluded.is_external,
+		home_team_id = excluded.home_team_id, home_team_name = excluded.home_team_name`)
+}
+
+func (r users) UpsertBot(u *models.User) error {
+	return r.insertUser(u, `DO UPDATE SET name = excluded.name, deleted = excluded.deleted, profile = excluded.profile`)
+}
+
+func (r users) UpsertExternal(u *models.User) error {
+	return r.insertUser(u, `DO UPDATE SET
+		name = excluded.name, deleted = excluded.deleted, profile = excluded.profile,
+		is_external = excluded.is_external, home_team_id = excluded.home_team_id, home_team_name = excluded.home_team_name`)
+}
+
+const historyColumns = `id, user_id, change, name, deleted, profile, changed_at`
+
+func scanHistory(row scanner) (*models.UserProfileHistory, error) {
+	var (
+		entry   models.UserProfileHistory
+		changed sql.NullInt64
+	)
+	err := row.Scan(&entry.ID, &entry.UserID, &entry.Change, &entry.Name, &entry.Deleted, jsonColumn{&entry.Profile}, &changed)
+	if err != nil {
+		return nil, err
+	}
+	entry.ChangedAt = *fromMicros(changed)
+	return &entry, nil
+}
+
+func (r users) History(id string) ([]models.UserProfileHistory, error) {
+	rows, err := r.s.query(`SELECT `+historyColumns+` FROM user_profile_history WHERE user_id = ? ORDER BY changed_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.UserProfileHistory{}
+	for rows.Next() {
+		entry, err := scanHistory(rows)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, *entry)
+	}
+	return history, rows.Err()
+}
+
+func (r users) LatestHistory(id string) (*models.UserProfileHistory, error) {
+	entry, err := scanHistory(r.s.queryRow(`SELECT `+historyColumns+` FROM user_profile_history WHERE user_id = ? ORDER BY changed_at DESC, id DESC LIMIT 1`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return entry, nil
+}
+
+func (r users) AddHistory(entry *models.UserProfileHistory) error {
+	res, err := r.s.exec(`
+		INSERT INTO user_profile_history (user_id, change, name, deleted, profile, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.UserID, entry.Change, entry.Name, entry.Deleted, jsonColumn{entry.Profile}, toMicros(&entry.ChangedAt),
+	)
+	if err != nil {
+		return err
+	}
+	entry.ID, err = res.LastInsertId()
+	return err
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SearchMode picks how MessageQuery.Query is matched against messages.
+type SearchMode string
+
+const (
+	// SearchFullText matches stemmed words with the full text index
+	SearchFullText SearchMode = ""
+	// SearchExact finds the terms as case insensitive substrings of the
+	// message text, for identifiers, ticket keys and bits of URLs
+	SearchExact SearchMode = "exact"
+	// SearchFuzzy is SearchExact that also finds words similar to the
+	// unquoted terms, to get past typos
+	SearchFuzzy SearchMode = "fuzzy"
+)
+
+// ErrSearchTimeout is returned when a search with a regular expression
+// takes too long, as one can over a large archive.
+var ErrSearchTimeout = errors.New("storage: search took too long")
+
+// FuzzyThreshold is how similar, from 0 to 1, a word has to be to a fuzzy
+// term to match it. It's the default word_similarity_threshold of pg_trgm.
+const FuzzyThreshold = 0.6
+
+// SubstringTerm is a term of an exact or fuzzy query:
+//
+//	PROJ-1234        a substring, * matches anything
+//	"foo bar"        a substring with spaces, never fuzzy
+//	/foo\d+/         a regular expression
+//	-foo             excluded, with any of the above
+type SubstringTerm struct {
+	Text    string
+	Quoted  bool
+	Regexp  bool
+	Negated bool
+}
+
+// Fuzzy is whether the term may match similar words in SearchFuzzy mode.
+func (t SubstringTerm) Fuzzy() bool {
+	return !t.Quoted && !t.Regexp && !t.Negated && !strings.Contains(t.Text, "*")
+}
+
+// LikePattern is the term as a LIKE pattern escaped with \.
+func (t SubstringTerm) LikePattern() string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(t.Text)
+	if !t.Quoted {
+		escaped = strings.Replace(escaped, "*", "%", -1)
+	}
+	return "%" + escaped + "%"
+}
+
+// pattern is the term as a case insensitive Go regular expression.
+func (t SubstringTerm) pattern() string {
+	if t.Regexp {
+		return "(?i)" + t.Text
+	}
+	if t.Quoted {
+		return "(?i)" + regexp.QuoteMeta(t.Text)
+	}
+	parts := strings.Split(t.Text, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return "(?i)" + strings.Join(parts, ".*?")
+}
+
+// ParseSubstringQuery splits an exact or fuzzy query in to its terms, and
+// checks the regular expressions compile, in Postgres too.
+func ParseSubstringQuery(query string) ([]SubstringTerm, error) {
+	var terms []SubstringTerm
+
+	for i := 0; i < len(query); {
+		if query[i] == ' ' || query[i] == '\t' || query[i] == '\n' {
+			i++
+			continue
+		}
+
+		var term SubstringTerm
+		if query[i] == '-' && i+1 < len(query) {
+			term.Negated = true
+			i++
+		}
+
+		switch {
+		case query[i] == '"':
+			term.Quoted = true
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				term.Text = query[i+1:]
+				i = len(query)
+			} else {
+				term.Text = query[i+1 : i+1+end]
+				i += end + 2
+			}
+		case query[i] == '/' && regexpEnd(query[i:]) > 0:
+			end := regexpEnd(query[i:])
+			term.Regexp = true
+			term.Text = query[i+1 : i+end]
+			i += end + 1
+		default:
+			end := strings.IndexAny(query[i:], " \t\n")
+			if end < 0 {
+				end = len(query) - i
+			}
+			term.Text = query[i : i+end]
+			i += end
+		}
+
+		if term.Text == "" {
+			continue
+		}
+		if term.Regexp {
+			if _, err := regexp.Compile(term.pattern()); err != nil {
+				return nil, fmt.Errorf("invalid regular expression /%s/", term.Text)
+			}
+			if _, err := term.PostgresRegexp(); err != nil {
+				return nil, fmt.Errorf("unsupported regular expression /%s/: %s", term.Text, err)
+			}
+		}
+		terms = append(terms, term)
+	}
+
+	return terms, nil
+}
+
+// HasRegexp tells whether an exact or fuzzy query has a regular expression.
+func HasRegexp(terms []SubstringTerm) bool {
+	for _, t := range terms {
+		if t.Regexp {
+			return true
+		}
+	}
+	return false
+}
+
+// regexpEnd finds the closing slash of a /regular expression/ at the start
+// of s: the first unescaped one followed by a space or the end of the
+// query, so paths like /api/v1 stay substrings. It's 0 without one.
+func regexpEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '/':
+			if i > 1 && (i+1 == len(s) || strings.IndexByte(" \t\n", s[i+1]) >= 0) {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// HighlightSubstrings wraps what the terms matched in text in [hl] and
+// [/hl], like the full text search highlighting. Fuzzy matches of similar
+// words aren't highlighted.
+func HighlightSubstrings(text string, terms []SubstringTerm) string {
+	var patterns []string
+	for _, t := range terms {
+		if !t.Negated {
+			patterns = append(patterns, "(?:"+t.pattern()+")")
+		}
+	}
+	if len(patterns) == 0 || text == "" {
+		return text
+	}
+
+	re, err := regexp.Compile(strings.Join(patterns, "|"))
+	if err != nil {
+		return text
+	}
+	return re.ReplaceAllStringFunc(text, func(match string) string {
+		if match == "" {
+			return match
+		}
+		return "[hl]" + match + "[/hl]"
+	})
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSubstringQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []SubstringTerm
+	}{
+		{"", nil},
+		{"  \t\n", nil},
+		{"PROJ-1234", []SubstringTerm{{Text: "PROJ-1234"}}},
+		{"foo  bar", []SubstringTerm{{Text: "foo"}, {Text: "bar"}}},
+		{`"foo bar" baz`, []SubstringTerm{{Text: "foo bar", Quoted: true}, {Text: "baz"}}},
+		{`"unclosed quote`, []SubstringTerm{{Text: "unclosed quote", Quoted: true}}},
+		{`""`, nil},
+		{"-foo", []SubstringTerm{{Text: "foo", Negated: true}}},
+		{`-"foo bar"`, []SubstringTerm{{Text: "foo bar", Quoted: true, Negated: true}}},
+		{"-", []SubstringTerm{{Text: "-"}}},
+		{"a-b", []SubstringTerm{{Text: "a-b"}}},
+		{`/foo\d+/`, []SubstringTerm{{Text: `foo\d+`, Regexp: true}}},
+		{`-/foo\d+/ bar`, []SubstringTerm{{Text: `foo\d+`, Regexp: true, Negated: true}, {Text: "bar"}}},
+		{`/a\/b/`, []SubstringTerm{{Text: `a\/b`, Regexp: true}}},
+		{"/a b/ c", []SubstringTerm{{Text: "a b", Regexp: true}, {Text: "c"}}},
+		// Paths aren't regular expressions
+		{"/api/v1", []SubstringTerm{{Text: "/api/v1"}}},
+		{"/api/v1/", []SubstringTerm{{Text: "api/v1", Regexp: true}}},
+		{"/", []SubstringTerm{{Text: "/"}}},
+		{"//", []SubstringTerm{{Text: "//"}}},
+		{"foo*bar", []SubstringTerm{{Text: "foo*bar"}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSubstringQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseSubstringQuery(%q): %s", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSubstringQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseSubstringQueryInvalid(t *testing.T) {
+	for _, query := range []string{"/foo(/", "ok /[a-/", `-/\p{Nope}/`, "/x{300}/", "/(?m)^x/"} {
+		if terms, err := ParseSubstringQuery(query); err == nil {
+			t.Errorf("ParseSubstringQuery(%q) = %+v, want an error", query, terms)
+		}
+	}
+}
+
+func TestSubstringTerm(t *testing.T) {
+	tests := []struct {
+		term  SubstringTerm
+		like  string
+		fuzzy bool
+	}{
+		{SubstringTerm{Text: "foo"}, "%foo%", true},
+		{SubstringTerm{Text: "foo*bar"}, "%foo%bar%", false},
+		{SubstringTerm{Text: "foo*bar", Quoted: true}, "%foo*bar%", false},
+		{SubstringTerm{Text: `50%_off\`}, `%50\%\_off\\%`, true},
+		{SubstringTerm{Text: "foo", Negated: true}, "%foo%", false},
+		{SubstringTerm{Text: "foo", Quoted: true}, "%foo%", false},
+	}
+	for _, tt := range tests {
+		if got := tt.term.LikePattern(); got != tt.like {
+			t.Errorf("%+v LikePattern() = %q, want %q", tt.term, got, tt.like)
+		}
+		if got := tt.term.Fuzzy(); got != tt.fuzzy {
+			t.Errorf("%+v Fuzzy() = %v, want %v", tt.term, got, tt.fuzzy)
+		}
+	}
+}
+
+func TestHighlightSubstrings(t *testing.T) {
+	tests := []struct {
+		text, query, want string
+	}{
+		{"see PROJ-1234 now", "proj-1234", "see [hl]PROJ-1234[/hl] now"},
+		{"foo and foo", "foo", "[hl]foo[/hl] and [hl]foo[/hl]"},
+		{"foo.bar", "foo*bar", "[hl]foo.bar[/hl]"},
+		{"a*b ab", `"a*b"`, "[hl]a*b[/hl] ab"},
+		{"v12 v3", `/v\d{2}/`, "[hl]v12[/hl] v3"},
+		{"foo bar", "-foo", "foo bar"},
+		{"foo bar", "foo -bar", "[hl]foo[/hl] bar"},
+		{"(x)", "(x)", "[hl](x)[/hl]"},
+		{"", "foo", ""},
+	}
+	for _, tt := range tests {
+		terms, err := ParseSubstringQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseSubstringQuery(%q): %s", tt.query, err)
+		}
+		if got := HighlightSubstrings(tt.text, terms); got != tt.want {
+			t.Errorf("HighlightSubstrings(%q, %q) = %q, want %q", tt.text, tt.query, got, tt.want)
+		}
+	}
+}
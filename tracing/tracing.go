@@ -0,0 +1,116 @@
+// Package tracing sends OpenTelemetry traces of API requests, database
+// queries and Slack API calls to a collector over OTLP, or to stdout.
+// Queries and Slack calls are only traced as part of a request or a sync,
+// on their own they'd be thousands of one span traces.
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ashb/slackarchive/config"
+	"github.com/ashb/slackarchive/logs"
+)
+
+const instrumentationName = "github.com/ashb/slackarchive"
+
+var log = logs.New("tracing")
+
+// Setup installs the exporter conf asks for. The function it returns sends
+// what's left and stops it, call it before exiting. Without an exporter
+// spans go nowhere.
+func Setup(ctx context.Context, conf config.TracingConfig) (func(context.Context) error, error) {
+	if conf.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch conf.Exporter {
+	case config.TraceOTLP:
+		var opts []otlptracehttp.Option
+		if conf.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(conf.Endpoint))
+		}
+		if conf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case config.TraceStdout:
+		exporter, err = stdouttrace.New()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(conf.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warningf("Error exporting traces: %s", err)
+	}))
+	return provider.Shutdown, nil
+}
+
+// Tracer starts spans. It does nothing until Setup installs an exporter.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Traced tells whether ctx is part of a trace.
+func Traced(ctx context.Context) bool {
+	return ctx != nil && trace.SpanFromContext(ctx).SpanContext().IsValid()
+}
+
+// LogFields are the IDs of ctx's trace and span for log lines, none when
+// it isn't traced.
+func LogFields(ctx context.Context) logrus.Fields {
+	sc := trace.SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return logrus.Fields{}
+	}
+	return logrus.Fields{"trace_id": sc.TraceID().String(), "span_id": sc.SpanID().String()}
+}
+
+// StartQuery starts the span of a database query, when ctx is traced. The
+// query goes in without its parameters, keeping messages out of traces.
+func StartQuery(ctx context.Context, system, query string) (context.Context, trace.Span) {
+	if !Traced(ctx) {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	query = strings.Join(strings.Fields(query), " ")
+	name := "query"
+	if i := strings.IndexByte(query, ' '); i > 0 {
+		name = strings.ToUpper(query[:i])
+	}
+	return Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemKey.String(system),
+		semconv.DBStatementKey.String(query),
+	))
+}
+
+// EndQuery ends the span of a database query that failed with err, if it
+// did.
+func EndQuery(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -30,6 +30,12 @@ func merge(dest reflect.Value, src reflect.Value) error {
 			return err
 		}
 	case reflect.Struct:
+		// Nothing sensible to merge a struct in to, e.g. slack.User.Enterprise
+		// meeting models.User.EnterpriseID
+		if dest.Kind() != reflect.Struct {
+			return nil
+		}
+
 		// try to set the struct
 		if src.Type() == dest.Type() {
 			if !dest.CanSet() {